@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/amirtalbi/examen_go/internal/config"
+	"github.com/amirtalbi/examen_go/internal/database"
+	"github.com/amirtalbi/examen_go/internal/domain/repositories"
+	"github.com/amirtalbi/examen_go/internal/service"
+	"github.com/amirtalbi/examen_go/pkg/auth"
+)
+
+// runCommand exécute une commande d'administration : api <commande> [options]
+func runCommand(args []string) error {
+	switch args[0] {
+	case "build-hibp-index":
+		return buildHIBPIndex(args[1:])
+	case "bootstrap-admin":
+		return bootstrapAdmin(args[1:])
+	default:
+		return fmt.Errorf("unknown command, available commands: build-hibp-index, bootstrap-admin")
+	}
+}
+
+// buildHIBPIndex convertit un fichier Pwned Passwords SHA-1 trié par empreinte
+// (téléchargé avec l'option d'ordre par hash) en index binaire compact
+func buildHIBPIndex(args []string) error {
+	flags := flag.NewFlagSet("build-hibp-index", flag.ContinueOnError)
+	source := flags.String("source", "", "sorted Pwned Passwords SHA-1 file (HASH:COUNT per line)")
+	output := flags.String("output", "", "index file to write")
+	minCount := flags.Int("min-count", 1, "skip hashes seen fewer times than this")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *source == "" || *output == "" {
+		flags.Usage()
+		return errors.New("-source and -output are required")
+	}
+
+	input, err := os.Open(*source)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	// L'index n'est mis en place qu'une fois complet, le serveur ne lit jamais un fichier partiel
+	temporary, err := os.CreateTemp(filepath.Dir(*output), filepath.Base(*output)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+
+	written, err := auth.BuildBreachedPasswordIndex(input, temporary, *minCount)
+	if err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temporary.Name(), *output); err != nil {
+		return err
+	}
+
+	log.Printf("Wrote %d hashes to %s", written, *output)
+	return nil
+}
+
+// bootstrapAdmin attribue le rôle admin à un compte existant. Elle ne sert qu'à nommer le
+// premier administrateur : les suivants sont nommés par l'API des rôles.
+func bootstrapAdmin(args []string) error {
+	flags := flag.NewFlagSet("bootstrap-admin", flag.ContinueOnError)
+	email := flags.String("email", "", "email address of the registered account to promote")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *email == "" {
+		flags.Usage()
+		return errors.New("-email is required")
+	}
+
+	cfg := config.Load()
+	db, err := database.NewPostgresConnection(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	userRepo := repositories.NewPostgresUserRepository(db)
+	sessionService := service.NewSessionService(repositories.NewPostgresSessionRepository(db), repositories.NewPostgresRefreshTokenRepository(db))
+	rbacService := service.NewRBACService(repositories.NewPostgresRoleRepository(db), userRepo, sessionService)
+	if err := rbacService.EnsureDefaults(); err != nil {
+		return err
+	}
+
+	user, err := rbacService.BootstrapAdmin(*email)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("%s (%s) is now an administrator; sign in again to receive the new permissions", user.Email, user.ID)
+	return nil
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -13,10 +15,20 @@ import (
 	"github.com/amirtalbi/examen_go/internal/config"
 	"github.com/amirtalbi/examen_go/internal/database"
 	"github.com/amirtalbi/examen_go/internal/domain/repositories"
+	"github.com/amirtalbi/examen_go/internal/mail"
 	"github.com/amirtalbi/examen_go/internal/service"
+	"github.com/amirtalbi/examen_go/pkg/auth"
 )
 
 func main() {
+	// api <commande> exécute une commande d'administration au lieu de démarrer le serveur
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			log.Fatalf("%s: %v", os.Args[1], err)
+		}
+		return
+	}
+
 	cfg := config.Load()
 
 	db, err := database.NewPostgresConnection(cfg)
@@ -26,11 +38,82 @@ func main() {
 	defer db.Close()
 
 	repo := repositories.NewPostgresUserRepository(db)
+	sessionRepo := repositories.NewPostgresSessionRepository(db)
+	refreshTokenRepo := repositories.NewPostgresRefreshTokenRepository(db)
+	revocations := repositories.NewPostgresRevocationStore(db)
+	clientRepo := repositories.NewPostgresOAuthClientRepository(db)
+	codeRepo := repositories.NewPostgresAuthorizationCodeRepository(db)
+	mfaRepo := repositories.NewPostgresMFARepository(db)
+	webauthnRepo := repositories.NewPostgresWebAuthnCredentialRepository(db)
+	loginCodeRepo := repositories.NewPostgresEmailLoginCodeRepository(db)
+	passwordHistoryRepo := repositories.NewPostgresPasswordHistoryRepository(db)
+	loginAttemptRepo := repositories.NewPostgresLoginAttemptRepository(db)
+	roleRepo := repositories.NewPostgresRoleRepository(db)
+	orgRepo := repositories.NewPostgresOrganizationRepository(db)
+	signingKeyRepo := repositories.NewPostgresSigningKeyRepository(db)
+	go pruneExpired(time.Hour, revocations, refreshTokenRepo, codeRepo, loginCodeRepo, loginAttemptRepo, sessionRepo)
+
+	keys, err := loadSigningKeys(cfg, signingKeyRepo)
+	if err != nil {
+		log.Fatalf("Failed to load signing key: %v", err)
+	}
+	go syncSigningKeys(keys, time.Minute)
+
+	// Les ID tokens ne sont signés qu'avec une clé asymétrique, publiée dans le JWKS
+	if auth.IDTokensEnabled(keys) {
+		if err := cfg.ValidateOIDCIssuer(); err != nil {
+			log.Fatalf("Failed to enable OpenID Connect: %v", err)
+		}
+	} else {
+		log.Printf("OpenID Connect disabled: set JWT_PRIVATE_KEY_PATH to sign ID tokens")
+	}
+
+	// Ce service est le premier destinataire (aud) des tokens d'accès qu'il émet
+	tokens := &auth.TokenConfig{
+		Keys:             keys,
+		Issuer:           cfg.JWTIssuer,
+		Audience:         cfg.JWTAudience,
+		AcceptedAudience: cfg.JWTAudience[0],
+		Leeway:           time.Duration(cfg.JWTLeewaySeconds) * time.Second,
+	}
+
+	mailer, err := mail.New(cfg.Mail)
+	if err != nil {
+		log.Fatalf("Failed to configure mailer: %v", err)
+	}
+	mailQueue := mail.NewQueue(mailer, mail.DefaultQueueOptions)
+	defer mailQueue.Close()
+
+	passwords := auth.NewArgon2idHasher(auth.Argon2idParams{
+		Memory:      uint32(cfg.PasswordHashing.Memory),
+		Iterations:  uint32(cfg.PasswordHashing.Iterations),
+		Parallelism: uint8(cfg.PasswordHashing.Parallelism),
+		SaltLength:  auth.DefaultArgon2idParams.SaltLength,
+		KeyLength:   auth.DefaultArgon2idParams.KeyLength,
+	})
 
-	authService := service.NewAuthService(repo, cfg)
+	policy, err := loadPasswordPolicy(cfg.PasswordPolicy)
+	if err != nil {
+		log.Fatalf("Failed to load password policy: %v", err)
+	}
+
+	mfaService := service.NewMFAService(mfaRepo, repo, cfg)
+	events := service.NewMailSecurityEventSink(service.NewLogSecurityEventSink(), repo, mailQueue)
+	lockoutService := service.NewLockoutService(loginAttemptRepo, repo, revocations, tokens, events, mailQueue, cfg)
+	sessionService := service.NewSessionService(sessionRepo, refreshTokenRepo)
+	rbacService := service.NewRBACService(roleRepo, repo, sessionService)
+	if err := rbacService.EnsureDefaults(); err != nil {
+		log.Fatalf("Failed to initialize roles: %v", err)
+	}
+	organizationService := service.NewOrganizationService(orgRepo, repo, rbacService, sessionService, cfg)
+	authService := service.NewAuthService(repo, sessionRepo, refreshTokenRepo, passwordHistoryRepo, revocations, mfaService, lockoutService, rbacService, organizationService, tokens, passwords, policy, events, mailQueue, cfg)
 	userService := service.NewUserService(repo)
+	userAdminService := service.NewUserAdminService(repo, rbacService, organizationService, sessionService, lockoutService, authService)
+	oauthService := service.NewOAuthService(clientRepo, codeRepo, repo, authService, sessionService, tokens, cfg)
+	webauthnService := service.NewWebAuthnService(webauthnRepo, repo, revocations, authService, tokens, events, cfg)
+	passwordlessService := service.NewPasswordlessService(repo, loginCodeRepo, revocations, authService, organizationService, lockoutService, tokens, mailQueue, cfg)
 
-	router := routes.SetupRouter(cfg, authService, userService)
+	router := routes.SetupRouter(cfg, keys, authService, userService, sessionService, oauthService, mfaService, webauthnService, passwordlessService, lockoutService, rbacService, userAdminService, organizationService)
 
 	server := &http.Server{
 		Addr:         ":" + cfg.ServerPort,
@@ -62,3 +145,118 @@ func main() {
 
 	log.Println("Server exited properly")
 }
+
+// loadSigningKeys ouvre le trousseau persisté et y ajoute la clé configurée (clé privée PEM,
+// ou HS256 avec JWT_SECRET) ainsi que les clés précédentes encore acceptées.
+// Une clé remplacée reste valide aussi longtemps que le plus long token qu'elle a pu signer.
+// Le matériel des clés est chiffré en base avec SIGNING_KEY_ENCRYPTION_KEY.
+func loadSigningKeys(cfg *config.Config, store auth.KeyStore) (*auth.KeyRing, error) {
+	kek, err := base64.StdEncoding.DecodeString(cfg.KeyEncryptionKey)
+	if err != nil || len(kek) == 0 {
+		return nil, errors.New("SIGNING_KEY_ENCRYPTION_KEY must hold 32 base64-encoded bytes")
+	}
+	if store, err = auth.NewEncryptedKeyStore(store, kek); err != nil {
+		return nil, err
+	}
+
+	retention := time.Hour * time.Duration(cfg.TokenExpiryHours)
+	if retention < auth.RefreshTokenLifetime {
+		retention = auth.RefreshTokenLifetime
+	}
+
+	key := auth.NewHMACKey(cfg.JWTKeyID, cfg.JWTSecret)
+	if cfg.JWTPrivateKeyPath != "" {
+		if key, err = auth.LoadSigningKey(cfg.JWTKeyID, cfg.JWTPrivateKeyPath); err != nil {
+			return nil, err
+		}
+	}
+
+	var previous []*auth.SigningKey
+	for kid, secret := range cfg.JWTPreviousSecrets {
+		previous = append(previous, auth.NewHMACKey(kid, secret))
+	}
+	for kid, path := range cfg.JWTPreviousKeyPaths {
+		previousKey, err := auth.LoadSigningKey(kid, path)
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, previousKey)
+	}
+
+	keys, err := auth.OpenKeyRing(store, retention, key, previous)
+	if err != nil {
+		return nil, err
+	}
+
+	current := keys.SigningKey()
+	log.Printf("Signing tokens with %s key %s (%d keys accepted)", current.Method.Alg(), current.ID, len(keys.VerificationKeys()))
+	return keys, nil
+}
+
+// syncSigningKeys relit périodiquement le trousseau pour suivre les rotations faites par les autres instances
+func syncSigningKeys(keys *auth.KeyRing, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := keys.Sync(); err != nil {
+			log.Printf("Failed to sync signing keys: %v", err)
+		}
+	}
+}
+
+// loadPasswordPolicy construit la politique de mots de passe, charge la liste des mots de passe
+// courants et ouvre le corpus de mots de passe divulgués. Le corpus reste ouvert jusqu'à l'arrêt.
+func loadPasswordPolicy(cfg config.PasswordPolicyConfig) (*auth.PasswordPolicy, error) {
+	policy := &auth.PasswordPolicy{
+		MinLength:         cfg.MinLength,
+		MaxLength:         cfg.MaxLength,
+		RequiredClasses:   cfg.RequiredClasses,
+		MinStrength:       cfg.MinStrength,
+		BreachedThreshold: cfg.BreachThreshold,
+	}
+
+	if cfg.DenylistPath != "" {
+		denylist, err := auth.LoadPasswordDenylist(cfg.DenylistPath)
+		if err != nil {
+			return nil, err
+		}
+		policy.Denylist = denylist
+		log.Printf("Loaded %d common passwords from %s", len(denylist), cfg.DenylistPath)
+	}
+
+	if cfg.BreachCorpusPath != "" {
+		breached, err := auth.OpenBreachedPasswords(cfg.BreachCorpusPath)
+		if err != nil {
+			return nil, err
+		}
+		policy.Breached = breached
+		log.Printf("Checking passwords against breach corpus %s", cfg.BreachCorpusPath)
+	}
+
+	return policy, nil
+}
+
+// pruner est implémenté par les stockages dont les entrées expirent
+type pruner interface {
+	PruneExpired() (int64, error)
+}
+
+// pruneExpired purge périodiquement les révocations, refresh tokens, sessions, codes d'autorisation et échecs de connexion expirés
+func pruneExpired(interval time.Duration, stores ...pruner) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, store := range stores {
+			pruned, err := store.PruneExpired()
+			if err != nil {
+				log.Printf("Failed to prune expired entries: %v", err)
+				continue
+			}
+			if pruned > 0 {
+				log.Printf("Pruned %d expired entries", pruned)
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/amirtalbi/examen_go/internal/api/middleware"
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminUserHandler expose la gestion des comptes utilisateurs aux administrateurs
+type AdminUserHandler struct {
+	userAdminService service.UserAdminService
+}
+
+func NewAdminUserHandler(userAdminService service.UserAdminService) *AdminUserHandler {
+	return &AdminUserHandler{
+		userAdminService: userAdminService,
+	}
+}
+
+// List retourne une page d'utilisateurs filtrés, du plus récent au plus ancien
+func (h *AdminUserHandler) List(c *gin.Context) {
+	var query models.ListUsersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.users(c).ListUsers(query)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *AdminUserHandler) Get(c *gin.Context) {
+	user, err := h.users(c).GetUser(c.Param("id"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AdminUserHandler) Update(c *gin.Context) {
+	var request models.UpdateUserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Attribuer des rôles revient à accorder des permissions : users:write ne suffit pas
+	if request.Roles != nil && !middleware.HasPermission(c, models.PermissionRolesWrite) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":      "Forbidden: missing permission " + models.PermissionRolesWrite,
+			"permission": models.PermissionRolesWrite,
+		})
+		return
+	}
+
+	user, err := h.users(c).UpdateUser(c.Param("id"), request)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ForcePasswordReset invalide le mot de passe actuel et envoie un lien de réinitialisation
+func (h *AdminUserHandler) ForcePasswordReset(c *gin.Context) {
+	if err := h.users(c).ForcePasswordReset(c.Param("id")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AdminUserHandler) Lock(c *gin.Context) {
+	if err := h.users(c).LockUser(c.GetString("userID"), c.Param("id")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Unlock lève aussi le blocage temporaire dû aux connexions échouées
+func (h *AdminUserHandler) Unlock(c *gin.Context) {
+	if err := h.users(c).UnlockUser(c.Param("id")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AdminUserHandler) Delete(c *gin.Context) {
+	if err := h.users(c).DeleteUser(c.GetString("userID"), c.Param("id")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// users retourne le service limité à l'organisation du token, ou à toute l'instance
+func (h *AdminUserHandler) users(c *gin.Context) service.UserAdminService {
+	return h.userAdminService.InOrganization(c.GetString("orgID"))
+}
+
+func (h *AdminUserHandler) handleError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case service.ErrRoleNotFound:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role not found"})
+	case service.ErrUserAlreadyExists:
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already in use"})
+	case service.ErrInvalidCursor:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+	case service.ErrCannotModifySelf:
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot lock or delete your own account"})
+	case service.ErrLastAdmin:
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot remove the last administrator"})
+	case service.ErrNotOrganizationMember:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case service.ErrForeignAccount:
+		c.JSON(http.StatusForbidden, gin.H{"error": "This account is not managed by the organization"})
+	default:
+		log.Printf("❌ Erreur de gestion de l'utilisateur %s par l'administrateur %s: %v", c.Param("id"), c.GetString("userID"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process user request"})
+	}
+}
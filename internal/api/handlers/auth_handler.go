@@ -2,12 +2,14 @@ package handlers
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"log"
 	"net/http"
 
 	"github.com/amirtalbi/examen_go/internal/domain/models"
 	"github.com/amirtalbi/examen_go/internal/service"
+	"github.com/amirtalbi/examen_go/pkg/auth"
 	"github.com/gin-gonic/gin"
 )
 
@@ -27,13 +29,29 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	request.UserAgent = c.Request.UserAgent()
+	request.IPAddress = c.ClientIP()
 
 	response, err := h.authService.Register(request)
+	if err == service.ErrEmailNotVerified {
+		// Compte créé, mais aucune session tant que l'adresse n'est pas confirmée
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Account created, please verify your email address before signing in",
+		})
+		return
+	}
+	if respondPasswordPolicy(c, err) {
+		return
+	}
 	if err != nil {
 		if err == service.ErrUserAlreadyExists {
 			c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
 			return
 		}
+		if err == service.ErrRegistrationClosed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Registration is not open for this organization"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
 		return
 	}
@@ -52,8 +70,39 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid credentials"})
 		return
 	}
+	request.UserAgent = c.Request.UserAgent()
+	request.IPAddress = c.ClientIP()
 
 	response, err := h.authService.Login(request)
+	var mfaRequired *service.MFARequiredError
+	if errors.As(err, &mfaRequired) {
+		// Mot de passe correct : les tokens ne sont émis qu'après le second facteur
+		c.JSON(http.StatusOK, models.MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    mfaRequired.Token,
+			ExpiresIn:   int(auth.MFATokenLifetime.Seconds()),
+		})
+		return
+	}
+	if err == service.ErrEmailNotVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Email address not verified"})
+		return
+	}
+	if err == service.ErrAccountLocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account locked by an administrator"})
+		return
+	}
+	if err == service.ErrPasswordResetRequired {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Password reset required, check your email for a reset link"})
+		return
+	}
+	if err == service.ErrNotOrganizationMember {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of the organization"})
+		return
+	}
+	if respondAccountLocked(c, err) {
+		return
+	}
 	if err != nil {
 		log.Printf("Login error: %v", err)
 		errorMsg := err.Error()
@@ -70,32 +119,94 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-func (h *AuthHandler) ForgotPassword(c *gin.Context) {
-	var request models.ForgotPasswordRequest
+// LoginMFA termine une connexion en deux étapes avec un code TOTP ou un code de récupération
+func (h *AuthHandler) LoginMFA(c *gin.Context) {
+	var request models.MFALoginRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	request.UserAgent = c.Request.UserAgent()
+	request.IPAddress = c.ClientIP()
 
-	// Appel au service pour générer un token JWT
-	resetToken, err := h.authService.ForgotPassword(request.Email)
+	response, err := h.authService.LoginWithMFA(request)
+	if respondAccountLocked(c, err) {
+		return
+	}
 	if err != nil {
-		// Pour des raisons de sécurité, nous ne révélons pas si l'email existe ou non
-		// Nous retournons un message générique même en cas d'erreur
-		c.JSON(http.StatusOK, gin.H{
-			"message": "If your email exists, you will receive a password reset token",
-		})
+		switch {
+		case errors.Is(err, service.ErrInvalidMFACode):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid MFA code"})
+		case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrMFANotEnrolled):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+		case errors.Is(err, service.ErrNotOrganizationMember), errors.Is(err, service.ErrOrganizationNotFound):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of the organization"})
+		default:
+			log.Printf("❌ Erreur lors de la vérification du second facteur: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify MFA code"})
+		}
 		return
 	}
 
-	// Retourner le token JWT dans la réponse
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var request models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Le token est envoyé par email : la réponse est la même que l'email existe ou non.
+	// La recherche du compte et l'envoi se font en arrière-plan, pour qu'elle parte aussi
+	// dans le même délai.
+	h.authService.RequestPasswordReset(request.Email, request.OrgID)
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Password reset token generated successfully",
-		"token":   resetToken,
+		"message": "If your email exists, you will receive a password reset link",
 	})
 }
 
+// VerifyEmail confirme l'adresse avec le token du lien envoyé à l'inscription
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var request models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.authService.VerifyEmail(request.Token)
+	if err == service.ErrInvalidToken {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Erreur lors de la vérification de l'adresse email: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email address"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email address verified"})
+}
+
+// ResendVerification renvoie le lien de vérification. La réponse ne révèle ni l'existence
+// du compte ni l'état de l'adresse.
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var request models.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.authService.ResendVerificationEmail(request.Email, request.OrgID); err != nil && err != service.ErrUserNotFound {
+		log.Printf("❌ Erreur lors du renvoi de l'email de vérification: %v", err)
+	}
 
+	c.JSON(http.StatusOK, gin.H{
+		"message": "If your email exists and is not yet verified, you will receive a verification link",
+	})
+}
 
 func (h *AuthHandler) ResetPassword(c *gin.Context) {
 	var request models.ResetPasswordRequest
@@ -108,6 +219,9 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 	
 	// Vérifier si le token est valide
 	err := h.authService.ResetPassword(request)
+	if respondPasswordPolicy(c, err) {
+		return
+	}
 	if err != nil {
 		if err == service.ErrInvalidToken {
 			// Token invalide ou expiré - renvoyer 401 Unauthorized
@@ -130,6 +244,34 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// ChangePassword remplace le mot de passe de l'utilisateur connecté et ferme ses autres sessions
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var request models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	request.IPAddress = c.ClientIP()
+
+	userID := c.GetString("userID")
+	err := h.authService.ChangePassword(userID, c.GetString("sessionID"), request)
+	if respondPasswordPolicy(c, err) || respondAccountLocked(c, err) {
+		return
+	}
+	switch {
+	case err == service.ErrPasswordMismatch:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Current password is incorrect"})
+	case err == service.ErrUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case err != nil:
+		log.Printf("❌ Erreur lors du changement de mot de passe de l'utilisateur %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
+
 func (h *AuthHandler) Logout(c *gin.Context) {
 	// Récupérer le token depuis le contexte
 	token, exists := c.Get("token")
@@ -203,11 +345,21 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	// Ignorer le token d'accès dans l'en-tête Authorization et utiliser uniquement le refresh token
 	response, err := h.authService.RefreshToken(request.RefreshToken)
 	if err != nil {
+		if err == service.ErrRefreshTokenReuse {
+			log.Printf("REFRESH ÉCHOUÉ: Réutilisation d'un refresh token détectée")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, please sign in again"})
+			return
+		}
 		if err == service.ErrInvalidToken {
 			log.Printf("REFRESH ÉCHOUÉ: Token invalide ou expiré")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
 			return
 		}
+		if err == service.ErrNotOrganizationMember || err == service.ErrOrganizationNotFound {
+			log.Printf("REFRESH ÉCHOUÉ: Utilisateur retiré de l'organisation de la session")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization membership revoked, please sign in again"})
+			return
+		}
 		log.Printf("REFRESH ÉCHOUÉ: Erreur interne: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
 		return
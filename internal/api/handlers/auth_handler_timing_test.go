@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/config"
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/domain/repositories"
+	"github.com/amirtalbi/examen_go/internal/mail"
+	"github.com/amirtalbi/examen_go/internal/service"
+	"github.com/amirtalbi/examen_go/pkg/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// Vérifie que /login et /forgot-password ne permettent pas de savoir si un email est
+// inscrit : mêmes réponses, et temps de réponse statistiquement indiscernables.
+//
+// Les requêtes sont servies directement par le routeur : un client HTTP dans le même
+// processus attendrait aussi le travail poursuivi en arrière-plan (envoi de l'email),
+// ce qu'un client distant ne voit pas.
+
+const (
+	// timingSamples est le nombre de mesures par cas, alternées dans un ordre aléatoire
+	timingSamples = 200
+	// timingTrim est la part des mesures les plus lentes et les plus rapides écartées (pauses du GC...)
+	timingTrim = 0.1
+	// maxRelativeGap est l'écart de moyenne toléré entre les deux cas
+	maxRelativeGap = 0.15
+	// maxTStatistic est la valeur du test t de Welch au-delà de laquelle l'écart est significatif
+	maxTStatistic = 4.0
+)
+
+const (
+	// timingEmail est le seul compte inscrit sur le serveur de test
+	timingEmail    = "timing@example.com"
+	timingPassword = "velo-rouge-du-dimanche"
+	unknownEmail   = "inconnu@example.com"
+	wrongPassword  = "mauvais-mot-de-passe"
+)
+
+// newTimingRouter monte les handlers de connexion avec des dépendances en mémoire
+func newTimingRouter(t *testing.T, lockout config.LockoutConfig) http.Handler {
+	t.Helper()
+
+	cfg := config.Load()
+	cfg.Lockout = lockout
+
+	userRepo := repositories.NewUserRepository()
+	mailer := mail.NewMemoryMailer()
+	tokens := &auth.TokenConfig{
+		Keys:             auth.NewStaticKeySource(auth.NewHMACKey(cfg.JWTKeyID, cfg.JWTSecret)),
+		Issuer:           cfg.JWTIssuer,
+		Audience:         cfg.JWTAudience,
+		AcceptedAudience: cfg.JWTAudience[0],
+	}
+	// Paramètres argon2id réduits pour garder le test rapide ; le hash de référence des
+	// emails inconnus utilise les mêmes, comme en production
+	passwords := auth.NewArgon2idHasher(auth.Argon2idParams{
+		Memory:      16 * 1024,
+		Iterations:  1,
+		Parallelism: 1,
+		SaltLength:  auth.DefaultArgon2idParams.SaltLength,
+		KeyLength:   auth.DefaultArgon2idParams.KeyLength,
+	})
+	revocations := repositories.NewRevocationStore()
+	events := service.NewLogSecurityEventSink()
+	sessionRepo := repositories.NewSessionRepository()
+	refreshTokenRepo := repositories.NewRefreshTokenRepository()
+	sessionService := service.NewSessionService(sessionRepo, refreshTokenRepo)
+	rbacService := service.NewRBACService(repositories.NewRoleRepository(), userRepo, sessionService)
+	authService := service.NewAuthService(
+		userRepo,
+		sessionRepo,
+		refreshTokenRepo,
+		repositories.NewPasswordHistoryRepository(),
+		revocations,
+		service.NewMFAService(repositories.NewMFARepository(), userRepo, cfg),
+		service.NewLockoutService(repositories.NewLoginAttemptRepository(), userRepo, revocations, tokens, events, mailer, cfg),
+		rbacService,
+		service.NewOrganizationService(repositories.NewOrganizationRepository(), userRepo, rbacService, sessionService, cfg),
+		tokens,
+		passwords,
+		&auth.PasswordPolicy{MinLength: 8, MaxLength: 128, MinStrength: 2},
+		events,
+		mailer,
+		cfg,
+	)
+
+	if _, err := authService.Register(models.RegisterRequest{
+		Name:     "Timing Test",
+		Email:    timingEmail,
+		Password: timingPassword,
+	}); err != nil && err != service.ErrEmailNotVerified {
+		t.Fatalf("Register: %v", err)
+	}
+
+	// Seuls les handlers concernés sont montés, sans journalisation des requêtes
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	authHandler := NewAuthHandler(authService)
+	router.POST("/login", authHandler.Login)
+	router.POST("/forgot-password", authHandler.ForgotPassword)
+	return router
+}
+
+func TestLoginAndForgotPasswordTiming(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mesures de temps de réponse ignorées en mode -short")
+	}
+
+	// Le blocage reste actif : chaque échec passe par le compteur du compte et de l'adresse
+	// IP, sans qu'aucun seuil ne soit atteint pendant les mesures
+	router := newTimingRouter(t, config.LockoutConfig{
+		AccountThreshold: 10 * timingSamples,
+		IPThreshold:      10 * timingSamples,
+		DurationSeconds:  60,
+		WindowSeconds:    60 * 60,
+	})
+
+	t.Run("login", func(t *testing.T) {
+		compareTimings(t, router, "/login",
+			map[string]string{"email": timingEmail, "password": wrongPassword},
+			map[string]string{"email": unknownEmail, "password": wrongPassword},
+		)
+	})
+	t.Run("forgot-password", func(t *testing.T) {
+		compareTimings(t, router, "/forgot-password",
+			map[string]string{"email": timingEmail},
+			map[string]string{"email": unknownEmail},
+		)
+	})
+}
+
+func TestLoginLockoutDoesNotRevealAccounts(t *testing.T) {
+	router := newTimingRouter(t, config.LockoutConfig{
+		AccountThreshold: 3,
+		DurationSeconds:  60,
+		WindowSeconds:    60 * 60,
+	})
+
+	known := map[string]string{"email": timingEmail, "password": wrongPassword}
+	unknown := map[string]string{"email": unknownEmail, "password": wrongPassword}
+	for i := 0; i < 4; i++ {
+		_, knownResponse := sendTimed(t, router, "/login", known)
+		_, unknownResponse := sendTimed(t, router, "/login", unknown)
+		if knownResponse != unknownResponse {
+			t.Fatalf("tentative %d : réponses différentes\n  inscrit : %s\n  inconnu : %s", i+1, knownResponse, unknownResponse)
+		}
+	}
+
+	// Le bon mot de passe ne doit pas non plus passer outre le blocage
+	_, response := sendTimed(t, router, "/login", map[string]string{"email": timingEmail, "password": timingPassword})
+	_, unknownResponse := sendTimed(t, router, "/login", unknown)
+	if !strings.HasPrefix(response, strconv.Itoa(http.StatusLocked)) {
+		t.Fatalf("réponse = %s, attendu un compte bloqué", response)
+	}
+	if response != unknownResponse {
+		t.Fatalf("compte bloqué : réponses différentes\n  bon mot de passe : %s\n  inconnu : %s", response, unknownResponse)
+	}
+}
+
+// compareTimings mesure les deux requêtes en alternance et vérifie que ni la réponse ni
+// le temps de réponse ne les distinguent
+func compareTimings(t *testing.T, router http.Handler, path string, known map[string]string, unknown map[string]string) {
+	t.Helper()
+
+	// Préchauffage : allocations de la mémoire d'argon2id
+	for i := 0; i < 10; i++ {
+		sendTimed(t, router, path, known)
+		sendTimed(t, router, path, unknown)
+	}
+
+	var knownTimes, unknownTimes []float64
+	var knownResponse, unknownResponse string
+	for i := 0; i < 2*timingSamples; i++ {
+		// L'ordre aléatoire évite qu'une dérive (GC, charge machine) favorise l'un des cas
+		useKnown := rand.Intn(2) == 0
+		if len(knownTimes) == timingSamples {
+			useKnown = false
+		} else if len(unknownTimes) == timingSamples {
+			useKnown = true
+		}
+
+		if useKnown {
+			elapsed, response := sendTimed(t, router, path, known)
+			knownTimes = append(knownTimes, elapsed)
+			knownResponse = response
+		} else {
+			elapsed, response := sendTimed(t, router, path, unknown)
+			unknownTimes = append(unknownTimes, elapsed)
+			unknownResponse = response
+		}
+	}
+
+	if knownResponse != unknownResponse {
+		t.Errorf("réponses différentes\n  inscrit : %s\n  inconnu : %s", knownResponse, unknownResponse)
+	}
+
+	knownMean, knownVariance := trimmedStats(knownTimes)
+	unknownMean, unknownVariance := trimmedStats(unknownTimes)
+	gap := math.Abs(knownMean-unknownMean) / math.Max(knownMean, unknownMean)
+	tStatistic := math.Abs(knownMean-unknownMean) / math.Sqrt(knownVariance/float64(len(knownTimes))+unknownVariance/float64(len(unknownTimes)))
+	t.Logf("email inscrit : %.3f ms, email inconnu : %.3f ms, écart : %.1f %%, t de Welch : %.2f",
+		knownMean, unknownMean, gap*100, tStatistic)
+
+	// Un écart n'est retenu que s'il est à la fois important et statistiquement significatif
+	if gap > maxRelativeGap && tStatistic > maxTStatistic {
+		t.Errorf("temps de réponse distinguables (écart > %.0f %% et t > %.1f)", maxRelativeGap*100, maxTStatistic)
+	}
+}
+
+// sendTimed envoie la requête et retourne sa durée en millisecondes, avec le statut et le corps reçus
+func sendTimed(t *testing.T, router http.Handler, path string, body map[string]string) (float64, string) {
+	t.Helper()
+
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to marshal JSON: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+
+	start := time.Now()
+	router.ServeHTTP(recorder, req)
+	elapsed := time.Since(start)
+
+	return float64(elapsed.Microseconds()) / 1000, fmt.Sprintf("%d %s", recorder.Code, bytes.TrimSpace(recorder.Body.Bytes()))
+}
+
+// trimmedStats retourne la moyenne et la variance des mesures, sans les valeurs extrêmes
+func trimmedStats(values []float64) (float64, float64) {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	cut := int(float64(len(sorted)) * timingTrim)
+	sorted = sorted[cut : len(sorted)-cut]
+
+	var sum float64
+	for _, value := range sorted {
+		sum += value
+	}
+	mean := sum / float64(len(sorted))
+
+	var squares float64
+	for _, value := range sorted {
+		squares += (value - mean) * (value - mean)
+	}
+	return mean, squares / float64(len(sorted)-1)
+}
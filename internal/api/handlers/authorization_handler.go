@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// loginPage est l'écran de connexion du serveur d'autorisation. Les paramètres de la
+// demande d'autorisation sont repostés tels quels avec les identifiants, puis avec
+// le code du second facteur si l'utilisateur l'a activé.
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="fr">
+<head>
+<meta charset="utf-8">
+<title>Connexion</title>
+</head>
+<body>
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+{{if .Client}}
+<h1>Connexion à {{.Client.Name}}</h1>
+<form method="post">
+<input type="hidden" name="response_type" value="{{.Request.ResponseType}}">
+<input type="hidden" name="client_id" value="{{.Request.ClientID}}">
+<input type="hidden" name="redirect_uri" value="{{.Request.RedirectURI}}">
+<input type="hidden" name="scope" value="{{.Request.Scope}}">
+<input type="hidden" name="state" value="{{.Request.State}}">
+<input type="hidden" name="code_challenge" value="{{.Request.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.Request.CodeChallengeMethod}}">
+<input type="hidden" name="nonce" value="{{.Request.Nonce}}">
+<input type="hidden" name="org_id" value="{{.Request.OrgID}}">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+{{if .MFAToken}}
+<input type="hidden" name="mfa_token" value="{{.MFAToken}}">
+<label>Code de vérification <input type="text" name="code" autocomplete="one-time-code" required autofocus></label>
+<button type="submit">Vérifier</button>
+{{else}}
+<label>Email <input type="email" name="email" required autofocus></label>
+<label>Mot de passe <input type="password" name="password" required></label>
+<button type="submit">Se connecter</button>
+{{end}}
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+type loginPageData struct {
+	Client    *models.OAuthClient
+	Request   models.AuthorizeRequest
+	MFAToken  string
+	CSRFToken string
+	Error     string
+}
+
+// AuthorizationHandler expose les endpoints du serveur d'autorisation OAuth 2.0 (RFC 6749)
+type AuthorizationHandler struct {
+	authService  service.AuthService
+	oauthService service.OAuthService
+}
+
+func NewAuthorizationHandler(authService service.AuthService, oauthService service.OAuthService) *AuthorizationHandler {
+	return &AuthorizationHandler{
+		authService:  authService,
+		oauthService: oauthService,
+	}
+}
+
+// Authorize valide la demande d'autorisation et affiche l'écran de connexion
+func (h *AuthorizationHandler) Authorize(c *gin.Context) {
+	var request models.AuthorizeRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		renderLoginPage(c, http.StatusBadRequest, loginPageData{Error: "Invalid authorization request"})
+		return
+	}
+
+	client, ok := h.validateRequest(c, request)
+	if !ok {
+		return
+	}
+
+	renderLoginPage(c, http.StatusOK, loginPageData{Client: client, Request: request})
+}
+
+// Login authentifie l'utilisateur (mot de passe puis, le cas échéant, second facteur)
+// puis redirige vers le client avec le code d'autorisation
+func (h *AuthorizationHandler) Login(c *gin.Context) {
+	var request models.AuthorizeRequest
+	if err := c.ShouldBind(&request); err != nil {
+		renderLoginPage(c, http.StatusBadRequest, loginPageData{Error: "Invalid authorization request"})
+		return
+	}
+
+	client, ok := h.validateRequest(c, request)
+	if !ok {
+		return
+	}
+
+	// Un formulaire qui n'a pas été affiché par ce navigateur est refusé avant toute
+	// vérification des identifiants
+	if !checkAuthorizeCSRF(c, request) {
+		log.Printf("❌ Formulaire de connexion sans jeton anti-CSRF valide pour le client %s", client.ID)
+		renderLoginPage(c, http.StatusForbidden, loginPageData{
+			Client:  client,
+			Request: request,
+			Error:   "Your sign-in form expired, please try again",
+		})
+		return
+	}
+
+	user, ok := h.authenticate(c, client, request)
+	if !ok {
+		return
+	}
+
+	location, err := h.oauthService.Authorize(request, user)
+	if err != nil {
+		log.Printf("❌ Erreur lors de l'émission du code d'autorisation: %v", err)
+		location, err = service.AuthorizeErrorURL(request, &service.OAuthError{Code: "server_error", Description: "Failed to issue authorization code"})
+		if err != nil {
+			renderLoginPage(c, http.StatusInternalServerError, loginPageData{Error: "Failed to issue authorization code"})
+			return
+		}
+	}
+
+	c.Redirect(http.StatusFound, location)
+}
+
+// authenticate vérifie le mot de passe ou, si un challenge est en cours, le code du second
+// facteur. En cas d'échec, l'écran adapté est affiché et ok vaut false.
+func (h *AuthorizationHandler) authenticate(c *gin.Context, client *models.OAuthClient, request models.AuthorizeRequest) (*models.User, bool) {
+	page := loginPageData{Client: client, Request: request}
+
+	if mfaToken := c.PostForm("mfa_token"); mfaToken != "" {
+		user, err := h.authService.VerifyMFA(mfaToken, c.PostForm("code"), c.ClientIP())
+		if errors.Is(err, service.ErrInvalidMFACode) {
+			page.MFAToken = mfaToken
+			page.Error = "Invalid verification code"
+			renderLoginPage(c, http.StatusUnauthorized, page)
+			return nil, false
+		}
+		var locked *service.AccountLockedError
+		if errors.As(err, &locked) {
+			c.Header("Retry-After", strconv.Itoa(locked.RetryAfterSeconds()))
+			page.MFAToken = mfaToken
+			page.Error = "Too many failed sign-in attempts, please try again later"
+			renderLoginPage(c, http.StatusLocked, page)
+			return nil, false
+		}
+		if err != nil {
+			log.Printf("❌ Challenge MFA refusé sur /authorize pour le client %s: %v", client.ID, err)
+			page.Error = "Your sign-in attempt expired, please sign in again"
+			renderLoginPage(c, http.StatusUnauthorized, page)
+			return nil, false
+		}
+		return user, true
+	}
+
+	user, err := h.authService.Authenticate(c.PostForm("email"), c.PostForm("password"), request.OrgID, c.ClientIP())
+	var mfaRequired *service.MFARequiredError
+	if errors.As(err, &mfaRequired) {
+		page.MFAToken = mfaRequired.Token
+		renderLoginPage(c, http.StatusOK, page)
+		return nil, false
+	}
+	if errors.Is(err, service.ErrEmailNotVerified) {
+		page.Error = "Please verify your email address before signing in"
+		renderLoginPage(c, http.StatusForbidden, page)
+		return nil, false
+	}
+	if errors.Is(err, service.ErrAccountLocked) {
+		page.Error = "Your account has been locked, please contact an administrator"
+		renderLoginPage(c, http.StatusForbidden, page)
+		return nil, false
+	}
+	if errors.Is(err, service.ErrPasswordResetRequired) {
+		page.Error = "Your password must be reset, check your email for a reset link"
+		renderLoginPage(c, http.StatusForbidden, page)
+		return nil, false
+	}
+	var locked *service.AccountLockedError
+	if errors.As(err, &locked) {
+		c.Header("Retry-After", strconv.Itoa(locked.RetryAfterSeconds()))
+		page.Error = "Too many failed sign-in attempts, please try again later"
+		renderLoginPage(c, http.StatusLocked, page)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("❌ Connexion refusée sur /authorize pour le client %s", client.ID)
+		page.Error = "Invalid credentials"
+		renderLoginPage(c, http.StatusUnauthorized, page)
+		return nil, false
+	}
+	return user, true
+}
+
+// Token implémente l'endpoint de token (RFC 6749 section 3.2)
+func (h *AuthorizationHandler) Token(c *gin.Context) {
+	var request models.TokenRequest
+	if err := c.ShouldBind(&request); err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_request", "Invalid token request")
+		return
+	}
+
+	// Les identifiants du client peuvent aussi être transmis en HTTP Basic (RFC 6749 section 2.3.1)
+	if clientID, clientSecret, ok := c.Request.BasicAuth(); ok {
+		request.ClientID = clientID
+		request.ClientSecret = clientSecret
+	}
+
+	response, err := h.oauthService.Token(request, models.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	})
+	if err != nil {
+		var oauthErr *service.OAuthError
+		if !errors.As(err, &oauthErr) {
+			log.Printf("❌ Erreur sur l'endpoint de token: %v", err)
+			oauthError(c, http.StatusInternalServerError, "server_error", "Failed to issue tokens")
+			return
+		}
+
+		status := http.StatusBadRequest
+		if oauthErr.Code == "invalid_client" {
+			c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+			status = http.StatusUnauthorized
+		}
+		oauthError(c, status, oauthErr.Code, oauthErr.Description)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, response)
+}
+
+// UserInfo retourne les claims de l'utilisateur couverts par le scope du token (OpenID Connect Core section 5.3)
+func (h *AuthorizationHandler) UserInfo(c *gin.Context) {
+	if !service.HasScope(c.GetString("scope"), models.ScopeOpenID) {
+		c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		oauthError(c, http.StatusForbidden, "insufficient_scope", "The openid scope is required")
+		return
+	}
+
+	userInfo, err := h.oauthService.UserInfo(c.GetString("userID"), c.GetString("scope"))
+	if err != nil {
+		log.Printf("❌ Erreur lors de la récupération des informations de l'utilisateur: %v", err)
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		oauthError(c, http.StatusUnauthorized, "invalid_token", "User not found")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, userInfo)
+}
+
+// validateRequest affiche une erreur si le client ou redirect_uri sont invalides,
+// et renvoie les autres erreurs au client via redirect_uri
+func (h *AuthorizationHandler) validateRequest(c *gin.Context, request models.AuthorizeRequest) (*models.OAuthClient, bool) {
+	client, err := h.oauthService.ValidateAuthorizeRequest(request)
+	if err == nil {
+		return client, true
+	}
+
+	var oauthErr *service.OAuthError
+	if !errors.As(err, &oauthErr) {
+		log.Printf("❌ Demande d'autorisation refusée: %v", err)
+		renderLoginPage(c, http.StatusBadRequest, loginPageData{Error: "Invalid client or redirect URI"})
+		return nil, false
+	}
+
+	location, err := service.AuthorizeErrorURL(request, oauthErr)
+	if err != nil {
+		renderLoginPage(c, http.StatusBadRequest, loginPageData{Error: "Invalid redirect URI"})
+		return nil, false
+	}
+	c.Redirect(http.StatusFound, location)
+	return nil, false
+}
+
+func renderLoginPage(c *gin.Context, status int, data loginPageData) {
+	// L'écran de connexion ne doit pas pouvoir être intégré dans une autre page
+	c.Header("X-Frame-Options", "DENY")
+	c.Header("Content-Security-Policy", "frame-ancestors 'none'")
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if data.Client != nil {
+		token, err := authorizeCSRFToken(c, data.Request)
+		if err != nil {
+			log.Printf("❌ Erreur lors de la génération du jeton anti-CSRF: %v", err)
+			status = http.StatusInternalServerError
+			data = loginPageData{Error: "Failed to display the sign-in form"}
+		} else {
+			data.CSRFToken = token
+		}
+	}
+	c.Status(status)
+	if err := loginPage.Execute(c.Writer, data); err != nil {
+		log.Printf("Erreur lors du rendu de la page de connexion: %v", err)
+	}
+}
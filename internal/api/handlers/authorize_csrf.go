@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Le formulaire de connexion de /authorize est protégé contre la falsification de requête
+// par un double envoi : un secret aléatoire est posé en cookie à l'affichage du formulaire,
+// qui porte un HMAC de la demande d'autorisation calculé avec ce secret. Un site tiers ne
+// peut ni lire le cookie ni le faire envoyer avec un POST cross-site (SameSite=Lax) : il ne
+// peut donc pas connecter la victime à un compte de son choix.
+const (
+	authorizeCSRFCookie = "authorize_csrf"
+	authorizeCSRFField  = "csrf_token"
+	// authorizeCSRFLifetime laisse le temps de saisir le mot de passe puis le second facteur
+	authorizeCSRFLifetime = 30 * time.Minute
+)
+
+// authorizeCSRFToken retourne le jeton du formulaire pour cette demande d'autorisation et
+// pose, ou prolonge, le cookie qui porte le secret
+func authorizeCSRFToken(c *gin.Context, request models.AuthorizeRequest) (string, error) {
+	secret, err := c.Cookie(authorizeCSRFCookie)
+	if err != nil || secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		secret = base64.RawURLEncoding.EncodeToString(buf)
+	}
+
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(authorizeCSRFCookie, secret, int(authorizeCSRFLifetime.Seconds()), c.Request.URL.Path, "", secure, true)
+	return authorizeCSRFMAC(secret, request), nil
+}
+
+// checkAuthorizeCSRF vérifie que le formulaire reposté a été affiché par ce navigateur pour
+// cette même demande d'autorisation
+func checkAuthorizeCSRF(c *gin.Context, request models.AuthorizeRequest) bool {
+	secret, err := c.Cookie(authorizeCSRFCookie)
+	if err != nil || secret == "" {
+		return false
+	}
+
+	expected := authorizeCSRFMAC(secret, request)
+	return hmac.Equal([]byte(expected), []byte(c.PostForm(authorizeCSRFField)))
+}
+
+// authorizeCSRFMAC lie le jeton à tous les paramètres de la demande : un jeton obtenu pour
+// un client ou une redirect_uri ne vaut pas pour une autre
+func authorizeCSRFMAC(secret string, request models.AuthorizeRequest) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	for _, value := range []string{
+		request.ResponseType,
+		request.ClientID,
+		request.RedirectURI,
+		request.Scope,
+		request.State,
+		request.CodeChallenge,
+		request.CodeChallengeMethod,
+		request.Nonce,
+		request.OrgID,
+	} {
+		mac.Write([]byte(value))
+		mac.Write([]byte{0})
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
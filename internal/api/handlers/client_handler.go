@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// ClientHandler gère le registre des clients OAuth
+type ClientHandler struct {
+	oauthService service.OAuthService
+}
+
+func NewClientHandler(oauthService service.OAuthService) *ClientHandler {
+	return &ClientHandler{
+		oauthService: oauthService,
+	}
+}
+
+func (h *ClientHandler) Create(c *gin.Context) {
+	var request models.RegisterClientRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	client, err := h.oauthService.RegisterClient(request)
+	if err != nil {
+		var oauthErr *service.OAuthError
+		if errors.As(err, &oauthErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Description})
+			return
+		}
+		log.Printf("❌ Erreur lors de l'enregistrement du client OAuth: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register client"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, client)
+}
+
+func (h *ClientHandler) List(c *gin.Context) {
+	clients, err := h.oauthService.ListClients()
+	if err != nil {
+		log.Printf("❌ Erreur lors de la récupération des clients OAuth: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list clients"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"clients": clients})
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/amirtalbi/examen_go/pkg/auth"
+	"github.com/gin-gonic/gin"
+)
+
+type KeyHandler struct {
+	keys *auth.KeyRing
+}
+
+func NewKeyHandler(keys *auth.KeyRing) *KeyHandler {
+	return &KeyHandler{
+		keys: keys,
+	}
+}
+
+func (h *KeyHandler) List(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"keys": h.keys.Keys()})
+}
+
+// Rotate génère une nouvelle clé de signature. Sans activates_at, elle signe immédiatement ;
+// sinon elle est publiée dans le JWKS dès maintenant et promue à la date indiquée.
+func (h *KeyHandler) Rotate(c *gin.Context) {
+	var request struct {
+		ActivatesAt *time.Time `json:"activates_at"`
+	}
+
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var activatesAt time.Time
+	if request.ActivatesAt != nil {
+		activatesAt = *request.ActivatesAt
+	}
+
+	info, err := h.keys.Rotate(activatesAt)
+	if err != nil {
+		log.Printf("❌ Erreur lors de la rotation de la clé de signature: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate signing key"})
+		return
+	}
+
+	log.Printf("✅ Nouvelle clé de signature %s (%s) active à partir de %s", info.ID, info.Algorithm, info.ActivatesAt.Format(time.RFC3339))
+	c.JSON(http.StatusCreated, info)
+}
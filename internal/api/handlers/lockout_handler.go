@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type LockoutHandler struct {
+	lockoutService service.LockoutService
+}
+
+func NewLockoutHandler(lockoutService service.LockoutService) *LockoutHandler {
+	return &LockoutHandler{
+		lockoutService: lockoutService,
+	}
+}
+
+// Unlock débloque un compte avec le lien reçu par email
+func (h *LockoutHandler) Unlock(c *gin.Context) {
+	var request models.UnlockAccountRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.lockoutService.UnlockWithToken(request.Token)
+	if err == service.ErrInvalidToken {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired unlock token"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Erreur lors du déblocage d'un compte: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock account"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account unlocked"})
+}
+
+// respondAccountLocked répond 423 avec Retry-After si err est une *service.AccountLockedError
+func respondAccountLocked(c *gin.Context, err error) bool {
+	var locked *service.AccountLockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+
+	retryAfter := locked.RetryAfterSeconds()
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusLocked, gin.H{
+		"error":       "Too many failed login attempts, try again later",
+		"retry_after": retryAfter,
+	})
+	return true
+}
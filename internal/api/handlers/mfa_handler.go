@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type MFAHandler struct {
+	mfaService service.MFAService
+}
+
+func NewMFAHandler(mfaService service.MFAService) *MFAHandler {
+	return &MFAHandler{
+		mfaService: mfaService,
+	}
+}
+
+// StartTOTP démarre l'enrôlement TOTP et retourne l'URI otpauth:// à scanner
+func (h *MFAHandler) StartTOTP(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	enrollment, err := h.mfaService.StartTOTPEnrollment(userID)
+	if err != nil {
+		h.handleError(c, userID, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTOTP active le second facteur et retourne les codes de récupération
+func (h *MFAHandler) ConfirmTOTP(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	var request models.MFACodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.mfaService.ConfirmTOTP(userID, request.Code)
+	if err != nil {
+		h.handleError(c, userID, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, codes)
+}
+
+// DisableTOTP désactive le second facteur, sur présentation d'un code valide
+func (h *MFAHandler) DisableTOTP(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	var request models.MFACodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.mfaService.DisableTOTP(userID, request.Code); err != nil {
+		h.handleError(c, userID, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *MFAHandler) handleError(c *gin.Context, userID string, err error) {
+	switch err {
+	case service.ErrMFAAlreadyEnabled:
+		c.JSON(http.StatusConflict, gin.H{"error": "MFA is already enabled"})
+	case service.ErrMFANotEnrolled:
+		c.JSON(http.StatusNotFound, gin.H{"error": "MFA is not enrolled"})
+	case service.ErrInvalidMFACode:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid MFA code"})
+	case service.ErrUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	default:
+		log.Printf("❌ Erreur MFA pour l'utilisateur %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process MFA request"})
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// OAuthHandler expose les endpoints OAuth 2.0 destinés aux autres services. Les clients
+// appelants sont ceux enregistrés via /admin/clients.
+type OAuthHandler struct {
+	authService  service.AuthService
+	oauthService service.OAuthService
+}
+
+func NewOAuthHandler(authService service.AuthService, oauthService service.OAuthService) *OAuthHandler {
+	return &OAuthHandler{
+		authService:  authService,
+		oauthService: oauthService,
+	}
+}
+
+// Introspect implémente l'introspection de token (RFC 7662). Seuls les clients confidentiels,
+// typiquement les serveurs de ressources, peuvent introspecter.
+func (h *OAuthHandler) Introspect(c *gin.Context) {
+	client, ok := h.authenticateClient(c)
+	if !ok {
+		return
+	}
+	if !client.IsConfidential() {
+		log.Printf("❌ Introspection refusée au client public %s", client.ID)
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		oauthError(c, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		oauthError(c, http.StatusBadRequest, "invalid_request", "Missing token parameter")
+		return
+	}
+
+	response := h.authService.Introspect(token, c.PostForm("token_type_hint"))
+	log.Printf("Introspection demandée par le client %s: active=%t", client.ID, response.Active)
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, response)
+}
+
+// Revoke implémente la révocation de token (RFC 7009). Un refresh token révoqué met fin
+// à sa session, ce qui invalide aussi les tokens d'accès qui en ont été dérivés.
+// Un client ne révoque que ses propres tokens ; sans client_id, seuls les tokens émis
+// directement par l'API (/login) peuvent l'être.
+func (h *OAuthHandler) Revoke(c *gin.Context) {
+	// Les clients publics (SPA, mobile) s'identifient par leur seul client_id
+	clientID := ""
+	if _, _, hasBasic := c.Request.BasicAuth(); hasBasic || c.PostForm("client_id") != "" {
+		client, ok := h.authenticateClient(c)
+		if !ok {
+			return
+		}
+		clientID = client.ID
+	}
+
+	token := c.PostForm("token")
+	if token == "" {
+		oauthError(c, http.StatusBadRequest, "invalid_request", "Missing token parameter")
+		return
+	}
+
+	// L'indice est facultatif : le type réel est déterminé à partir du token lui-même
+	hint := c.PostForm("token_type_hint")
+	if hint != "" && hint != models.TokenTypeAccess && hint != models.TokenTypeRefresh {
+		log.Printf("token_type_hint inconnu ignoré: %s", hint)
+	}
+
+	if err := h.authService.RevokeTokenForClient(token, clientID); err != nil {
+		log.Printf("❌ Erreur lors de la révocation du token: %v", err)
+		oauthError(c, http.StatusServiceUnavailable, "temporarily_unavailable", "Failed to revoke token")
+		return
+	}
+
+	// Un token invalide, inconnu ou émis à un autre client donne aussi 200 pour ne rien
+	// révéler (RFC 7009 sections 2.1 et 2.2)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+}
+
+// authenticateClient authentifie le client appelant via HTTP Basic ou via
+// client_id/client_secret dans le corps (RFC 6749 section 2.3.1)
+func (h *OAuthHandler) authenticateClient(c *gin.Context) (*models.OAuthClient, bool) {
+	clientID, clientSecret, ok := c.Request.BasicAuth()
+	if !ok {
+		clientID = c.PostForm("client_id")
+		clientSecret = c.PostForm("client_secret")
+	}
+
+	client, err := h.oauthService.AuthenticateClient(clientID, clientSecret)
+	if err != nil {
+		log.Printf("❌ Authentification du client OAuth %q refusée", clientID)
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+		oauthError(c, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return nil, false
+	}
+
+	return client, true
+}
+
+// oauthError renvoie une erreur au format OAuth 2.0 (RFC 6749 section 5.2)
+func oauthError(c *gin.Context, status int, code string, description string) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
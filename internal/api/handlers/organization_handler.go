@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/amirtalbi/examen_go/internal/api/middleware"
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// OrganizationHandler gère les organisations, leurs membres et le changement d'organisation de la session
+type OrganizationHandler struct {
+	organizationService service.OrganizationService
+	authService         service.AuthService
+}
+
+func NewOrganizationHandler(organizationService service.OrganizationService, authService service.AuthService) *OrganizationHandler {
+	return &OrganizationHandler{
+		organizationService: organizationService,
+		authService:         authService,
+	}
+}
+
+func (h *OrganizationHandler) List(c *gin.Context) {
+	organizations, err := h.organizationService.ListOrganizations()
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organizations": organizations})
+}
+
+func (h *OrganizationHandler) Create(c *gin.Context) {
+	var request models.CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	organization, err := h.organizationService.CreateOrganization(request)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, organization)
+}
+
+func (h *OrganizationHandler) Get(c *gin.Context) {
+	organization, err := h.organizationService.GetOrganization(c.Param("id"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, organization)
+}
+
+func (h *OrganizationHandler) Update(c *gin.Context) {
+	var request models.UpdateOrganizationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	organization, err := h.organizationService.UpdateOrganization(c.Param("id"), request)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, organization)
+}
+
+func (h *OrganizationHandler) Delete(c *gin.Context) {
+	if err := h.organizationService.DeleteOrganization(c.Param("id")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListMembers retourne une page de membres avec leurs rôles dans l'organisation
+func (h *OrganizationHandler) ListMembers(c *gin.Context) {
+	var query models.ListUsersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.organizationService.ListMembers(c.Param("id"), query)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+func (h *OrganizationHandler) AddMember(c *gin.Context) {
+	var request models.AddMemberRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Comme pour /admin/users, attribuer des rôles exige roles:write
+	if len(request.Roles) > 0 && !middleware.HasPermission(c, models.PermissionRolesWrite) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":      "Forbidden: missing permission " + models.PermissionRolesWrite,
+			"permission": models.PermissionRolesWrite,
+		})
+		return
+	}
+
+	membership, err := h.organizationService.AddMember(c.Param("id"), request)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, membership)
+}
+
+func (h *OrganizationHandler) UpdateMemberRoles(c *gin.Context) {
+	var request models.UpdateMemberRolesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	membership, err := h.organizationService.UpdateMemberRoles(c.Param("id"), c.Param("userId"), request)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, membership)
+}
+
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	if err := h.organizationService.RemoveMember(c.Param("id"), c.Param("userId")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListMine liste les organisations de l'utilisateur connecté
+func (h *OrganizationHandler) ListMine(c *gin.Context) {
+	organizations, err := h.organizationService.ListUserOrganizations(c.GetString("userID"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organizations": organizations})
+}
+
+// Switch ouvre une session dans l'organisation demandée et ferme la session courante
+func (h *OrganizationHandler) Switch(c *gin.Context) {
+	var request models.SwitchOrganizationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.UserAgent = c.Request.UserAgent()
+	request.IPAddress = c.ClientIP()
+
+	response, err := h.authService.SwitchOrganization(c.GetString("userID"), c.GetString("sessionID"), request)
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, response)
+	case service.ErrNotOrganizationMember, service.ErrOrganizationNotFound:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not a member of the organization"})
+	case service.ErrInvalidToken:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session not found, please sign in again"})
+	case service.ErrAccountLocked:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account locked by an administrator"})
+	case service.ErrEmailNotVerified:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Email address not verified"})
+	default:
+		h.handleError(c, err)
+	}
+}
+
+func (h *OrganizationHandler) handleError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrOrganizationNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+	case service.ErrOrganizationAlreadyExists:
+		c.JSON(http.StatusConflict, gin.H{"error": "Organization slug already in use"})
+	case service.ErrInvalidOrganizationSlug:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Slug must be 2 to 50 lowercase letters, digits or -, starting with a letter or digit"})
+	case service.ErrInvalidOrganizationSettings:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization settings"})
+	case service.ErrNotOrganizationMember:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+	case service.ErrUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case service.ErrForeignAccount:
+		c.JSON(http.StatusForbidden, gin.H{"error": "This account is not managed by the organization"})
+	case service.ErrUserAlreadyExists:
+		c.JSON(http.StatusConflict, gin.H{"error": "Email already used by another member"})
+	case service.ErrRoleNotFound:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role not found"})
+	case service.ErrInvalidCursor:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+	default:
+		log.Printf("❌ Erreur de gestion de l'organisation %s par l'utilisateur %s: %v", c.Param("id"), c.GetString("userID"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process organization request"})
+	}
+}
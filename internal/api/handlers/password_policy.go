@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/amirtalbi/examen_go/pkg/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// respondPasswordPolicy renvoie la liste des règles non respectées si err est une
+// *auth.PasswordPolicyError, pour que le client les affiche toutes en une fois
+func respondPasswordPolicy(c *gin.Context, err error) bool {
+	var policyError *auth.PasswordPolicyError
+	if !errors.As(err, &policyError) {
+		return false
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":      "Password does not meet the password policy",
+		"violations": policyError.Violations,
+	})
+	return true
+}
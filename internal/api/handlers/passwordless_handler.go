@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/service"
+	"github.com/amirtalbi/examen_go/pkg/auth"
+	"github.com/gin-gonic/gin"
+)
+
+type PasswordlessHandler struct {
+	passwordlessService service.PasswordlessService
+}
+
+func NewPasswordlessHandler(passwordlessService service.PasswordlessService) *PasswordlessHandler {
+	return &PasswordlessHandler{
+		passwordlessService: passwordlessService,
+	}
+}
+
+// SendMagicLink envoie un lien de connexion. La réponse est identique que l'email existe ou non.
+func (h *PasswordlessHandler) SendMagicLink(c *gin.Context) {
+	var request models.PasswordlessRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.passwordlessService.SendMagicLink(request.Email, request.OrgID); err != nil && err != service.ErrUserNotFound {
+		log.Printf("❌ Erreur lors de l'envoi du lien de connexion: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "If your email exists, you will receive a login link",
+	})
+}
+
+// LoginWithMagicLink échange le token du lien contre la même réponse que /login
+func (h *PasswordlessHandler) LoginWithMagicLink(c *gin.Context) {
+	var request models.MagicLinkLoginRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.UserAgent = c.Request.UserAgent()
+	request.IPAddress = c.ClientIP()
+
+	response, err := h.passwordlessService.LoginWithMagicLink(request)
+	h.respond(c, response, err)
+}
+
+// SendLoginCode envoie un code à 6 chiffres. La réponse est identique que l'email existe ou non.
+func (h *PasswordlessHandler) SendLoginCode(c *gin.Context) {
+	var request models.PasswordlessRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Un compte bloqué reçoit la même réponse, sans nouveau code
+	err := h.passwordlessService.SendLoginCode(request.Email, request.OrgID, c.ClientIP())
+	var locked *service.AccountLockedError
+	if err != nil && err != service.ErrUserNotFound && !errors.As(err, &locked) {
+		log.Printf("❌ Erreur lors de l'envoi du code de connexion: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "If your email exists, you will receive a login code",
+	})
+}
+
+// LoginWithCode échange le code reçu par email contre la même réponse que /login
+func (h *PasswordlessHandler) LoginWithCode(c *gin.Context) {
+	var request models.EmailCodeLoginRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.UserAgent = c.Request.UserAgent()
+	request.IPAddress = c.ClientIP()
+
+	response, err := h.passwordlessService.LoginWithCode(request)
+	h.respond(c, response, err)
+}
+
+func (h *PasswordlessHandler) respond(c *gin.Context, response *models.AuthResponse, err error) {
+	if respondAccountLocked(c, err) {
+		return
+	}
+
+	var mfaRequired *service.MFARequiredError
+	switch {
+	case errors.As(err, &mfaRequired):
+		// Premier facteur validé : les tokens ne sont émis qu'après le second facteur
+		c.JSON(http.StatusOK, models.MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    mfaRequired.Token,
+			ExpiresIn:   int(auth.MFATokenLifetime.Seconds()),
+		})
+	case err == service.ErrInvalidToken:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired login link"})
+	case err == service.ErrInvalidLoginCode:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired login code"})
+	case err == service.ErrAccountLocked:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account locked by an administrator"})
+	case err != nil:
+		log.Printf("❌ Erreur de connexion sans mot de passe: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+	default:
+		c.JSON(http.StatusOK, response)
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// RoleHandler gère les rôles, les permissions et leur attribution aux utilisateurs
+type RoleHandler struct {
+	rbacService service.RBACService
+}
+
+func NewRoleHandler(rbacService service.RBACService) *RoleHandler {
+	return &RoleHandler{
+		rbacService: rbacService,
+	}
+}
+
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	permissions, err := h.rbacService.ListPermissions()
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"permissions": permissions})
+}
+
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	roles, err := h.rbacService.ListRoles()
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var request models.CreateRoleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := h.rbacService.CreateRole(request)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdatePermissions remplace les permissions d'un rôle
+func (h *RoleHandler) UpdatePermissions(c *gin.Context) {
+	var request models.UpdateRolePermissionsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := h.rbacService.UpdateRolePermissions(c.Param("name"), request)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	if err := h.rbacService.DeleteRole(c.Param("name")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListUserRoles liste les rôles attribués à un utilisateur
+func (h *RoleHandler) ListUserRoles(c *gin.Context) {
+	roles, err := h.rbacService.ListUserRoles(c.Param("id"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+func (h *RoleHandler) AssignRole(c *gin.Context) {
+	var request models.AssignRoleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.rbacService.AssignRole(c.Param("id"), request.Role); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *RoleHandler) UnassignRole(c *gin.Context) {
+	if err := h.rbacService.UnassignRole(c.Param("id"), c.Param("role")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *RoleHandler) handleError(c *gin.Context, err error) {
+	switch err {
+	case service.ErrRoleNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+	case service.ErrUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case service.ErrRoleAlreadyExists:
+		c.JSON(http.StatusConflict, gin.H{"error": "Role already exists"})
+	case service.ErrInvalidRoleName:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role name must be 2 to 50 lowercase letters, digits, - or _, starting with a letter"})
+	case service.ErrUnknownPermission:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown permission"})
+	case service.ErrProtectedRole:
+		c.JSON(http.StatusForbidden, gin.H{"error": "The admin role cannot be modified"})
+	case service.ErrLastAdmin:
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot remove the last administrator"})
+	default:
+		log.Printf("❌ Erreur de gestion des rôles par l'utilisateur %s: %v", c.GetString("userID"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process role request"})
+	}
+}
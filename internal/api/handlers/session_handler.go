@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/amirtalbi/examen_go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	sessionService service.SessionService
+}
+
+func NewSessionHandler(sessionService service.SessionService) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+	}
+}
+
+func (h *SessionHandler) List(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: User ID not found in context"})
+		return
+	}
+
+	sessions, err := h.sessionService.ListSessions(userID, c.GetString("sessionID"))
+	if err != nil {
+		log.Printf("❌ Erreur lors de la récupération des sessions de l'utilisateur %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+func (h *SessionHandler) Revoke(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: User ID not found in context"})
+		return
+	}
+
+	err := h.sessionService.RevokeSession(userID, c.Param("id"))
+	if err != nil {
+		if err == service.ErrSessionNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		log.Printf("❌ Erreur lors de la révocation de la session %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	log.Printf("✅ Session %s révoquée pour l'utilisateur %s", c.Param("id"), userID)
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeAll déconnecte l'utilisateur de tous ses appareils, y compris celui de la requête
+func (h *SessionHandler) RevokeAll(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: User ID not found in context"})
+		return
+	}
+
+	count, err := h.sessionService.RevokeAllSessions(userID)
+	if err != nil {
+		log.Printf("❌ Erreur lors de la révocation des sessions de l'utilisateur %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	log.Printf("✅ %d sessions révoquées pour l'utilisateur %s", count, userID)
+	c.Status(http.StatusNoContent)
+}
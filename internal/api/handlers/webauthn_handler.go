@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type WebAuthnHandler struct {
+	webauthnService service.WebAuthnService
+}
+
+func NewWebAuthnHandler(webauthnService service.WebAuthnService) *WebAuthnHandler {
+	return &WebAuthnHandler{
+		webauthnService: webauthnService,
+	}
+}
+
+// BeginRegistration retourne les options à passer à navigator.credentials.create()
+func (h *WebAuthnHandler) BeginRegistration(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	options, err := h.webauthnService.BeginRegistration(userID)
+	if err != nil {
+		h.handleError(c, userID, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, options)
+}
+
+// FinishRegistration vérifie l'attestation et enregistre la clé d'accès
+func (h *WebAuthnHandler) FinishRegistration(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	var request models.WebAuthnRegisterRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	credential, err := h.webauthnService.FinishRegistration(userID, request)
+	if err != nil {
+		h.handleError(c, userID, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, credential)
+}
+
+// ListCredentials liste les clés d'accès de l'utilisateur connecté
+func (h *WebAuthnHandler) ListCredentials(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	credentials, err := h.webauthnService.ListCredentials(userID)
+	if err != nil {
+		h.handleError(c, userID, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"credentials": credentials})
+}
+
+// DeleteCredential supprime une clé d'accès de l'utilisateur connecté
+func (h *WebAuthnHandler) DeleteCredential(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	if err := h.webauthnService.DeleteCredential(userID, c.Param("id")); err != nil {
+		h.handleError(c, userID, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// BeginLogin retourne les options à passer à navigator.credentials.get()
+func (h *WebAuthnHandler) BeginLogin(c *gin.Context) {
+	options, err := h.webauthnService.BeginLogin()
+	if err != nil {
+		h.handleError(c, "", err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, options)
+}
+
+// FinishLogin vérifie l'assertion et retourne la même réponse que /login
+func (h *WebAuthnHandler) FinishLogin(c *gin.Context) {
+	var request models.WebAuthnLoginRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	request.UserAgent = c.Request.UserAgent()
+	request.IPAddress = c.ClientIP()
+
+	response, err := h.webauthnService.FinishLogin(request)
+	if err != nil {
+		h.handleError(c, "", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *WebAuthnHandler) handleError(c *gin.Context, userID string, err error) {
+	switch err {
+	case service.ErrInvalidToken:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired ceremony token"})
+	case service.ErrWebAuthnVerificationFailed:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Passkey verification failed"})
+	case service.ErrWebAuthnCredentialExists:
+		c.JSON(http.StatusConflict, gin.H{"error": "Passkey already registered"})
+	case service.ErrWebAuthnCredentialNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Passkey not found"})
+	case service.ErrUserNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case service.ErrEmailNotVerified:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Email address not verified"})
+	case service.ErrAccountLocked:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account locked by an administrator"})
+	default:
+		log.Printf("❌ Erreur WebAuthn pour l'utilisateur %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process passkey request"})
+	}
+}
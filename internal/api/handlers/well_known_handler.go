@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/amirtalbi/examen_go/internal/config"
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/pkg/auth"
+	"github.com/gin-gonic/gin"
+)
+
+type WellKnownHandler struct {
+	config *config.Config
+	keys   auth.KeySource
+}
+
+func NewWellKnownHandler(config *config.Config, keys auth.KeySource) *WellKnownHandler {
+	return &WellKnownHandler{
+		config: config,
+		keys:   keys,
+	}
+}
+
+// JWKS publie les clés publiques permettant aux autres services de vérifier nos tokens
+func (h *WellKnownHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, auth.NewJWKS(h.keys.VerificationKeys()))
+}
+
+// OpenIDConfiguration publie le document de découverte OpenID Connect, servi uniquement
+// lorsque les ID tokens sont signés par une clé asymétrique. JWT_ISSUER est alors l'URL
+// sous laquelle ce document est servi (vérifié au démarrage).
+func (h *WellKnownHandler) OpenIDConfiguration(c *gin.Context) {
+	if !auth.IDTokensEnabled(h.keys) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OpenID Connect is not enabled"})
+		return
+	}
+
+	baseURL := h.config.PublicURL + "/" + h.config.APIPrefix
+
+	// Les clés HMAC ne signent jamais d'ID token
+	var algorithms []string
+	seen := make(map[string]bool)
+	for _, key := range h.keys.VerificationKeys() {
+		if alg := key.Method.Alg(); !key.IsSymmetric() && !seen[alg] {
+			seen[alg] = true
+			algorithms = append(algorithms, alg)
+		}
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, models.OpenIDConfiguration{
+		Issuer:                            h.config.JWTIssuer,
+		AuthorizationEndpoint:             baseURL + "/authorize",
+		TokenEndpoint:                     baseURL + "/token",
+		UserInfoEndpoint:                  baseURL + "/userinfo",
+		JWKSURI:                           baseURL + "/.well-known/jwks.json",
+		RevocationEndpoint:                baseURL + "/revoke",
+		IntrospectionEndpoint:             baseURL + "/introspect",
+		ScopesSupported:                   []string{models.ScopeOpenID, models.ScopeProfile, models.ScopeEmail},
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken, models.GrantTypeClientCredentials},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  algorithms,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{auth.PKCEMethodS256},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "at_hash", "name", "email", "email_verified"},
+	})
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"bytes"
+	"crypto/subtle"
 	"encoding/json"
 	"io"
 	"log"
@@ -84,6 +85,12 @@ func LoggerMiddleware() gin.HandlerFunc {
 	}
 }
 
+// Types de principal placés dans le contexte gin sous la clé "principalType"
+const (
+	PrincipalUser   = "user"
+	PrincipalClient = "client"
+)
+
 func AuthMiddleware(authService service.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Vérifier si l'en-tête d'autorisation existe
@@ -113,7 +120,13 @@ func AuthMiddleware(authService service.AuthService) gin.HandlerFunc {
 			return
 		}
 
-		userID, err := authService.ValidateToken(tokenString)
+		claims, err := authService.ValidateToken(tokenString)
+		if err == service.ErrTokenRevoked {
+			log.Printf("❌ Erreur d'authentification: Token révoqué")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Token has been revoked"})
+			c.Abort()
+			return
+		}
 		if err != nil {
 			log.Printf("❌ Erreur d'authentification: Token invalide: %v", err)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Invalid token"})
@@ -121,10 +134,111 @@ func AuthMiddleware(authService service.AuthService) gin.HandlerFunc {
 			return
 		}
 
-		// Token valide, continuer
-		log.Printf("✅ Authentification réussie pour l'utilisateur ID: %s", userID)
+		// Token valide, continuer. Un client machine n'a ni utilisateur ni session.
+		if claims.IsClient() {
+			log.Printf("✅ Authentification réussie pour le client ID: %s", claims.ClientID)
+			c.Set("principalType", PrincipalClient)
+		} else {
+			log.Printf("✅ Authentification réussie pour l'utilisateur ID: %s", claims.UserID)
+			c.Set("principalType", PrincipalUser)
+			c.Set("userID", claims.UserID)
+			c.Set("sessionID", claims.SessionID)
+			c.Set("roles", claims.Roles)
+			c.Set("permissions", claims.Permissions)
+			c.Set("orgID", claims.OrgID)
+		}
 		c.Set("token", tokenString)
-		c.Set("userID", userID)
+		c.Set("clientID", claims.ClientID)
+		c.Set("scope", claims.Scope)
+		c.Next()
+	}
+}
+
+// RequireUser réserve une route aux tokens émis pour un utilisateur
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("principalType") != PrincipalUser {
+			log.Printf("❌ Accès refusé au client %s: route réservée aux utilisateurs", c.GetString("clientID"))
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: This endpoint requires a user token"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePermission réserve une route aux utilisateurs dont le token porte la permission.
+// À placer après AuthMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if HasPermission(c, permission) {
+			c.Next()
+			return
+		}
+
+		log.Printf("❌ Accès refusé à l'utilisateur %s: permission %s manquante", c.GetString("userID"), permission)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":      "Forbidden: missing permission " + permission,
+			"permission": permission,
+		})
+		c.Abort()
+	}
+}
+
+// RequirePlatformContext réserve une route aux tokens émis dans le contexte de l'instance :
+// les permissions accordées dans une organisation ne s'étendent pas aux autres.
+// À placer après AuthMiddleware.
+func RequirePlatformContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if orgID := c.GetString("orgID"); orgID != "" {
+			log.Printf("❌ Accès refusé à l'utilisateur %s: route réservée à l'instance (organisation %s)", c.GetString("userID"), orgID)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: This endpoint is not available in an organization context"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireOrganizationAccess limite un token d'organisation à la sienne, désignée par le
+// paramètre de route param. Les tokens de l'instance accèdent à toutes les organisations.
+func RequireOrganizationAccess(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if orgID := c.GetString("orgID"); orgID != "" && orgID != c.Param(param) {
+			log.Printf("❌ Accès refusé à l'utilisateur %s: organisation %s hors de son contexte", c.GetString("userID"), c.Param(param))
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: This organization is outside your token's context"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// HasPermission indique si le token de la requête porte la permission
+func HasPermission(c *gin.Context, permission string) bool {
+	for _, granted := range c.GetStringSlice("permissions") {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminKeyMiddleware protège les routes d'administration par une clé partagée
+// transmise dans l'en-tête X-Admin-Key. Sans clé configurée, ces routes sont fermées.
+func AdminKeyMiddleware(adminKey string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := c.GetHeader("X-Admin-Key")
+		if adminKey == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(adminKey)) != 1 {
+			log.Printf("❌ Accès administrateur refusé pour %s", c.ClientIP())
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: Invalid admin key"})
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
 }
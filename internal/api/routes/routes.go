@@ -1,41 +1,145 @@
 package routes
 
 import (
+	"log"
+
 	"github.com/amirtalbi/examen_go/internal/api/handlers"
 	"github.com/amirtalbi/examen_go/internal/api/middleware"
 	"github.com/amirtalbi/examen_go/internal/config"
+	"github.com/amirtalbi/examen_go/internal/domain/models"
 	"github.com/amirtalbi/examen_go/internal/service"
+	"github.com/amirtalbi/examen_go/pkg/auth"
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter(cfg *config.Config, authService service.AuthService, userService service.UserService) *gin.Engine {
+func SetupRouter(cfg *config.Config, keys *auth.KeyRing, authService service.AuthService, userService service.UserService, sessionService service.SessionService, oauthService service.OAuthService, mfaService service.MFAService, webauthnService service.WebAuthnService, passwordlessService service.PasswordlessService, lockoutService service.LockoutService, rbacService service.RBACService, userAdminService service.UserAdminService, organizationService service.OrganizationService) *gin.Engine {
 	router := gin.Default()
 
+	// Sans proxy de confiance, X-Forwarded-For est ignoré : sinon n'importe quel client
+	// choisirait l'adresse IP prise en compte par le blocage des connexions
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
 	router.Use(middleware.LoggerMiddleware())
 
 	authHandler := handlers.NewAuthHandler(authService)
 	userHandler := handlers.NewUserHandler(userService)
+	sessionHandler := handlers.NewSessionHandler(sessionService)
+	oauthHandler := handlers.NewOAuthHandler(authService, oauthService)
+	authorizationHandler := handlers.NewAuthorizationHandler(authService, oauthService)
+	clientHandler := handlers.NewClientHandler(oauthService)
+	mfaHandler := handlers.NewMFAHandler(mfaService)
+	webauthnHandler := handlers.NewWebAuthnHandler(webauthnService)
+	passwordlessHandler := handlers.NewPasswordlessHandler(passwordlessService)
+	lockoutHandler := handlers.NewLockoutHandler(lockoutService)
+	roleHandler := handlers.NewRoleHandler(rbacService)
+	adminUserHandler := handlers.NewAdminUserHandler(userAdminService)
+	organizationHandler := handlers.NewOrganizationHandler(organizationService, authService)
 	healthHandler := handlers.NewHealthHandler()
+	wellKnownHandler := handlers.NewWellKnownHandler(cfg, keys)
+	keyHandler := handlers.NewKeyHandler(keys)
 
 	apiGroup := router.Group("/" + cfg.APIPrefix)
 
 	apiGroup.GET("/health", healthHandler.Check)
+	apiGroup.GET("/.well-known/jwks.json", wellKnownHandler.JWKS)
+	apiGroup.GET("/.well-known/openid-configuration", wellKnownHandler.OpenIDConfiguration)
 
 	authRoutes := apiGroup.Group("/")
 	{
 		authRoutes.POST("/register", authHandler.Register)
 		authRoutes.POST("/login", authHandler.Login)
+		authRoutes.POST("/login/mfa", authHandler.LoginMFA)
+		authRoutes.POST("/login/webauthn/options", webauthnHandler.BeginLogin)
+		authRoutes.POST("/login/webauthn", webauthnHandler.FinishLogin)
+		authRoutes.POST("/login/magic-link", passwordlessHandler.SendMagicLink)
+		authRoutes.POST("/login/magic-link/verify", passwordlessHandler.LoginWithMagicLink)
+		authRoutes.POST("/login/email-code", passwordlessHandler.SendLoginCode)
+		authRoutes.POST("/login/email-code/verify", passwordlessHandler.LoginWithCode)
 		authRoutes.POST("/forgot-password", authHandler.ForgotPassword)
 		authRoutes.POST("/reset-password", authHandler.ResetPassword)
+		authRoutes.POST("/verify-email", authHandler.VerifyEmail)
+		authRoutes.POST("/verify-email/resend", authHandler.ResendVerification)
+		authRoutes.POST("/unlock-account", lockoutHandler.Unlock)
 		// Moved refresh endpoint outside of protected routes
 		authRoutes.POST("/refresh", authHandler.RefreshToken)
+		authRoutes.POST("/introspect", oauthHandler.Introspect)
+		authRoutes.POST("/revoke", oauthHandler.Revoke)
+		authRoutes.GET("/authorize", authorizationHandler.Authorize)
+		authRoutes.POST("/authorize", authorizationHandler.Login)
+		authRoutes.POST("/token", authorizationHandler.Token)
 	}
 
 	protected := apiGroup.Group("/")
-	protected.Use(middleware.AuthMiddleware(authService))
+	protected.Use(middleware.AuthMiddleware(authService), middleware.RequireUser())
 	{
 		protected.POST("/logout", authHandler.Logout)
 		protected.GET("/me", userHandler.GetProfile)
+		protected.PUT("/me/password", authHandler.ChangePassword)
+		protected.GET("/me/sessions", sessionHandler.List)
+		protected.DELETE("/me/sessions/:id", sessionHandler.Revoke)
+		protected.DELETE("/me/sessions", sessionHandler.RevokeAll)
+		protected.POST("/me/mfa/totp", mfaHandler.StartTOTP)
+		protected.POST("/me/mfa/totp/confirm", mfaHandler.ConfirmTOTP)
+		protected.DELETE("/me/mfa/totp", mfaHandler.DisableTOTP)
+		protected.POST("/me/webauthn/options", webauthnHandler.BeginRegistration)
+		protected.POST("/me/webauthn/credentials", webauthnHandler.FinishRegistration)
+		protected.GET("/me/webauthn/credentials", webauthnHandler.ListCredentials)
+		protected.DELETE("/me/webauthn/credentials/:id", webauthnHandler.DeleteCredential)
+		protected.GET("/userinfo", authorizationHandler.UserInfo)
+		protected.POST("/userinfo", authorizationHandler.UserInfo)
+		protected.GET("/me/organizations", organizationHandler.ListMine)
+		protected.POST("/me/organization", organizationHandler.Switch)
+
+		// Le catalogue des rôles et les rôles de l'instance ne se gèrent que dans son contexte ;
+		// dans une organisation, les rôles des membres passent par /organizations/:id/members
+		platform := middleware.RequirePlatformContext()
+		protected.GET("/permissions", middleware.RequirePermission(models.PermissionRolesRead), roleHandler.ListPermissions)
+		protected.GET("/roles", middleware.RequirePermission(models.PermissionRolesRead), roleHandler.ListRoles)
+		protected.POST("/roles", platform, middleware.RequirePermission(models.PermissionRolesWrite), roleHandler.CreateRole)
+		protected.PUT("/roles/:name/permissions", platform, middleware.RequirePermission(models.PermissionRolesWrite), roleHandler.UpdatePermissions)
+		protected.DELETE("/roles/:name", platform, middleware.RequirePermission(models.PermissionRolesWrite), roleHandler.DeleteRole)
+		protected.GET("/users/:id/roles", platform, middleware.RequirePermission(models.PermissionRolesRead), roleHandler.ListUserRoles)
+		protected.POST("/users/:id/roles", platform, middleware.RequirePermission(models.PermissionRolesWrite), roleHandler.AssignRole)
+		protected.DELETE("/users/:id/roles/:role", platform, middleware.RequirePermission(models.PermissionRolesWrite), roleHandler.UnassignRole)
+
+		// Un token d'organisation n'accède qu'à la sienne ; créer, supprimer ou peupler une
+		// organisation avec des comptes existants reste réservé à l'instance
+		ownOrganization := middleware.RequireOrganizationAccess("id")
+		protected.GET("/organizations", platform, middleware.RequirePermission(models.PermissionOrganizationsRead), organizationHandler.List)
+		protected.POST("/organizations", platform, middleware.RequirePermission(models.PermissionOrganizationsWrite), organizationHandler.Create)
+		protected.GET("/organizations/:id", ownOrganization, middleware.RequirePermission(models.PermissionOrganizationsRead), organizationHandler.Get)
+		protected.PATCH("/organizations/:id", ownOrganization, middleware.RequirePermission(models.PermissionOrganizationsWrite), organizationHandler.Update)
+		protected.DELETE("/organizations/:id", platform, middleware.RequirePermission(models.PermissionOrganizationsWrite), organizationHandler.Delete)
+		protected.GET("/organizations/:id/members", ownOrganization, middleware.RequirePermission(models.PermissionOrganizationsRead), organizationHandler.ListMembers)
+		protected.POST("/organizations/:id/members", platform, middleware.RequirePermission(models.PermissionOrganizationsWrite), organizationHandler.AddMember)
+		// Attribuer des rôles revient à accorder des permissions : organizations:write ne suffit pas
+		protected.PUT("/organizations/:id/members/:userId/roles", ownOrganization, middleware.RequirePermission(models.PermissionOrganizationsWrite), middleware.RequirePermission(models.PermissionRolesWrite), organizationHandler.UpdateMemberRoles)
+		protected.DELETE("/organizations/:id/members/:userId", ownOrganization, middleware.RequirePermission(models.PermissionOrganizationsWrite), organizationHandler.RemoveMember)
+	}
+
+	admin := apiGroup.Group("/admin")
+	admin.Use(middleware.AdminKeyMiddleware(cfg.AdminAPIKey))
+	{
+		admin.GET("/keys", keyHandler.List)
+		admin.POST("/keys/rotate", keyHandler.Rotate)
+		admin.GET("/clients", clientHandler.List)
+		admin.POST("/clients", clientHandler.Create)
+	}
+
+	// Gestion des comptes par les utilisateurs ayant les permissions users:read et users:write.
+	// Avec un token d'organisation, seuls les membres de celle-ci sont visibles.
+	adminUsers := apiGroup.Group("/admin/users")
+	adminUsers.Use(middleware.AuthMiddleware(authService), middleware.RequireUser())
+	{
+		adminUsers.GET("", middleware.RequirePermission(models.PermissionUsersRead), adminUserHandler.List)
+		adminUsers.GET("/:id", middleware.RequirePermission(models.PermissionUsersRead), adminUserHandler.Get)
+		adminUsers.PATCH("/:id", middleware.RequirePermission(models.PermissionUsersWrite), adminUserHandler.Update)
+		adminUsers.DELETE("/:id", middleware.RequirePermission(models.PermissionUsersWrite), adminUserHandler.Delete)
+		adminUsers.POST("/:id/password-reset", middleware.RequirePermission(models.PermissionUsersWrite), adminUserHandler.ForcePasswordReset)
+		adminUsers.POST("/:id/lock", middleware.RequirePermission(models.PermissionUsersWrite), adminUserHandler.Lock)
+		adminUsers.POST("/:id/unlock", middleware.RequirePermission(models.PermissionUsersWrite), adminUserHandler.Unlock)
 	}
 
 	return router
@@ -1,19 +1,47 @@
 package config
 
 import (
+	"fmt"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	ServerPort       string
-	JWTSecret        string
-	ResetTokenSecret string
-	TokenExpiryHours int
-	APIPrefix        string
-	Database         DatabaseConfig
+	ServerPort           string
+	PublicURL            string
+	TrustedProxies       []string
+	JWTSecret            string
+	JWTKeyID             string
+	JWTPrivateKeyPath    string
+	JWTPreviousSecrets   map[string]string
+	JWTPreviousKeyPaths  map[string]string
+	KeyEncryptionKey     string
+	JWTIssuer            string
+	JWTAudience          []string
+	JWTLeewaySeconds     int
+	AdminAPIKey          string
+	MFAIssuer            string
+	WebAuthnRPID         string
+	WebAuthnRPName       string
+	WebAuthnOrigins      []string
+	MagicLinkURL         string
+	ResetPasswordURL     string
+	VerifyEmailURL       string
+	UnlockAccountURL     string
+	RequireVerifiedEmail bool
+	LoginCodeSecret      string
+	TokenExpiryHours     int
+	APIPrefix            string
+	Database             DatabaseConfig
+	Mail                 MailConfig
+	PasswordHashing      PasswordHashingConfig
+	PasswordPolicy       PasswordPolicyConfig
+	Lockout              LockoutConfig
+	Tenancy              TenancyConfig
 }
 
 type DatabaseConfig struct {
@@ -24,15 +52,121 @@ type DatabaseConfig struct {
 	Name     string
 }
 
+// MailConfig décrit l'envoi des emails : "smtp" (Mailpit en local) ou "file" (fichiers .eml)
+type MailConfig struct {
+	Driver       string
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	FileDir      string
+}
+
+// PasswordHashingConfig règle le coût d'argon2id : mémoire en KiB, nombre de passes et de threads
+type PasswordHashingConfig struct {
+	Memory      int
+	Iterations  int
+	Parallelism int
+}
+
+// PasswordPolicyConfig décrit les exigences sur les nouveaux mots de passe.
+// DenylistPath pointe vers la liste des mots de passe courants et BreachCorpusPath vers le
+// fichier Pwned Passwords trié ou son index ; vides pour s'en passer.
+type PasswordPolicyConfig struct {
+	MinLength        int
+	MaxLength        int
+	RequiredClasses  int
+	MinStrength      int
+	DenylistPath     string
+	BreachCorpusPath string
+	BreachThreshold  int
+	// HistorySize est le nombre de mots de passe récents, l'actuel compris, qui ne peuvent être réutilisés
+	HistorySize int
+}
+
+// LockoutConfig règle le ralentissement puis le blocage après des connexions échouées.
+// Au-delà de BackoffThreshold échecs, chaque nouvel échec impose un délai qui double, de
+// BackoffBaseSeconds jusqu'à BackoffMaxSeconds. AccountThreshold échecs sur un compte, ou
+// IPThreshold depuis une même adresse, bloquent pendant DurationSeconds. Les échecs sont
+// oubliés WindowSeconds après le dernier. Un seuil à 0 désactive la règle correspondante.
+type LockoutConfig struct {
+	BackoffThreshold   int
+	BackoffBaseSeconds int
+	BackoffMaxSeconds  int
+	AccountThreshold   int
+	IPThreshold        int
+	DurationSeconds    int
+	WindowSeconds      int
+}
+
+// Valeurs de TenancyConfig.EmailUniqueness
+const (
+	// Une adresse email ne désigne qu'un seul compte sur toute l'instance
+	EmailUniquenessGlobal = "global"
+	// Chaque organisation a ses propres comptes : une même adresse peut y exister une fois par organisation
+	EmailUniquenessTenant = "tenant"
+)
+
+// TenancyConfig règle l'isolation des organisations hébergées sur l'instance
+type TenancyConfig struct {
+	EmailUniqueness string
+}
+
+// TenantScopedEmails indique si les comptes créés dans une organisation lui appartiennent
+func (c TenancyConfig) TenantScopedEmails() bool {
+	return c.EmailUniqueness == EmailUniquenessTenant
+}
+
+// Validate refuse un mode d'unicité inconnu, qui serait sinon traité comme global
+func (c TenancyConfig) Validate() error {
+	if c.EmailUniqueness != EmailUniquenessGlobal && c.EmailUniqueness != EmailUniquenessTenant {
+		return fmt.Errorf("invalid TENANT_EMAIL_UNIQUENESS %q: expected %q or %q", c.EmailUniqueness, EmailUniquenessGlobal, EmailUniquenessTenant)
+	}
+	return nil
+}
+
+// ValidateOIDCIssuer exige que JWT_ISSUER soit l'URL https sous laquelle le document de
+// découverte est servi : les clients OpenID Connect comparent iss à cette URL
+func (c *Config) ValidateOIDCIssuer() error {
+	issuer, err := url.Parse(c.JWTIssuer)
+	if err != nil || issuer.Scheme != "https" || issuer.Host == "" || issuer.RawQuery != "" || issuer.Fragment != "" {
+		return fmt.Errorf("invalid JWT_ISSUER %q: OpenID Connect requires an https URL without query or fragment", c.JWTIssuer)
+	}
+	return nil
+}
+
 func Load() *Config {
 	_ = godotenv.Load()
 
+	publicURL := strings.TrimSuffix(getEnv("PUBLIC_URL", "http://localhost:"+getEnv("SERVER_PORT", "8080")), "/")
+
 	return &Config{
-		ServerPort:       getEnv("SERVER_PORT", "8080"),
-		JWTSecret:        getEnv("JWT_SECRET", "your-secret-key"),
-		ResetTokenSecret: getEnv("RESET_TOKEN_SECRET", "reset-token-secret-key"),
-		TokenExpiryHours: getEnvAsInt("TOKEN_EXPIRY_HOURS", 24),
-		APIPrefix:        getEnv("API_PREFIX", "4efb0957-d14e-437f-8f01-a8db9f47405b"),
+		ServerPort:           getEnv("SERVER_PORT", "8080"),
+		PublicURL:            publicURL,
+		TrustedProxies:       getEnvAsList("TRUSTED_PROXIES", nil),
+		JWTSecret:            getEnv("JWT_SECRET", "your-secret-key"),
+		JWTKeyID:             getEnv("JWT_KEY_ID", "default"),
+		JWTPrivateKeyPath:    getEnv("JWT_PRIVATE_KEY_PATH", ""),
+		JWTPreviousSecrets:   getEnvAsMap("JWT_PREVIOUS_SECRETS"),
+		JWTPreviousKeyPaths:  getEnvAsMap("JWT_PREVIOUS_KEY_PATHS"),
+		KeyEncryptionKey:     getEnv("SIGNING_KEY_ENCRYPTION_KEY", ""),
+		JWTIssuer:            getEnv("JWT_ISSUER", "examen_go"),
+		JWTAudience:          getEnvAsList("JWT_AUDIENCE", []string{"examen_go_api"}),
+		JWTLeewaySeconds:     getEnvAsInt("JWT_LEEWAY_SECONDS", 30),
+		AdminAPIKey:          getEnv("ADMIN_API_KEY", ""),
+		MFAIssuer:            getEnv("MFA_ISSUER", "examen_go"),
+		WebAuthnRPID:         getEnv("WEBAUTHN_RP_ID", "localhost"),
+		WebAuthnRPName:       getEnv("WEBAUTHN_RP_NAME", "examen_go"),
+		WebAuthnOrigins:      getEnvAsList("WEBAUTHN_ORIGINS", []string{publicURL}),
+		MagicLinkURL:         getEnv("MAGIC_LINK_URL", publicURL+"/magic-link"),
+		ResetPasswordURL:     getEnv("RESET_PASSWORD_URL", publicURL+"/reset-password"),
+		VerifyEmailURL:       getEnv("VERIFY_EMAIL_URL", publicURL+"/verify-email"),
+		UnlockAccountURL:     getEnv("UNLOCK_ACCOUNT_URL", publicURL+"/unlock-account"),
+		RequireVerifiedEmail: getEnvAsBool("REQUIRE_VERIFIED_EMAIL", false),
+		LoginCodeSecret:      getEnv("LOGIN_CODE_SECRET", "login-code-secret-key"),
+		TokenExpiryHours:     getEnvAsInt("TOKEN_EXPIRY_HOURS", 24),
+		APIPrefix:            getEnv("API_PREFIX", "4efb0957-d14e-437f-8f01-a8db9f47405b"),
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
 			Port:     getEnv("DB_PORT", "5432"),
@@ -40,6 +174,42 @@ func Load() *Config {
 			Password: getEnv("DB_PASSWORD", "postgres"),
 			Name:     getEnv("DB_NAME", "examen_go"),
 		},
+		Mail: MailConfig{
+			Driver:       getEnv("MAIL_DRIVER", "smtp"),
+			From:         getEnv("MAIL_FROM", "examen_go <no-reply@examen-go.local>"),
+			SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+			SMTPPort:     getEnv("SMTP_PORT", "1025"),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			FileDir:      getEnv("MAIL_FILE_DIR", "mail"),
+		},
+		PasswordHashing: PasswordHashingConfig{
+			Memory:      getEnvAsInt("ARGON2_MEMORY_KIB", 64*1024),
+			Iterations:  getEnvAsInt("ARGON2_ITERATIONS", 3),
+			Parallelism: getEnvAsInt("ARGON2_PARALLELISM", 4),
+		},
+		PasswordPolicy: PasswordPolicyConfig{
+			MinLength:        getEnvAsInt("PASSWORD_MIN_LENGTH", 8),
+			MaxLength:        getEnvAsInt("PASSWORD_MAX_LENGTH", 128),
+			RequiredClasses:  getEnvAsInt("PASSWORD_REQUIRED_CLASSES", 0),
+			MinStrength:      getEnvAsInt("PASSWORD_MIN_STRENGTH", 2),
+			DenylistPath:     getEnv("PASSWORD_DENYLIST_PATH", "data/common-passwords.txt"),
+			BreachCorpusPath: getEnv("PASSWORD_BREACH_CORPUS_PATH", ""),
+			BreachThreshold:  getEnvAsInt("PASSWORD_BREACH_THRESHOLD", 1),
+			HistorySize:      getEnvAsInt("PASSWORD_HISTORY_SIZE", 5),
+		},
+		Lockout: LockoutConfig{
+			BackoffThreshold:   getEnvAsInt("LOGIN_BACKOFF_THRESHOLD", 3),
+			BackoffBaseSeconds: getEnvAsInt("LOGIN_BACKOFF_BASE_SECONDS", 1),
+			BackoffMaxSeconds:  getEnvAsInt("LOGIN_BACKOFF_MAX_SECONDS", 60),
+			AccountThreshold:   getEnvAsInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+			IPThreshold:        getEnvAsInt("LOGIN_IP_LOCKOUT_THRESHOLD", 100),
+			DurationSeconds:    getEnvAsInt("LOGIN_LOCKOUT_SECONDS", 15*60),
+			WindowSeconds:      getEnvAsInt("LOGIN_FAILURE_WINDOW_SECONDS", 60*60),
+		},
+		Tenancy: TenancyConfig{
+			EmailUniqueness: getEnv("TENANT_EMAIL_UNIQUENESS", EmailUniquenessGlobal),
+		},
 	}
 }
 
@@ -57,3 +227,36 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(getEnv(key, "")); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvAsList lit une liste de valeurs séparées par des virgules
+func getEnvAsList(key string, defaultValue []string) []string {
+	var result []string
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
+		}
+	}
+	if len(result) == 0 {
+		return defaultValue
+	}
+	return result
+}
+
+// getEnvAsMap lit une liste de paires cle:valeur séparées par des virgules
+func getEnvAsMap(key string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(getEnv(key, ""), ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(pair), ":")
+		if found && name != "" {
+			result[name] = value
+		}
+	}
+	return result
+}
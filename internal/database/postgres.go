@@ -10,6 +10,11 @@ import (
 )
 
 func NewPostgresConnection(cfg *config.Config) (*sqlx.DB, error) {
+	// Le mode d'unicité des emails détermine les index créés par initSchema
+	if err := cfg.Tenancy.Validate(); err != nil {
+		return nil, err
+	}
+
 	// First try to connect to the database
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		cfg.Database.Host, cfg.Database.Port, cfg.Database.User, cfg.Database.Password, cfg.Database.Name)
@@ -60,7 +65,7 @@ func NewPostgresConnection(cfg *config.Config) (*sqlx.DB, error) {
 	}
 
 	// Initialize the schema
-	err = initSchema(db)
+	err = initSchema(db, cfg.Tenancy)
 	if err != nil {
 		log.Printf("Error initializing schema: %v", err)
 		return nil, err
@@ -70,8 +75,16 @@ func NewPostgresConnection(cfg *config.Config) (*sqlx.DB, error) {
 	return db, nil
 }
 
-func initSchema(db *sqlx.DB) error {
+func initSchema(db *sqlx.DB, tenancy config.TenancyConfig) error {
 	schema := `
+    CREATE TABLE IF NOT EXISTS organizations (
+        id UUID PRIMARY KEY,
+        slug VARCHAR(50) UNIQUE NOT NULL,
+        name TEXT NOT NULL,
+        settings JSONB NOT NULL DEFAULT '{}',
+        created_at TIMESTAMP NOT NULL
+    );
+
     CREATE TABLE IF NOT EXISTS users (
         id UUID PRIMARY KEY,
         name TEXT NOT NULL,
@@ -79,11 +92,207 @@ func initSchema(db *sqlx.DB) error {
         password TEXT NOT NULL,
         reset_token TEXT,
         reset_token_expires TIMESTAMP,
+        email_verified_at TIMESTAMP,
+        locked_at TIMESTAMP,
+        must_reset_password BOOLEAN NOT NULL DEFAULT FALSE,
         created_at TIMESTAMP NOT NULL,
+        updated_at TIMESTAMP NOT NULL,
+        org_id UUID REFERENCES organizations(id) ON DELETE CASCADE
+    );
+    ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP;
+    ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_at TIMESTAMP;
+    ALTER TABLE users ADD COLUMN IF NOT EXISTS must_reset_password BOOLEAN NOT NULL DEFAULT FALSE;
+    ALTER TABLE users ADD COLUMN IF NOT EXISTS org_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+    CREATE INDEX IF NOT EXISTS idx_users_created_at ON users (created_at, id);
+
+    CREATE TABLE IF NOT EXISTS organization_members (
+        org_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+        user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        roles TEXT[] NOT NULL DEFAULT '{}',
+        created_at TIMESTAMP NOT NULL,
+        PRIMARY KEY (org_id, user_id)
+    );
+    CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members (user_id);
+
+    CREATE TABLE IF NOT EXISTS revoked_tokens (
+        jti TEXT PRIMARY KEY,
+        expires_at TIMESTAMP NOT NULL,
+        revoked_at TIMESTAMP NOT NULL
+    );
+    CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
+
+    CREATE TABLE IF NOT EXISTS refresh_tokens (
+        id TEXT PRIMARY KEY,
+        family_id TEXT NOT NULL,
+        user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        expires_at TIMESTAMP NOT NULL,
+        rotated_at TIMESTAMP,
+        revoked_at TIMESTAMP,
+        created_at TIMESTAMP NOT NULL
+    );
+    CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+
+    CREATE TABLE IF NOT EXISTS sessions (
+        id TEXT PRIMARY KEY,
+        user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        device_name TEXT NOT NULL DEFAULT '',
+        user_agent TEXT NOT NULL DEFAULT '',
+        ip_address TEXT NOT NULL DEFAULT '',
+        client_id TEXT NOT NULL DEFAULT '',
+        scope TEXT NOT NULL DEFAULT '',
+        created_at TIMESTAMP NOT NULL,
+        last_seen_at TIMESTAMP NOT NULL,
+        revoked_at TIMESTAMP,
+        org_id TEXT NOT NULL DEFAULT ''
+    );
+    CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+    CREATE INDEX IF NOT EXISTS idx_sessions_last_seen_at ON sessions (last_seen_at);
+    ALTER TABLE sessions ADD COLUMN IF NOT EXISTS client_id TEXT NOT NULL DEFAULT '';
+    ALTER TABLE sessions ADD COLUMN IF NOT EXISTS scope TEXT NOT NULL DEFAULT '';
+    ALTER TABLE sessions ADD COLUMN IF NOT EXISTS org_id TEXT NOT NULL DEFAULT '';
+
+    CREATE TABLE IF NOT EXISTS oauth_clients (
+        id TEXT PRIMARY KEY,
+        name TEXT NOT NULL,
+        secret_hash TEXT,
+        redirect_uris TEXT[] NOT NULL DEFAULT '{}',
+        grant_types TEXT[] NOT NULL DEFAULT '{}',
+        scopes TEXT[] NOT NULL DEFAULT '{}',
+        created_at TIMESTAMP NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS authorization_codes (
+        code_hash TEXT PRIMARY KEY,
+        client_id TEXT NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+        user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        redirect_uri TEXT NOT NULL,
+        scope TEXT NOT NULL DEFAULT '',
+        code_challenge TEXT NOT NULL,
+        code_challenge_method TEXT NOT NULL,
+        nonce TEXT NOT NULL DEFAULT '',
+        auth_time TIMESTAMP NOT NULL DEFAULT NOW(),
+        session_id TEXT,
+        expires_at TIMESTAMP NOT NULL,
+        used_at TIMESTAMP,
+        created_at TIMESTAMP NOT NULL
+    );
+    CREATE INDEX IF NOT EXISTS idx_authorization_codes_expires_at ON authorization_codes (expires_at);
+    ALTER TABLE authorization_codes ADD COLUMN IF NOT EXISTS nonce TEXT NOT NULL DEFAULT '';
+    ALTER TABLE authorization_codes ADD COLUMN IF NOT EXISTS auth_time TIMESTAMP NOT NULL DEFAULT NOW();
+
+    CREATE TABLE IF NOT EXISTS mfa_totp (
+        user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+        secret TEXT NOT NULL,
+        confirmed_at TIMESTAMP,
+        last_used_step BIGINT NOT NULL DEFAULT 0,
+        created_at TIMESTAMP NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+        id TEXT PRIMARY KEY,
+        user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        code_hash TEXT NOT NULL,
+        used_at TIMESTAMP,
+        created_at TIMESTAMP NOT NULL
+    );
+    CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes (user_id);
+
+    CREATE TABLE IF NOT EXISTS webauthn_credentials (
+        id TEXT PRIMARY KEY,
+        user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        name TEXT NOT NULL DEFAULT '',
+        public_key BYTEA NOT NULL,
+        algorithm BIGINT NOT NULL,
+        sign_count BIGINT NOT NULL DEFAULT 0,
+        aaguid TEXT NOT NULL DEFAULT '',
+        transports TEXT[] NOT NULL DEFAULT '{}',
+        attestation_format TEXT NOT NULL,
+        backup_eligible BOOLEAN NOT NULL DEFAULT FALSE,
+        backup_state BOOLEAN NOT NULL DEFAULT FALSE,
+        created_at TIMESTAMP NOT NULL,
+        last_used_at TIMESTAMP
+    );
+    CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_id ON webauthn_credentials (user_id);
+
+    CREATE TABLE IF NOT EXISTS email_login_codes (
+        user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+        code_hash TEXT NOT NULL,
+        attempts INTEGER NOT NULL DEFAULT 0,
+        expires_at TIMESTAMP NOT NULL,
+        created_at TIMESTAMP NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS password_history (
+        id UUID PRIMARY KEY,
+        user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        password_hash TEXT NOT NULL,
+        created_at TIMESTAMP NOT NULL
+    );
+    CREATE INDEX IF NOT EXISTS idx_password_history_user_id ON password_history (user_id, created_at);
+
+    CREATE TABLE IF NOT EXISTS login_attempts (
+        key VARCHAR(320) PRIMARY KEY,
+        failures INTEGER NOT NULL DEFAULT 0,
+        last_failure_at TIMESTAMP NOT NULL,
+        locked_until TIMESTAMP,
+        expires_at TIMESTAMP NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS permissions (
+        name VARCHAR(100) PRIMARY KEY,
+        description TEXT NOT NULL DEFAULT ''
+    );
+
+    CREATE TABLE IF NOT EXISTS roles (
+        id UUID PRIMARY KEY,
+        name VARCHAR(100) UNIQUE NOT NULL,
+        description TEXT NOT NULL DEFAULT '',
+        created_at TIMESTAMP NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS role_permissions (
+        role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+        permission VARCHAR(100) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+        PRIMARY KEY (role_id, permission)
+    );
+
+    CREATE TABLE IF NOT EXISTS user_roles (
+        user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        role_id UUID NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+        created_at TIMESTAMP NOT NULL,
+        PRIMARY KEY (user_id, role_id)
+    );
+    CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON user_roles (role_id);
+
+    CREATE TABLE IF NOT EXISTS signing_keys (
+        kid TEXT PRIMARY KEY,
+        alg VARCHAR(10) NOT NULL,
+        material BYTEA NOT NULL,
+        state VARCHAR(20) NOT NULL,
+        activates_at TIMESTAMP NOT NULL,
+        retires_at TIMESTAMP,
         updated_at TIMESTAMP NOT NULL
     );
     `
+	schema += emailUniquenessSchema(tenancy)
 
 	_, err := db.Exec(schema)
 	return err
 }
+
+// emailUniquenessSchema remplace l'unicité de l'email sur toute l'instance par une unicité par
+// organisation propriétaire (les comptes de l'instance formant un groupe), ou l'inverse.
+// Revenir à l'unicité globale échoue si une adresse est déjà utilisée dans deux organisations.
+func emailUniquenessSchema(tenancy config.TenancyConfig) string {
+	if tenancy.TenantScopedEmails() {
+		return `
+    ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+    DROP INDEX IF EXISTS users_email_key;
+    CREATE UNIQUE INDEX IF NOT EXISTS idx_users_org_email ON users ((COALESCE(org_id::text, '')), email);
+    `
+	}
+	return `
+    DROP INDEX IF EXISTS idx_users_org_email;
+    CREATE UNIQUE INDEX IF NOT EXISTS users_email_key ON users (email);
+    `
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// LoginAttempts compte les connexions échouées d'un compte ou d'une adresse IP.
+// Key vaut "account:<email>" ou "ip:<adresse>".
+type LoginAttempts struct {
+	Key           string     `json:"key" db:"key"`
+	Failures      int        `json:"failures" db:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at" db:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty" db:"locked_until"`
+	// ExpiresAt est la fin de la fenêtre de comptage, ou du blocage s'il dure plus longtemps
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// TOTPCredential est le secret TOTP d'un utilisateur. Tant que ConfirmedAt est nil,
+// l'enrôlement n'est pas terminé et le second facteur n'est pas exigé.
+type TOTPCredential struct {
+	UserID      string     `db:"user_id"`
+	Secret      string     `db:"secret"`
+	ConfirmedAt *time.Time `db:"confirmed_at"`
+	// Dernier pas de temps accepté, pour refuser qu'un code soit rejoué
+	LastUsedStep int64     `db:"last_used_step"`
+	CreatedAt    time.Time `db:"created_at"`
+}
+
+// TOTPEnrollmentResponse contient le secret à enregistrer dans l'application d'authentification
+type TOTPEnrollmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// MFACodeRequest porte un code TOTP ou un code de récupération
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// RecoveryCodesResponse contient les codes de récupération, affichés une seule fois
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAChallengeResponse est retournée par /login à la place des tokens lorsque le second facteur est requis
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// MFALoginRequest termine une connexion en deux étapes
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+	ClientInfo
+}
@@ -0,0 +1,22 @@
+package models
+
+// Types de tokens au sens des RFC 7009 et 7662
+const (
+	TokenTypeAccess  = "access_token"
+	TokenTypeRefresh = "refresh_token"
+)
+
+// IntrospectionResponse est la réponse de l'endpoint d'introspection (RFC 7662).
+// Un token inactif est représenté uniquement par {"active": false}.
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Subject   string `json:"sub,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	TokenID   string `json:"jti,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	OrgID     string `json:"org_id,omitempty"`
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Grants OAuth 2.0 supportés
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+)
+
+// OAuthClient est une application enregistrée auprès du serveur d'autorisation.
+// Un client public (SPA, mobile) n'a pas de secret et doit utiliser PKCE.
+// Un client machine (tâche de fond, service) utilise client_credentials avec son secret.
+type OAuthClient struct {
+	ID           string         `json:"client_id" db:"id"`
+	Name         string         `json:"name" db:"name"`
+	SecretHash   *string        `json:"-" db:"secret_hash"`
+	RedirectURIs pq.StringArray `json:"redirect_uris" db:"redirect_uris"`
+	GrantTypes   pq.StringArray `json:"grant_types" db:"grant_types"`
+	Scopes       pq.StringArray `json:"scopes" db:"scopes"`
+	CreatedAt    time.Time      `json:"created_at" db:"created_at"`
+}
+
+// IsConfidential indique si le client doit s'authentifier avec un secret
+func (c *OAuthClient) IsConfidential() bool {
+	return c.SecretHash != nil
+}
+
+// AllowsGrant indique si le client est autorisé à utiliser le grant donné
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	for _, allowed := range c.GrantTypes {
+		if allowed == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsRedirectURI compare l'URI de redirection exactement, sans normalisation
+func (c *OAuthClient) AllowsRedirectURI(redirectURI string) bool {
+	for _, allowed := range c.RedirectURIs {
+		if allowed == redirectURI {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthorizationCode est un code d'autorisation à usage unique. Seule son empreinte est stockée.
+type AuthorizationCode struct {
+	CodeHash            string `db:"code_hash"`
+	ClientID            string `db:"client_id"`
+	UserID              string `db:"user_id"`
+	RedirectURI         string `db:"redirect_uri"`
+	Scope               string `db:"scope"`
+	CodeChallenge       string `db:"code_challenge"`
+	CodeChallengeMethod string `db:"code_challenge_method"`
+	// Nonce OpenID Connect et date de connexion, reportés dans l'ID token
+	Nonce    string    `db:"nonce"`
+	AuthTime time.Time `db:"auth_time"`
+	// Session ouverte lors de l'échange, révoquée si le code est rejoué
+	SessionID *string    `db:"session_id"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+type RegisterClientRequest struct {
+	Name         string   `json:"name" binding:"required"`
+	RedirectURIs []string `json:"redirect_uris"`
+	GrantTypes   []string `json:"grant_types" binding:"required,min=1"`
+	Scopes       []string `json:"scopes"`
+	// Un client confidentiel reçoit un secret, retourné une seule fois
+	Confidential bool `json:"confidential"`
+}
+
+type RegisterClientResponse struct {
+	OAuthClient
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// AuthorizeRequest reprend les paramètres de l'endpoint d'autorisation (RFC 6749 section 4.1.1, RFC 7636)
+type AuthorizeRequest struct {
+	ResponseType        string `form:"response_type"`
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+	Nonce               string `form:"nonce"`
+	// Organisation dans laquelle l'email désigne le compte, vide pour l'instance
+	OrgID string `form:"org_id"`
+}
+
+// TokenRequest reprend les paramètres de l'endpoint de token (RFC 6749 sections 4.1.3 et 6)
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// TokenResponse est la réponse de l'endpoint de token (RFC 6749 section 5.1)
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Organization est un client hébergé sur l'instance. Ses membres se connectent dans son
+// contexte (claim org_id) et y ont leurs propres rôles.
+type Organization struct {
+	ID        string               `json:"id" db:"id"`
+	Slug      string               `json:"slug" db:"slug"`
+	Name      string               `json:"name" db:"name"`
+	Settings  OrganizationSettings `json:"settings" db:"settings"`
+	CreatedAt time.Time            `json:"created_at" db:"created_at"`
+}
+
+// OrganizationSettings remplace la configuration de l'instance pour l'organisation.
+// Un champ absent garde la valeur de l'instance.
+type OrganizationSettings struct {
+	// Durée de validité des tokens d'accès émis dans le contexte de l'organisation
+	TokenExpiryHours *int `json:"token_expiry_hours,omitempty"`
+	// Politique des mots de passe des comptes de l'organisation
+	PasswordMinLength       *int `json:"password_min_length,omitempty"`
+	PasswordRequiredClasses *int `json:"password_required_classes,omitempty"`
+	PasswordMinStrength     *int `json:"password_min_strength,omitempty"`
+	PasswordHistorySize     *int `json:"password_history_size,omitempty"`
+	// Inscription publique par /register. Désactivée par défaut : les membres sont
+	// sinon ajoutés par un administrateur de l'organisation.
+	AllowSelfSignup bool `json:"allow_self_signup,omitempty"`
+}
+
+// Value enregistre les paramètres en JSONB
+func (s OrganizationSettings) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+func (s *OrganizationSettings) Scan(value interface{}) error {
+	switch raw := value.(type) {
+	case []byte:
+		return json.Unmarshal(raw, s)
+	case string:
+		return json.Unmarshal([]byte(raw), s)
+	case nil:
+		*s = OrganizationSettings{}
+		return nil
+	default:
+		return errors.New("invalid organization settings")
+	}
+}
+
+// Membership rattache un utilisateur à une organisation, avec ses rôles dans celle-ci.
+// Les rôles sont ceux du catalogue de l'instance, désignés par leur nom.
+type Membership struct {
+	OrgID     string         `json:"org_id" db:"org_id"`
+	UserID    string         `json:"user_id" db:"user_id"`
+	Roles     pq.StringArray `json:"roles" db:"roles"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+}
+
+type CreateOrganizationRequest struct {
+	Slug     string               `json:"slug" binding:"required"`
+	Name     string               `json:"name" binding:"required"`
+	Settings OrganizationSettings `json:"settings"`
+}
+
+// UpdateOrganizationRequest ne modifie que les champs présents ; Settings remplace tous les paramètres
+type UpdateOrganizationRequest struct {
+	Name     *string               `json:"name" binding:"omitempty,min=1"`
+	Settings *OrganizationSettings `json:"settings"`
+}
+
+type AddMemberRequest struct {
+	UserID string   `json:"user_id" binding:"required"`
+	Roles  []string `json:"roles"`
+}
+
+type UpdateMemberRolesRequest struct {
+	Roles []string `json:"roles" binding:"required"`
+}
+
+// SwitchOrganizationRequest ouvre une session dans l'organisation org_id ; vide pour revenir au
+// contexte de l'instance
+type SwitchOrganizationRequest struct {
+	ClientInfo
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// EmailLoginCode est le code de connexion à usage unique envoyé par email.
+// Seule son empreinte est stockée, avec le nombre d'essais déjà consommés.
+type EmailLoginCode struct {
+	UserID    string    `db:"user_id"`
+	CodeHash  string    `db:"code_hash"`
+	Attempts  int       `db:"attempts"`
+	ExpiresAt time.Time `db:"expires_at"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// PasswordlessRequest demande l'envoi d'un lien magique ou d'un code de connexion
+type PasswordlessRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	OrgID string `json:"org_id"`
+}
+
+type MagicLinkLoginRequest struct {
+	Token string `json:"token" binding:"required"`
+	ClientInfo
+}
+
+type EmailCodeLoginRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Code  string `json:"code" binding:"required"`
+	ClientInfo
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken est l'enregistrement serveur d'un refresh token émis.
+// Tous les tokens issus d'une même connexion partagent le même FamilyID.
+type RefreshToken struct {
+	ID        string     `json:"id" db:"id"`
+	FamilyID  string     `json:"family_id" db:"family_id"`
+	UserID    string     `json:"user_id" db:"user_id"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at" db:"rotated_at"`
+	RevokedAt *time.Time `json:"revoked_at" db:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
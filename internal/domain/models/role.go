@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Permissions connues de l'application, au format "<ressource>:<action>"
+const (
+	PermissionUsersRead  = "users:read"
+	PermissionUsersWrite = "users:write"
+	PermissionRolesRead  = "roles:read"
+	PermissionRolesWrite = "roles:write"
+	// Un token émis dans le contexte d'une organisation ne les exerce que sur celle-ci
+	PermissionOrganizationsRead  = "organizations:read"
+	PermissionOrganizationsWrite = "organizations:write"
+)
+
+// RoleAdmin a toutes les permissions. Il est créé au démarrage et ne peut être ni modifié
+// ni supprimé.
+const RoleAdmin = "admin"
+
+// Permission est un droit qu'un rôle peut accorder
+type Permission struct {
+	Name        string `json:"name" db:"name"`
+	Description string `json:"description" db:"description"`
+}
+
+// DefaultPermissions est le catalogue des permissions enregistré au démarrage
+var DefaultPermissions = []Permission{
+	{Name: PermissionUsersRead, Description: "List and view user accounts"},
+	{Name: PermissionUsersWrite, Description: "Create, update, disable and delete user accounts"},
+	{Name: PermissionRolesRead, Description: "List roles, permissions and role assignments"},
+	{Name: PermissionRolesWrite, Description: "Create, update and delete roles and assign them to users"},
+	{Name: PermissionOrganizationsRead, Description: "List organizations, their settings and members"},
+	{Name: PermissionOrganizationsWrite, Description: "Create, update and delete organizations and manage their members"},
+}
+
+type Role struct {
+	ID          string         `json:"id" db:"id"`
+	Name        string         `json:"name" db:"name"`
+	Description string         `json:"description" db:"description"`
+	Permissions pq.StringArray `json:"permissions" db:"permissions"`
+	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
+}
+
+type CreateRoleRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type UpdateRolePermissionsRequest struct {
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+type AssignRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// Session représente une connexion d'un utilisateur depuis un appareil.
+// Son identifiant est porté par les tokens (claim sid) et sert de famille aux refresh tokens.
+type Session struct {
+	ID         string     `json:"id" db:"id"`
+	UserID     string     `json:"-" db:"user_id"`
+	DeviceName string     `json:"device_name" db:"device_name"`
+	UserAgent  string     `json:"user_agent" db:"user_agent"`
+	IPAddress  string     `json:"ip_address" db:"ip_address"`
+	ClientID   string     `json:"client_id,omitempty" db:"client_id"`
+	Scope      string     `json:"scope,omitempty" db:"scope"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at" db:"last_seen_at"`
+	RevokedAt  *time.Time `json:"-" db:"revoked_at"`
+	Current    bool       `json:"current" db:"-"`
+	// Organisation choisie à la connexion ou par changement d'organisation
+	OrgID string `json:"org_id,omitempty" db:"org_id"`
+}
@@ -11,32 +11,77 @@ type User struct {
 	Password          string     `json:"-" db:"password"`
 	ResetToken        *string    `json:"-" db:"reset_token"`
 	ResetTokenExpires *time.Time `json:"-" db:"reset_token_expires"`
+	EmailVerifiedAt   *time.Time `json:"email_verified_at" db:"email_verified_at"`
+	LockedAt          *time.Time `json:"locked_at" db:"locked_at"`
+	MustResetPassword bool       `json:"must_reset_password" db:"must_reset_password"`
 	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at"`
+	// OrgID est l'organisation propriétaire du compte, nil pour un compte de l'instance
+	OrgID *string `json:"org_id,omitempty" db:"org_id"`
+}
+
+// ClientInfo décrit l'appareil à l'origine d'une connexion.
+// Seul le nom de l'appareil vient du corps de la requête, le reste est renseigné par le handler.
+type ClientInfo struct {
+	DeviceName string `json:"device_name"`
+	UserAgent  string `json:"-"`
+	IPAddress  string `json:"-"`
+	// Client OAuth et scope accordé, pour les sessions ouvertes via /authorize
+	ClientID string `json:"-"`
+	Scope    string `json:"-"`
+	// Organisation dans le contexte de laquelle ouvrir la session, vide pour l'instance
+	OrgID string `json:"org_id"`
 }
 
 type RegisterRequest struct {
 	Name     string `json:"name" binding:"required"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
+	ClientInfo
 }
 
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	ClientInfo
 }
 
 type ForgotPasswordRequest struct {
 	Email string `json:"email" binding:"required,email"`
+	OrgID string `json:"org_id"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+	IPAddress       string `json:"-"`
+}
+
+type UnlockAccountRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	OrgID string `json:"org_id"`
 }
 
 type ResetPasswordRequest struct {
 	Token       string `json:"token" binding:"required"`
-	NewPassword string `json:"new_password" binding:"required,min=6"`
+	NewPassword string `json:"new_password" binding:"required"`
 }
 
 type AuthResponse struct {
 	Token        string `json:"token"`
 	RefreshToken string `json:"refreshToken"`
 	User         User   `json:"user"`
+	// Session et scope des tokens émis, utilisés par le serveur d'autorisation OAuth
+	SessionID string `json:"-"`
+	Scope     string `json:"-"`
+	// Durée de vie du token d'accès en secondes, qui peut dépendre de l'organisation
+	ExpiresIn int `json:"-"`
 }
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+)
+
+// ListUsersQuery contient les filtres de GET /admin/users
+type ListUsersQuery struct {
+	EmailPrefix   string     `form:"email_prefix"`
+	CreatedAfter  *time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore *time.Time `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	Verified      *bool      `form:"verified"`
+	Locked        *bool      `form:"locked"`
+	Limit         int        `form:"limit"`
+	Cursor        string     `form:"cursor"`
+}
+
+// UserCursor est la position du dernier utilisateur d'une page
+type UserCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// UserFilter sélectionne une page d'utilisateurs, du plus récent au plus ancien
+type UserFilter struct {
+	EmailPrefix   string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Verified      *bool
+	// Locked ne concerne que le verrouillage par un administrateur, pas le blocage
+	// temporaire après des connexions échouées
+	Locked *bool
+	// After est nil pour la première page
+	After *UserCursor
+	Limit int
+}
+
+// AdminUser est un utilisateur tel que vu par l'administration, avec ses rôles
+type AdminUser struct {
+	User
+	Roles []string `json:"roles"`
+}
+
+type UserPage struct {
+	Users []AdminUser `json:"users"`
+	// NextCursor est vide sur la dernière page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// UpdateUserRequest ne modifie que les champs présents
+type UpdateUserRequest struct {
+	Name  *string `json:"name" binding:"omitempty,min=1"`
+	Email *string `json:"email" binding:"omitempty,email"`
+	// Roles remplace tous les rôles de l'utilisateur
+	Roles []string `json:"roles"`
+}
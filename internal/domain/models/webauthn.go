@@ -0,0 +1,129 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// WebAuthnCredential est une clé d'accès (passkey) enregistrée par un utilisateur.
+// L'identifiant est celui choisi par l'authentificateur, encodé en base64url.
+type WebAuthnCredential struct {
+	ID     string `json:"id" db:"id"`
+	UserID string `json:"-" db:"user_id"`
+	Name   string `json:"name" db:"name"`
+	// Clé publique au format COSE, telle que fournie à l'enregistrement
+	PublicKey []byte `json:"-" db:"public_key"`
+	Algorithm int64  `json:"algorithm" db:"algorithm"`
+	// Dernier compteur de signatures reçu, pour détecter un authentificateur cloné
+	SignCount         int64          `json:"-" db:"sign_count"`
+	AAGUID            string         `json:"aaguid" db:"aaguid"`
+	Transports        pq.StringArray `json:"transports" db:"transports"`
+	AttestationFormat string         `json:"attestation_format" db:"attestation_format"`
+	// Clé synchronisée entre appareils (BE) et effectivement sauvegardée (BS)
+	BackupEligible bool       `json:"backup_eligible" db:"backup_eligible"`
+	BackupState    bool       `json:"backup_state" db:"backup_state"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+}
+
+// Structures des options transmises à navigator.credentials (WebAuthn Level 3 section 5.4),
+// sérialisées comme attendu par PublicKeyCredential.parseCreationOptionsFromJSON()
+
+type PublicKeyCredentialRPEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type PublicKeyCredentialUserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type PublicKeyCredentialParameters struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type PublicKeyCredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type AuthenticatorSelectionCriteria struct {
+	ResidentKey        string `json:"residentKey"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
+
+type PublicKeyCredentialCreationOptions struct {
+	Challenge              string                          `json:"challenge"`
+	RP                     PublicKeyCredentialRPEntity     `json:"rp"`
+	User                   PublicKeyCredentialUserEntity   `json:"user"`
+	PubKeyCredParams       []PublicKeyCredentialParameters `json:"pubKeyCredParams"`
+	Timeout                int64                           `json:"timeout"`
+	ExcludeCredentials     []PublicKeyCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelectionCriteria  `json:"authenticatorSelection"`
+	Attestation            string                          `json:"attestation"`
+}
+
+type PublicKeyCredentialRequestOptions struct {
+	Challenge        string                          `json:"challenge"`
+	Timeout          int64                           `json:"timeout"`
+	RPID             string                          `json:"rpId"`
+	AllowCredentials []PublicKeyCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                          `json:"userVerification"`
+}
+
+// WebAuthnRegistrationOptionsResponse contient les options de création et le token de
+// cérémonie à renvoyer avec la réponse de l'authentificateur
+type WebAuthnRegistrationOptionsResponse struct {
+	CeremonyToken string                             `json:"ceremony_token"`
+	PublicKey     PublicKeyCredentialCreationOptions `json:"publicKey"`
+}
+
+type WebAuthnLoginOptionsResponse struct {
+	CeremonyToken string                            `json:"ceremony_token"`
+	PublicKey     PublicKeyCredentialRequestOptions `json:"publicKey"`
+}
+
+// Réponses des authentificateurs, au format de PublicKeyCredential.toJSON() (binaire en base64url)
+
+type AuthenticatorAttestationResponse struct {
+	ClientDataJSON    string   `json:"clientDataJSON" binding:"required"`
+	AttestationObject string   `json:"attestationObject" binding:"required"`
+	Transports        []string `json:"transports"`
+}
+
+type RegistrationCredential struct {
+	ID       string                           `json:"id" binding:"required"`
+	Type     string                           `json:"type" binding:"required"`
+	Response AuthenticatorAttestationResponse `json:"response"`
+}
+
+type AuthenticatorAssertionResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+	AuthenticatorData string `json:"authenticatorData" binding:"required"`
+	Signature         string `json:"signature" binding:"required"`
+	UserHandle        string `json:"userHandle" binding:"required"`
+}
+
+type AuthenticationCredential struct {
+	ID       string                         `json:"id" binding:"required"`
+	Type     string                         `json:"type" binding:"required"`
+	Response AuthenticatorAssertionResponse `json:"response"`
+}
+
+type WebAuthnRegisterRequest struct {
+	CeremonyToken string                 `json:"ceremony_token" binding:"required"`
+	Name          string                 `json:"name"`
+	Credential    RegistrationCredential `json:"credential"`
+}
+
+type WebAuthnLoginRequest struct {
+	CeremonyToken string                   `json:"ceremony_token" binding:"required"`
+	Credential    AuthenticationCredential `json:"credential"`
+	ClientInfo
+}
@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+)
+
+var (
+	ErrAuthorizationCodeNotFound = errors.New("authorization code not found")
+	ErrAuthorizationCodeUsed     = errors.New("authorization code already used")
+)
+
+type AuthorizationCodeRepository interface {
+	Create(code *models.AuthorizationCode) error
+	// Consume marque le code comme utilisé et le retourne. Si le code a déjà été
+	// utilisé, il est retourné avec ErrAuthorizationCodeUsed.
+	Consume(codeHash string) (*models.AuthorizationCode, error)
+	// AttachSession rattache au code la session ouverte lors de son échange
+	AttachSession(codeHash string, sessionID string) error
+	PruneExpired() (int64, error)
+}
+
+type inMemoryAuthorizationCodeRepository struct {
+	codes map[string]*models.AuthorizationCode
+	mutex sync.Mutex
+}
+
+func NewAuthorizationCodeRepository() AuthorizationCodeRepository {
+	return &inMemoryAuthorizationCodeRepository{
+		codes: make(map[string]*models.AuthorizationCode),
+	}
+}
+
+func (r *inMemoryAuthorizationCodeRepository) Create(code *models.AuthorizationCode) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	code.CreatedAt = time.Now()
+	r.codes[code.CodeHash] = code
+	return nil
+}
+
+func (r *inMemoryAuthorizationCodeRepository) Consume(codeHash string) (*models.AuthorizationCode, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	code, exists := r.codes[codeHash]
+	if !exists {
+		return nil, ErrAuthorizationCodeNotFound
+	}
+
+	codeCopy := *code
+	if code.UsedAt != nil {
+		return &codeCopy, ErrAuthorizationCodeUsed
+	}
+
+	now := time.Now()
+	code.UsedAt = &now
+	codeCopy.UsedAt = &now
+	return &codeCopy, nil
+}
+
+func (r *inMemoryAuthorizationCodeRepository) AttachSession(codeHash string, sessionID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	code, exists := r.codes[codeHash]
+	if !exists {
+		return ErrAuthorizationCodeNotFound
+	}
+	code.SessionID = &sessionID
+	return nil
+}
+
+func (r *inMemoryAuthorizationCodeRepository) PruneExpired() (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var pruned int64
+	now := time.Now()
+	for hash, code := range r.codes {
+		if code.ExpiresAt.Before(now) {
+			delete(r.codes, hash)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+)
+
+var ErrEmailLoginCodeNotFound = errors.New("email login code not found")
+
+type EmailLoginCodeRepository interface {
+	// Save enregistre le code de l'utilisateur en remplaçant le précédent
+	Save(code *models.EmailLoginCode) error
+	Find(userID string) (*models.EmailLoginCode, error)
+	// RecordFailure incrémente le nombre d'essais et retourne la nouvelle valeur
+	RecordFailure(userID string) (int, error)
+	// Consume supprime le code s'il correspond, n'a pas expiré et n'a pas épuisé ses essais.
+	// Retourne false sinon, en particulier si une autre requête l'a consommé.
+	Consume(userID string, codeHash string, maxAttempts int) (bool, error)
+	Delete(userID string) error
+	PruneExpired() (int64, error)
+}
+
+type inMemoryEmailLoginCodeRepository struct {
+	codes map[string]*models.EmailLoginCode
+	mutex sync.Mutex
+}
+
+func NewEmailLoginCodeRepository() EmailLoginCodeRepository {
+	return &inMemoryEmailLoginCodeRepository{
+		codes: make(map[string]*models.EmailLoginCode),
+	}
+}
+
+func (r *inMemoryEmailLoginCodeRepository) Save(code *models.EmailLoginCode) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	code.CreatedAt = time.Now()
+	r.codes[code.UserID] = code
+	return nil
+}
+
+func (r *inMemoryEmailLoginCodeRepository) Find(userID string) (*models.EmailLoginCode, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if code, exists := r.codes[userID]; exists {
+		codeCopy := *code
+		return &codeCopy, nil
+	}
+	return nil, ErrEmailLoginCodeNotFound
+}
+
+func (r *inMemoryEmailLoginCodeRepository) RecordFailure(userID string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	code, exists := r.codes[userID]
+	if !exists {
+		return 0, ErrEmailLoginCodeNotFound
+	}
+	code.Attempts++
+	return code.Attempts, nil
+}
+
+func (r *inMemoryEmailLoginCodeRepository) Consume(userID string, codeHash string, maxAttempts int) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	code, exists := r.codes[userID]
+	if !exists || code.CodeHash != codeHash || code.Attempts >= maxAttempts || code.ExpiresAt.Before(time.Now()) {
+		return false, nil
+	}
+
+	delete(r.codes, userID)
+	return true, nil
+}
+
+func (r *inMemoryEmailLoginCodeRepository) Delete(userID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.codes, userID)
+	return nil
+}
+
+func (r *inMemoryEmailLoginCodeRepository) PruneExpired() (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var pruned int64
+	now := time.Now()
+	for userID, code := range r.codes {
+		if code.ExpiresAt.Before(now) {
+			delete(r.codes, userID)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+)
+
+var ErrLoginAttemptsNotFound = errors.New("login attempts not found")
+
+type LoginAttemptRepository interface {
+	Find(key string) (*models.LoginAttempts, error)
+	// RecordFailure incrémente le nombre d'échecs et retourne le nouvel état. Le comptage
+	// repart de zéro si l'entrée a expiré, sinon la fenêtre est prolongée de window.
+	RecordFailure(key string, window time.Duration) (*models.LoginAttempts, error)
+	// Lock refuse les connexions jusqu'à until
+	Lock(key string, until time.Time) error
+	// Delete remet les échecs à zéro et lève le blocage
+	Delete(key string) error
+	PruneExpired() (int64, error)
+}
+
+type inMemoryLoginAttemptRepository struct {
+	attempts map[string]*models.LoginAttempts
+	mutex    sync.Mutex
+}
+
+func NewLoginAttemptRepository() LoginAttemptRepository {
+	return &inMemoryLoginAttemptRepository{
+		attempts: make(map[string]*models.LoginAttempts),
+	}
+}
+
+func (r *inMemoryLoginAttemptRepository) Find(key string) (*models.LoginAttempts, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if attempts, exists := r.attempts[key]; exists {
+		attemptsCopy := *attempts
+		return &attemptsCopy, nil
+	}
+	return nil, ErrLoginAttemptsNotFound
+}
+
+func (r *inMemoryLoginAttemptRepository) RecordFailure(key string, window time.Duration) (*models.LoginAttempts, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	attempts, exists := r.attempts[key]
+	if !exists || attempts.ExpiresAt.Before(now) {
+		attempts = &models.LoginAttempts{Key: key}
+		r.attempts[key] = attempts
+	}
+
+	attempts.Failures++
+	attempts.LastFailureAt = now
+	if expiresAt := now.Add(window); expiresAt.After(attempts.ExpiresAt) {
+		attempts.ExpiresAt = expiresAt
+	}
+
+	attemptsCopy := *attempts
+	return &attemptsCopy, nil
+}
+
+func (r *inMemoryLoginAttemptRepository) Lock(key string, until time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	attempts, exists := r.attempts[key]
+	if !exists {
+		return ErrLoginAttemptsNotFound
+	}
+	attempts.LockedUntil = &until
+	if until.After(attempts.ExpiresAt) {
+		attempts.ExpiresAt = until
+	}
+	return nil
+}
+
+func (r *inMemoryLoginAttemptRepository) Delete(key string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.attempts, key)
+	return nil
+}
+
+func (r *inMemoryLoginAttemptRepository) PruneExpired() (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var pruned int64
+	now := time.Now()
+	for key, attempts := range r.attempts {
+		if attempts.ExpiresAt.Before(now) {
+			delete(r.attempts, key)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
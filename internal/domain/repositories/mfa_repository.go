@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+)
+
+var ErrTOTPNotFound = errors.New("totp credential not found")
+
+type MFARepository interface {
+	// SaveTOTP enregistre un nouveau secret en remplaçant un enrôlement non confirmé
+	SaveTOTP(credential *models.TOTPCredential) error
+	FindTOTP(userID string) (*models.TOTPCredential, error)
+	ConfirmTOTP(userID string, step int64) error
+	// UseTOTPStep enregistre le pas utilisé. Retourne false si un pas égal ou plus récent l'a déjà été.
+	UseTOTPStep(userID string, step int64) (bool, error)
+	// DeleteTOTP supprime le secret et les codes de récupération de l'utilisateur
+	DeleteTOTP(userID string) error
+	ReplaceRecoveryCodes(userID string, codeHashes []string) error
+	// UseRecoveryCode consomme un code de récupération. Retourne false s'il est inconnu ou déjà utilisé.
+	UseRecoveryCode(userID string, codeHash string) (bool, error)
+}
+
+type inMemoryMFARepository struct {
+	credentials map[string]*models.TOTPCredential
+	// Empreintes des codes de récupération restants, par utilisateur
+	recoveryCodes map[string]map[string]bool
+	mutex         sync.Mutex
+}
+
+func NewMFARepository() MFARepository {
+	return &inMemoryMFARepository{
+		credentials:   make(map[string]*models.TOTPCredential),
+		recoveryCodes: make(map[string]map[string]bool),
+	}
+}
+
+func (r *inMemoryMFARepository) SaveTOTP(credential *models.TOTPCredential) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	credential.CreatedAt = time.Now()
+	r.credentials[credential.UserID] = credential
+	return nil
+}
+
+func (r *inMemoryMFARepository) FindTOTP(userID string) (*models.TOTPCredential, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if credential, exists := r.credentials[userID]; exists {
+		credentialCopy := *credential
+		return &credentialCopy, nil
+	}
+	return nil, ErrTOTPNotFound
+}
+
+func (r *inMemoryMFARepository) ConfirmTOTP(userID string, step int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	credential, exists := r.credentials[userID]
+	if !exists {
+		return ErrTOTPNotFound
+	}
+
+	now := time.Now()
+	credential.ConfirmedAt = &now
+	credential.LastUsedStep = step
+	return nil
+}
+
+func (r *inMemoryMFARepository) UseTOTPStep(userID string, step int64) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	credential, exists := r.credentials[userID]
+	if !exists {
+		return false, ErrTOTPNotFound
+	}
+	if credential.LastUsedStep >= step {
+		return false, nil
+	}
+
+	credential.LastUsedStep = step
+	return true, nil
+}
+
+func (r *inMemoryMFARepository) DeleteTOTP(userID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.credentials, userID)
+	delete(r.recoveryCodes, userID)
+	return nil
+}
+
+func (r *inMemoryMFARepository) ReplaceRecoveryCodes(userID string, codeHashes []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	codes := make(map[string]bool, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes[hash] = true
+	}
+	r.recoveryCodes[userID] = codes
+	return nil
+}
+
+func (r *inMemoryMFARepository) UseRecoveryCode(userID string, codeHash string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.recoveryCodes[userID][codeHash] {
+		return false, nil
+	}
+
+	delete(r.recoveryCodes[userID], codeHash)
+	return true, nil
+}
@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+)
+
+var ErrOAuthClientNotFound = errors.New("oauth client not found")
+
+type OAuthClientRepository interface {
+	Create(client *models.OAuthClient) error
+	FindByID(id string) (*models.OAuthClient, error)
+	List() ([]models.OAuthClient, error)
+}
+
+type inMemoryOAuthClientRepository struct {
+	clients map[string]*models.OAuthClient
+	mutex   sync.RWMutex
+}
+
+func NewOAuthClientRepository() OAuthClientRepository {
+	return &inMemoryOAuthClientRepository{
+		clients: make(map[string]*models.OAuthClient),
+	}
+}
+
+func (r *inMemoryOAuthClientRepository) Create(client *models.OAuthClient) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	client.CreatedAt = time.Now()
+	r.clients[client.ID] = client
+	return nil
+}
+
+func (r *inMemoryOAuthClientRepository) FindByID(id string) (*models.OAuthClient, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if client, exists := r.clients[id]; exists {
+		clientCopy := *client
+		return &clientCopy, nil
+	}
+	return nil, ErrOAuthClientNotFound
+}
+
+func (r *inMemoryOAuthClientRepository) List() ([]models.OAuthClient, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	clients := make([]models.OAuthClient, 0, len(r.clients))
+	for _, client := range r.clients {
+		clients = append(clients, *client)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].CreatedAt.Before(clients[j].CreatedAt)
+	})
+	return clients, nil
+}
@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+)
+
+var (
+	ErrOrganizationNotFound      = errors.New("organization not found")
+	ErrOrganizationAlreadyExists = errors.New("organization already exists")
+)
+
+// OrganizationRepository stocke les organisations ; leurs membres sont gérés par UserRepository
+type OrganizationRepository interface {
+	// Create retourne ErrOrganizationAlreadyExists si le slug est déjà pris
+	Create(organization *models.Organization) error
+	FindByID(id string) (*models.Organization, error)
+	FindBySlug(slug string) (*models.Organization, error)
+	ListAll() ([]models.Organization, error)
+	// Update enregistre le nom et les paramètres
+	Update(organization *models.Organization) error
+	Delete(id string) error
+}
+
+type inMemoryOrganizationRepository struct {
+	organizations map[string]*models.Organization
+	mutex         sync.RWMutex
+}
+
+func NewOrganizationRepository() OrganizationRepository {
+	return &inMemoryOrganizationRepository{
+		organizations: make(map[string]*models.Organization),
+	}
+}
+
+func (r *inMemoryOrganizationRepository) Create(organization *models.Organization) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.organizations {
+		if existing.Slug == organization.Slug {
+			return ErrOrganizationAlreadyExists
+		}
+	}
+
+	organization.CreatedAt = time.Now()
+	organizationCopy := *organization
+	r.organizations[organization.ID] = &organizationCopy
+	return nil
+}
+
+func (r *inMemoryOrganizationRepository) FindByID(id string) (*models.Organization, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if organization, exists := r.organizations[id]; exists {
+		organizationCopy := *organization
+		return &organizationCopy, nil
+	}
+	return nil, ErrOrganizationNotFound
+}
+
+func (r *inMemoryOrganizationRepository) FindBySlug(slug string) (*models.Organization, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, organization := range r.organizations {
+		if organization.Slug == slug {
+			organizationCopy := *organization
+			return &organizationCopy, nil
+		}
+	}
+	return nil, ErrOrganizationNotFound
+}
+
+func (r *inMemoryOrganizationRepository) ListAll() ([]models.Organization, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	organizations := make([]models.Organization, 0, len(r.organizations))
+	for _, organization := range r.organizations {
+		organizations = append(organizations, *organization)
+	}
+	sort.Slice(organizations, func(i, j int) bool { return organizations[i].Slug < organizations[j].Slug })
+	return organizations, nil
+}
+
+func (r *inMemoryOrganizationRepository) Update(organization *models.Organization) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, exists := r.organizations[organization.ID]
+	if !exists {
+		return ErrOrganizationNotFound
+	}
+	existing.Name = organization.Name
+	existing.Settings = organization.Settings
+	return nil
+}
+
+func (r *inMemoryOrganizationRepository) Delete(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.organizations[id]; !exists {
+		return ErrOrganizationNotFound
+	}
+	delete(r.organizations, id)
+	return nil
+}
@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"sync"
+)
+
+// PasswordHistoryRepository conserve les hash des anciens mots de passe de chaque utilisateur,
+// pour refuser leur réutilisation
+type PasswordHistoryRepository interface {
+	// Add enregistre un ancien hash et ne garde que les keep plus récents de l'utilisateur
+	Add(userID string, passwordHash string, keep int) error
+	// ListRecent retourne au plus limit hash, du plus récent au plus ancien
+	ListRecent(userID string, limit int) ([]string, error)
+}
+
+type inMemoryPasswordHistoryRepository struct {
+	// Hash par utilisateur, du plus récent au plus ancien
+	hashes map[string][]string
+	mutex  sync.RWMutex
+}
+
+func NewPasswordHistoryRepository() PasswordHistoryRepository {
+	return &inMemoryPasswordHistoryRepository{
+		hashes: make(map[string][]string),
+	}
+}
+
+func (r *inMemoryPasswordHistoryRepository) Add(userID string, passwordHash string, keep int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	history := append([]string{passwordHash}, r.hashes[userID]...)
+	if len(history) > keep {
+		history = history[:keep]
+	}
+	r.hashes[userID] = history
+	return nil
+}
+
+func (r *inMemoryPasswordHistoryRepository) ListRecent(userID string, limit int) ([]string, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	history := r.hashes[userID]
+	if len(history) > limit {
+		history = history[:limit]
+	}
+	return append([]string(nil), history...), nil
+}
@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/jmoiron/sqlx"
+)
+
+type postgresAuthorizationCodeRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresAuthorizationCodeRepository(db *sqlx.DB) AuthorizationCodeRepository {
+	return &postgresAuthorizationCodeRepository{db: db}
+}
+
+func (r *postgresAuthorizationCodeRepository) Create(code *models.AuthorizationCode) error {
+	code.CreatedAt = time.Now()
+
+	query := `
+        INSERT INTO authorization_codes (code_hash, client_id, user_id, redirect_uri, scope,
+            code_challenge, code_challenge_method, nonce, auth_time, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+    `
+	_, err := r.db.Exec(query, code.CodeHash, code.ClientID, code.UserID, code.RedirectURI, code.Scope,
+		code.CodeChallenge, code.CodeChallengeMethod, code.Nonce, code.AuthTime, code.ExpiresAt, code.CreatedAt)
+	return err
+}
+
+func (r *postgresAuthorizationCodeRepository) Consume(codeHash string) (*models.AuthorizationCode, error) {
+	// La condition sur used_at rend l'opération atomique face aux requêtes concurrentes
+	var code models.AuthorizationCode
+	query := "UPDATE authorization_codes SET used_at = $1 WHERE code_hash = $2 AND used_at IS NULL RETURNING *"
+	err := r.db.Get(&code, query, time.Now(), codeHash)
+	if err == nil {
+		return &code, nil
+	}
+
+	err = r.db.Get(&code, "SELECT * FROM authorization_codes WHERE code_hash = $1", codeHash)
+	if err != nil {
+		return nil, ErrAuthorizationCodeNotFound
+	}
+	return &code, ErrAuthorizationCodeUsed
+}
+
+func (r *postgresAuthorizationCodeRepository) AttachSession(codeHash string, sessionID string) error {
+	query := "UPDATE authorization_codes SET session_id = $1 WHERE code_hash = $2"
+	_, err := r.db.Exec(query, sessionID, codeHash)
+	return err
+}
+
+func (r *postgresAuthorizationCodeRepository) PruneExpired() (int64, error) {
+	result, err := r.db.Exec("DELETE FROM authorization_codes WHERE expires_at < $1", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/jmoiron/sqlx"
+)
+
+type postgresEmailLoginCodeRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresEmailLoginCodeRepository(db *sqlx.DB) EmailLoginCodeRepository {
+	return &postgresEmailLoginCodeRepository{db: db}
+}
+
+func (r *postgresEmailLoginCodeRepository) Save(code *models.EmailLoginCode) error {
+	code.CreatedAt = time.Now()
+
+	query := `
+        INSERT INTO email_login_codes (user_id, code_hash, attempts, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (user_id) DO UPDATE
+        SET code_hash = EXCLUDED.code_hash, attempts = EXCLUDED.attempts,
+            expires_at = EXCLUDED.expires_at, created_at = EXCLUDED.created_at
+    `
+	_, err := r.db.Exec(query, code.UserID, code.CodeHash, code.Attempts, code.ExpiresAt, code.CreatedAt)
+	return err
+}
+
+func (r *postgresEmailLoginCodeRepository) Find(userID string) (*models.EmailLoginCode, error) {
+	var code models.EmailLoginCode
+	err := r.db.Get(&code, "SELECT * FROM email_login_codes WHERE user_id = $1", userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEmailLoginCodeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (r *postgresEmailLoginCodeRepository) RecordFailure(userID string) (int, error) {
+	var attempts int
+	query := "UPDATE email_login_codes SET attempts = attempts + 1 WHERE user_id = $1 RETURNING attempts"
+	err := r.db.Get(&attempts, query, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrEmailLoginCodeNotFound
+	}
+	return attempts, err
+}
+
+func (r *postgresEmailLoginCodeRepository) Consume(userID string, codeHash string, maxAttempts int) (bool, error) {
+	// Toutes les conditions sont vérifiées dans la même requête pour rester atomique
+	query := `
+        DELETE FROM email_login_codes
+        WHERE user_id = $1 AND code_hash = $2 AND attempts < $3 AND expires_at > $4
+    `
+	result, err := r.db.Exec(query, userID, codeHash, maxAttempts, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *postgresEmailLoginCodeRepository) Delete(userID string) error {
+	_, err := r.db.Exec("DELETE FROM email_login_codes WHERE user_id = $1", userID)
+	return err
+}
+
+func (r *postgresEmailLoginCodeRepository) PruneExpired() (int64, error) {
+	result, err := r.db.Exec("DELETE FROM email_login_codes WHERE expires_at < $1", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
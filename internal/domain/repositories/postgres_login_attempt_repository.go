@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/jmoiron/sqlx"
+)
+
+type postgresLoginAttemptRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresLoginAttemptRepository(db *sqlx.DB) LoginAttemptRepository {
+	return &postgresLoginAttemptRepository{db: db}
+}
+
+func (r *postgresLoginAttemptRepository) Find(key string) (*models.LoginAttempts, error) {
+	var attempts models.LoginAttempts
+	err := r.db.Get(&attempts, "SELECT * FROM login_attempts WHERE key = $1", key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrLoginAttemptsNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attempts, nil
+}
+
+func (r *postgresLoginAttemptRepository) RecordFailure(key string, window time.Duration) (*models.LoginAttempts, error) {
+	now := time.Now()
+
+	// Une seule requête : des échecs simultanés ne peuvent pas se perdre
+	query := `
+        INSERT INTO login_attempts (key, failures, last_failure_at, expires_at)
+        VALUES ($1, 1, $2, $3)
+        ON CONFLICT (key) DO UPDATE
+        SET failures = CASE WHEN login_attempts.expires_at < $2 THEN 1 ELSE login_attempts.failures + 1 END,
+            locked_until = CASE WHEN login_attempts.expires_at < $2 THEN NULL ELSE login_attempts.locked_until END,
+            last_failure_at = $2,
+            expires_at = GREATEST(login_attempts.expires_at, EXCLUDED.expires_at)
+        RETURNING *
+    `
+	var attempts models.LoginAttempts
+	if err := r.db.Get(&attempts, query, key, now, now.Add(window)); err != nil {
+		return nil, err
+	}
+	return &attempts, nil
+}
+
+func (r *postgresLoginAttemptRepository) Lock(key string, until time.Time) error {
+	query := `
+        UPDATE login_attempts
+        SET locked_until = $2, expires_at = GREATEST(expires_at, $2)
+        WHERE key = $1
+    `
+	result, err := r.db.Exec(query, key, until)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrLoginAttemptsNotFound
+	}
+	return nil
+}
+
+func (r *postgresLoginAttemptRepository) Delete(key string) error {
+	_, err := r.db.Exec("DELETE FROM login_attempts WHERE key = $1", key)
+	return err
+}
+
+func (r *postgresLoginAttemptRepository) PruneExpired() (int64, error) {
+	result, err := r.db.Exec("DELETE FROM login_attempts WHERE expires_at < $1", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type postgresMFARepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresMFARepository(db *sqlx.DB) MFARepository {
+	return &postgresMFARepository{db: db}
+}
+
+func (r *postgresMFARepository) SaveTOTP(credential *models.TOTPCredential) error {
+	credential.CreatedAt = time.Now()
+
+	query := `
+        INSERT INTO mfa_totp (user_id, secret, confirmed_at, last_used_step, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (user_id) DO UPDATE
+        SET secret = EXCLUDED.secret, confirmed_at = EXCLUDED.confirmed_at,
+            last_used_step = EXCLUDED.last_used_step, created_at = EXCLUDED.created_at
+    `
+	_, err := r.db.Exec(query, credential.UserID, credential.Secret, credential.ConfirmedAt,
+		credential.LastUsedStep, credential.CreatedAt)
+	return err
+}
+
+func (r *postgresMFARepository) FindTOTP(userID string) (*models.TOTPCredential, error) {
+	var credential models.TOTPCredential
+	err := r.db.Get(&credential, "SELECT * FROM mfa_totp WHERE user_id = $1", userID)
+	// Une erreur de la base ne doit pas être confondue avec l'absence de second facteur
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTOTPNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *postgresMFARepository) ConfirmTOTP(userID string, step int64) error {
+	query := "UPDATE mfa_totp SET confirmed_at = $1, last_used_step = $2 WHERE user_id = $3"
+	result, err := r.db.Exec(query, time.Now(), step, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTOTPNotFound
+	}
+	return nil
+}
+
+func (r *postgresMFARepository) UseTOTPStep(userID string, step int64) (bool, error) {
+	// La condition sur last_used_step rend l'opération atomique face aux requêtes concurrentes
+	query := "UPDATE mfa_totp SET last_used_step = $1 WHERE user_id = $2 AND last_used_step < $1"
+	result, err := r.db.Exec(query, step, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *postgresMFARepository) DeleteTOTP(userID string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM mfa_totp WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *postgresMFARepository) ReplaceRecoveryCodes(userID string, codeHashes []string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, hash := range codeHashes {
+		query := "INSERT INTO mfa_recovery_codes (id, user_id, code_hash, created_at) VALUES ($1, $2, $3, $4)"
+		if _, err := tx.Exec(query, uuid.New().String(), userID, hash, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *postgresMFARepository) UseRecoveryCode(userID string, codeHash string) (bool, error) {
+	query := "UPDATE mfa_recovery_codes SET used_at = $1 WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL"
+	result, err := r.db.Exec(query, time.Now(), userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
@@ -0,0 +1,44 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/jmoiron/sqlx"
+)
+
+type postgresOAuthClientRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresOAuthClientRepository(db *sqlx.DB) OAuthClientRepository {
+	return &postgresOAuthClientRepository{db: db}
+}
+
+func (r *postgresOAuthClientRepository) Create(client *models.OAuthClient) error {
+	client.CreatedAt = time.Now()
+
+	query := `
+        INSERT INTO oauth_clients (id, name, secret_hash, redirect_uris, grant_types, scopes, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `
+	_, err := r.db.Exec(query, client.ID, client.Name, client.SecretHash, client.RedirectURIs,
+		client.GrantTypes, client.Scopes, client.CreatedAt)
+	return err
+}
+
+func (r *postgresOAuthClientRepository) FindByID(id string) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	query := "SELECT * FROM oauth_clients WHERE id = $1"
+	err := r.db.Get(&client, query, id)
+	if err != nil {
+		return nil, ErrOAuthClientNotFound
+	}
+	return &client, nil
+}
+
+func (r *postgresOAuthClientRepository) List() ([]models.OAuthClient, error) {
+	clients := []models.OAuthClient{}
+	err := r.db.Select(&clients, "SELECT * FROM oauth_clients ORDER BY created_at")
+	return clients, err
+}
@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type postgresOrganizationRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresOrganizationRepository(db *sqlx.DB) OrganizationRepository {
+	return &postgresOrganizationRepository{db: db}
+}
+
+func (r *postgresOrganizationRepository) Create(organization *models.Organization) error {
+	organization.CreatedAt = time.Now()
+
+	query := `
+        INSERT INTO organizations (id, slug, name, settings, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+    `
+	_, err := r.db.Exec(query, organization.ID, organization.Slug, organization.Name, organization.Settings, organization.CreatedAt)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrOrganizationAlreadyExists
+	}
+	return err
+}
+
+func (r *postgresOrganizationRepository) FindByID(id string) (*models.Organization, error) {
+	// Un identifiant qui n'est pas un UUID ferait échouer la requête : il ne désigne aucune organisation
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+
+	var organization models.Organization
+	if err := r.db.Get(&organization, "SELECT * FROM organizations WHERE id = $1", id); err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+	return &organization, nil
+}
+
+func (r *postgresOrganizationRepository) FindBySlug(slug string) (*models.Organization, error) {
+	var organization models.Organization
+	if err := r.db.Get(&organization, "SELECT * FROM organizations WHERE slug = $1", slug); err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+	return &organization, nil
+}
+
+func (r *postgresOrganizationRepository) ListAll() ([]models.Organization, error) {
+	organizations := []models.Organization{}
+	err := r.db.Select(&organizations, "SELECT * FROM organizations ORDER BY slug")
+	return organizations, err
+}
+
+func (r *postgresOrganizationRepository) Update(organization *models.Organization) error {
+	query := `
+        UPDATE organizations
+        SET name = $1, settings = $2
+        WHERE id = $3
+    `
+	result, err := r.db.Exec(query, organization.Name, organization.Settings, organization.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrOrganizationNotFound
+	}
+	return nil
+}
+
+func (r *postgresOrganizationRepository) Delete(id string) error {
+	// Les adhésions et les comptes de l'organisation sont supprimés en cascade
+	result, err := r.db.Exec("DELETE FROM organizations WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrOrganizationNotFound
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type postgresPasswordHistoryRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresPasswordHistoryRepository(db *sqlx.DB) PasswordHistoryRepository {
+	return &postgresPasswordHistoryRepository{db: db}
+}
+
+func (r *postgresPasswordHistoryRepository) Add(userID string, passwordHash string, keep int) error {
+	query := `
+        INSERT INTO password_history (id, user_id, password_hash, created_at)
+        VALUES ($1, $2, $3, $4)
+    `
+	if _, err := r.db.Exec(query, uuid.New().String(), userID, passwordHash, time.Now()); err != nil {
+		return err
+	}
+
+	query = `
+        DELETE FROM password_history
+        WHERE user_id = $1 AND id NOT IN (
+            SELECT id FROM password_history WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2
+        )
+    `
+	_, err := r.db.Exec(query, userID, keep)
+	return err
+}
+
+func (r *postgresPasswordHistoryRepository) ListRecent(userID string, limit int) ([]string, error) {
+	hashes := []string{}
+	query := `
+        SELECT password_hash FROM password_history
+        WHERE user_id = $1
+        ORDER BY created_at DESC
+        LIMIT $2
+    `
+	err := r.db.Select(&hashes, query, userID, limit)
+	return hashes, err
+}
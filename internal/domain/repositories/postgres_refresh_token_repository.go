@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/jmoiron/sqlx"
+)
+
+type postgresRefreshTokenRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresRefreshTokenRepository(db *sqlx.DB) RefreshTokenRepository {
+	return &postgresRefreshTokenRepository{db: db}
+}
+
+func (r *postgresRefreshTokenRepository) Create(token *models.RefreshToken) error {
+	token.CreatedAt = time.Now()
+
+	query := `
+        INSERT INTO refresh_tokens (id, family_id, user_id, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, $5)
+    `
+	_, err := r.db.Exec(query, token.ID, token.FamilyID, token.UserID, token.ExpiresAt, token.CreatedAt)
+	return err
+}
+
+func (r *postgresRefreshTokenRepository) FindByID(id string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	query := "SELECT * FROM refresh_tokens WHERE id = $1"
+	err := r.db.Get(&token, query, id)
+	if err != nil {
+		return nil, ErrRefreshTokenNotFound
+	}
+	return &token, nil
+}
+
+func (r *postgresRefreshTokenRepository) MarkRotated(id string) (bool, error) {
+	// La condition sur rotated_at rend l'opération atomique face aux requêtes concurrentes
+	query := "UPDATE refresh_tokens SET rotated_at = $1 WHERE id = $2 AND rotated_at IS NULL"
+	result, err := r.db.Exec(query, time.Now(), id)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected == 1, nil
+}
+
+func (r *postgresRefreshTokenRepository) RevokeFamily(familyID string) error {
+	query := "UPDATE refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL"
+	_, err := r.db.Exec(query, time.Now(), familyID)
+	return err
+}
+
+func (r *postgresRefreshTokenRepository) PruneExpired() (int64, error) {
+	result, err := r.db.Exec("DELETE FROM refresh_tokens WHERE expires_at < $1", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,40 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type postgresRevocationStore struct {
+	db *sqlx.DB
+}
+
+func NewPostgresRevocationStore(db *sqlx.DB) RevocationStore {
+	return &postgresRevocationStore{db: db}
+}
+
+func (s *postgresRevocationStore) Revoke(jti string, expiresAt time.Time) error {
+	query := `
+        INSERT INTO revoked_tokens (jti, expires_at, revoked_at)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (jti) DO NOTHING
+    `
+	_, err := s.db.Exec(query, jti, expiresAt, time.Now())
+	return err
+}
+
+func (s *postgresRevocationStore) IsRevoked(jti string) (bool, error) {
+	var exists bool
+	query := "SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)"
+	err := s.db.Get(&exists, query, jti)
+	return exists, err
+}
+
+func (s *postgresRevocationStore) PruneExpired() (int64, error) {
+	result, err := s.db.Exec("DELETE FROM revoked_tokens WHERE expires_at < $1", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,167 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type postgresRoleRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresRoleRepository(db *sqlx.DB) RoleRepository {
+	return &postgresRoleRepository{db: db}
+}
+
+// selectRoles charge les rôles avec leurs permissions agrégées en tableau
+const selectRoles = `
+        SELECT r.id, r.name, r.description, r.created_at,
+               COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}') AS permissions
+        FROM roles r
+        LEFT JOIN role_permissions rp ON rp.role_id = r.id
+    `
+
+func (r *postgresRoleRepository) SavePermission(permission models.Permission) error {
+	query := `
+        INSERT INTO permissions (name, description)
+        VALUES ($1, $2)
+        ON CONFLICT (name) DO UPDATE SET description = EXCLUDED.description
+    `
+	_, err := r.db.Exec(query, permission.Name, permission.Description)
+	return err
+}
+
+func (r *postgresRoleRepository) ListPermissions() ([]models.Permission, error) {
+	permissions := []models.Permission{}
+	err := r.db.Select(&permissions, "SELECT name, description FROM permissions ORDER BY name")
+	return permissions, err
+}
+
+func (r *postgresRoleRepository) CreateRole(role *models.Role) error {
+	role.CreatedAt = time.Now()
+
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO roles (id, name, description, created_at)
+        VALUES ($1, $2, $3, $4)
+    `
+	if _, err := tx.Exec(query, role.ID, role.Name, role.Description, role.CreatedAt); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrRoleAlreadyExists
+		}
+		return err
+	}
+	if err := insertRolePermissions(tx, role.ID, role.Permissions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *postgresRoleRepository) FindRoleByName(name string) (*models.Role, error) {
+	var role models.Role
+	err := r.db.Get(&role, selectRoles+" WHERE r.name = $1 GROUP BY r.id", name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRoleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *postgresRoleRepository) ListRoles() ([]models.Role, error) {
+	roles := []models.Role{}
+	err := r.db.Select(&roles, selectRoles+" GROUP BY r.id ORDER BY r.name")
+	return roles, err
+}
+
+func (r *postgresRoleRepository) SetRolePermissions(roleID string, permissions []string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM role_permissions WHERE role_id = $1", roleID); err != nil {
+		return err
+	}
+	if err := insertRolePermissions(tx, roleID, permissions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertRolePermissions(tx *sqlx.Tx, roleID string, permissions []string) error {
+	for _, permission := range permissions {
+		query := "INSERT INTO role_permissions (role_id, permission) VALUES ($1, $2) ON CONFLICT DO NOTHING"
+		if _, err := tx.Exec(query, roleID, permission); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *postgresRoleRepository) DeleteRole(roleID string) error {
+	// Les permissions et attributions du rôle sont supprimées en cascade
+	result, err := r.db.Exec("DELETE FROM roles WHERE id = $1", roleID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRoleNotFound
+	}
+	return nil
+}
+
+func (r *postgresRoleRepository) AssignRole(userID string, roleID string) error {
+	query := `
+        INSERT INTO user_roles (user_id, role_id, created_at)
+        VALUES ($1, $2, $3)
+        ON CONFLICT DO NOTHING
+    `
+	_, err := r.db.Exec(query, userID, roleID, time.Now())
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return ErrRoleNotFound
+	}
+	return err
+}
+
+func (r *postgresRoleRepository) UnassignRole(userID string, roleID string) error {
+	_, err := r.db.Exec("DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2", userID, roleID)
+	return err
+}
+
+func (r *postgresRoleRepository) ListUserRoles(userID string) ([]models.Role, error) {
+	roles := []models.Role{}
+	query := selectRoles + `
+        JOIN user_roles ur ON ur.role_id = r.id
+        WHERE ur.user_id = $1
+        GROUP BY r.id
+        ORDER BY r.name
+    `
+	err := r.db.Select(&roles, query, userID)
+	return roles, err
+}
+
+func (r *postgresRoleRepository) ListRoleUserIDs(roleID string) ([]string, error) {
+	userIDs := []string{}
+	err := r.db.Select(&userIDs, "SELECT user_id FROM user_roles WHERE role_id = $1 ORDER BY user_id", roleID)
+	return userIDs, err
+}
@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/pkg/auth"
+	"github.com/jmoiron/sqlx"
+)
+
+type postgresSessionRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresSessionRepository(db *sqlx.DB) SessionRepository {
+	return &postgresSessionRepository{db: db}
+}
+
+func (r *postgresSessionRepository) Create(session *models.Session) error {
+	session.CreatedAt = time.Now()
+	session.LastSeenAt = session.CreatedAt
+
+	query := `
+        INSERT INTO sessions (id, user_id, device_name, user_agent, ip_address, client_id, scope, org_id, created_at, last_seen_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+    `
+	_, err := r.db.Exec(query, session.ID, session.UserID, session.DeviceName, session.UserAgent,
+		session.IPAddress, session.ClientID, session.Scope, session.OrgID, session.CreatedAt, session.LastSeenAt)
+	return err
+}
+
+func (r *postgresSessionRepository) FindByID(id string) (*models.Session, error) {
+	var session models.Session
+	query := "SELECT * FROM sessions WHERE id = $1"
+	err := r.db.Get(&session, query, id)
+	if err != nil {
+		return nil, ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+func (r *postgresSessionRepository) ListActiveByUser(userID string) ([]models.Session, error) {
+	sessions := []models.Session{}
+	query := `
+        SELECT * FROM sessions
+        WHERE user_id = $1 AND revoked_at IS NULL AND last_seen_at > $2
+        ORDER BY last_seen_at DESC
+    `
+	err := r.db.Select(&sessions, query, userID, time.Now().Add(-auth.RefreshTokenLifetime))
+	return sessions, err
+}
+
+func (r *postgresSessionRepository) Touch(id string, ipAddress string, seenAt time.Time) error {
+	query := `
+        UPDATE sessions
+        SET last_seen_at = $1, ip_address = COALESCE(NULLIF($2, ''), ip_address)
+        WHERE id = $3
+    `
+	result, err := r.db.Exec(query, seenAt, ipAddress, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (r *postgresSessionRepository) Revoke(id string) error {
+	query := "UPDATE sessions SET revoked_at = COALESCE(revoked_at, $1) WHERE id = $2"
+	result, err := r.db.Exec(query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (r *postgresSessionRepository) RevokeAllForUser(userID string, exceptID string) ([]string, error) {
+	revoked := []string{}
+	query := `
+        UPDATE sessions SET revoked_at = $1
+        WHERE user_id = $2 AND id <> $3 AND revoked_at IS NULL
+        RETURNING id
+    `
+	err := r.db.Select(&revoked, query, time.Now(), userID, exceptID)
+	return revoked, err
+}
+
+func (r *postgresSessionRepository) PruneExpired() (int64, error) {
+	result, err := r.db.Exec("DELETE FROM sessions WHERE last_seen_at < $1", time.Now().Add(-auth.RefreshTokenLifetime))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/amirtalbi/examen_go/pkg/auth"
+	"github.com/jmoiron/sqlx"
+)
+
+type postgresSigningKeyRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresSigningKeyRepository(db *sqlx.DB) auth.KeyStore {
+	return &postgresSigningKeyRepository{db: db}
+}
+
+type signingKeyRow struct {
+	ID          string     `db:"kid"`
+	Algorithm   string     `db:"alg"`
+	Material    []byte     `db:"material"`
+	State       string     `db:"state"`
+	ActivatesAt time.Time  `db:"activates_at"`
+	RetiresAt   *time.Time `db:"retires_at"`
+	UpdatedAt   time.Time  `db:"updated_at"`
+}
+
+func (r *postgresSigningKeyRepository) SaveKey(key auth.StoredKey) error {
+	query := `
+        INSERT INTO signing_keys (kid, alg, material, state, activates_at, retires_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        ON CONFLICT (kid) DO UPDATE
+        SET material = EXCLUDED.material,
+            state = EXCLUDED.state,
+            activates_at = EXCLUDED.activates_at,
+            retires_at = EXCLUDED.retires_at,
+            updated_at = EXCLUDED.updated_at
+        WHERE signing_keys.state <> 'retired'
+    `
+	_, err := r.db.Exec(query, key.ID, key.Algorithm, key.Material, key.State, key.ActivatesAt, key.RetiresAt, time.Now())
+	return err
+}
+
+func (r *postgresSigningKeyRepository) ListKeys() ([]auth.StoredKey, error) {
+	var rows []signingKeyRow
+	if err := r.db.Select(&rows, "SELECT * FROM signing_keys ORDER BY activates_at"); err != nil {
+		return nil, err
+	}
+
+	keys := make([]auth.StoredKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, auth.StoredKey{
+			ID:          row.ID,
+			Algorithm:   row.Algorithm,
+			Material:    row.Material,
+			State:       row.State,
+			ActivatesAt: row.ActivatesAt,
+			RetiresAt:   row.RetiresAt,
+		})
+	}
+	return keys, nil
+}
@@ -1,42 +1,117 @@
 package repositories
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/amirtalbi/examen_go/internal/domain/models"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type postgresUserRepository struct {
 	db *sqlx.DB
+	// Organisation de la vue, vide hors de toute vue
+	orgID       string
+	tenantOwned bool
 }
 
 func NewPostgresUserRepository(db *sqlx.DB) UserRepository {
 	return &postgresUserRepository{db: db}
 }
 
+func (r *postgresUserRepository) ForOrganization(orgID string, tenantOwned bool) UserRepository {
+	return &postgresUserRepository{db: r.db, orgID: orgID, tenantOwned: tenantOwned}
+}
+
+// memberCondition teste l'adhésion du compte à l'organisation passée en paramètre
+const memberCondition = "EXISTS (SELECT 1 FROM organization_members m WHERE m.user_id = users.id AND m.org_id = %s)"
+
+// scopeCondition limite une requête aux comptes de la vue. L'organisation, s'il y en a une,
+// est ajoutée aux arguments comme paramètre suivant.
+func (r *postgresUserRepository) scopeCondition(args []interface{}) (string, []interface{}) {
+	if r.orgID == "" {
+		return "TRUE", args
+	}
+	args = append(args, r.orgID)
+	return fmt.Sprintf(memberCondition, fmt.Sprintf("$%d", len(args))), args
+}
+
+// emailScopeCondition limite une recherche par email : hors de toute vue, seuls les comptes
+// de l'instance sont désignés par leur email
+func (r *postgresUserRepository) emailScopeCondition(args []interface{}) (string, []interface{}) {
+	if r.orgID == "" {
+		return "org_id IS NULL", args
+	}
+	return r.scopeCondition(args)
+}
+
 func (r *postgresUserRepository) Create(user *models.User) error {
 	user.ID = uuid.New().String()
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
+	user.OrgID = nil
+	if r.orgID != "" && r.tenantOwned {
+		orgID := r.orgID
+		user.OrgID = &orgID
+	}
+
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// L'index unique ne couvre que les comptes du même propriétaire : un membre invité
+	// depuis l'instance ne doit pas non plus avoir la même adresse
+	if r.orgID != "" {
+		var taken bool
+		err := tx.Get(&taken, "SELECT EXISTS (SELECT 1 FROM users WHERE email = $1 AND "+fmt.Sprintf(memberCondition, "$2")+")", user.Email, r.orgID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrEmailAlreadyExists
+		}
+	}
 
 	query := `
-        INSERT INTO users (id, name, email, password, created_at, updated_at)
-        VALUES ($1, $2, $3, $4, $5, $6)
+        INSERT INTO users (id, name, email, password, email_verified_at, org_id, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
     `
-	_, err := r.db.Exec(query, user.ID, user.Name, user.Email, user.Password, user.CreatedAt, user.UpdatedAt)
+	_, err = tx.Exec(query, user.ID, user.Name, user.Email, user.Password, user.EmailVerifiedAt, user.OrgID, user.CreatedAt, user.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrEmailAlreadyExists
+		}
+		return err
+	}
 
-	return err
+	if r.orgID != "" {
+		_, err = tx.Exec(`
+        INSERT INTO organization_members (org_id, user_id, roles, created_at)
+        VALUES ($1, $2, '{}', $3)
+    `, r.orgID, user.ID, user.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 func (r *postgresUserRepository) FindByEmail(email string) (*models.User, error) {
 	var user models.User
-	query := "SELECT * FROM users WHERE email = $1"
+	condition, args := r.emailScopeCondition([]interface{}{email})
+	query := "SELECT * FROM users WHERE email = $1 AND " + condition
 	
 	log.Printf("Searching for user with email: %s", email)
-	err := r.db.Get(&user, query, email)
+	err := r.db.Get(&user, query, args...)
 	if err != nil {
 		log.Printf("Error finding user by email: %v", err)
 		return nil, ErrUserNotFound
@@ -47,8 +122,9 @@ func (r *postgresUserRepository) FindByEmail(email string) (*models.User, error)
 
 func (r *postgresUserRepository) FindByID(id string) (*models.User, error) {
 	var user models.User
-	query := "SELECT * FROM users WHERE id = $1"
-	err := r.db.Get(&user, query, id)
+	condition, args := r.scopeCondition([]interface{}{id})
+	query := "SELECT * FROM users WHERE id = $1 AND " + condition
+	err := r.db.Get(&user, query, args...)
 	if err != nil {
 		return nil, ErrUserNotFound
 	}
@@ -56,12 +132,12 @@ func (r *postgresUserRepository) FindByID(id string) (*models.User, error) {
 }
 
 func (r *postgresUserRepository) SaveResetToken(email, token string, expiry time.Time) error {
+	condition, args := r.emailScopeCondition([]interface{}{token, expiry, time.Now(), email})
 	query := `
         UPDATE users 
         SET reset_token = $1, reset_token_expires = $2, updated_at = $3
-        WHERE email = $4
-    `
-	result, err := r.db.Exec(query, token, expiry, time.Now(), email)
+        WHERE email = $4 AND ` + condition
+	result, err := r.db.Exec(query, args...)
 	if err != nil {
 		return err
 	}
@@ -79,11 +155,11 @@ func (r *postgresUserRepository) SaveResetToken(email, token string, expiry time
 
 func (r *postgresUserRepository) FindByResetToken(token string) (*models.User, error) {
 	var user models.User
+	condition, args := r.scopeCondition([]interface{}{token, time.Now()})
 	query := `
         SELECT * FROM users 
-        WHERE reset_token = $1 AND (reset_token_expires IS NULL OR reset_token_expires > $2)
-    `
-	err := r.db.Get(&user, query, token, time.Now())
+        WHERE reset_token = $1 AND (reset_token_expires IS NULL OR reset_token_expires > $2) AND ` + condition
+	err := r.db.Get(&user, query, args...)
 	if err != nil {
 		return nil, ErrUserNotFound
 	}
@@ -91,12 +167,34 @@ func (r *postgresUserRepository) FindByResetToken(token string) (*models.User, e
 }
 
 func (r *postgresUserRepository) UpdatePassword(id, password string) error {
+	condition, args := r.scopeCondition([]interface{}{password, time.Now(), id})
 	query := `
         UPDATE users 
-        SET password = $1, reset_token = NULL, reset_token_expires = NULL, updated_at = $2
-        WHERE id = $3
-    `
-	result, err := r.db.Exec(query, password, time.Now(), id)
+        SET password = $1, reset_token = NULL, reset_token_expires = NULL, must_reset_password = FALSE, updated_at = $2
+        WHERE id = $3 AND ` + condition
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *postgresUserRepository) MarkEmailVerified(id string, verifiedAt time.Time) error {
+	condition, args := r.scopeCondition([]interface{}{verifiedAt, time.Now(), id})
+	query := `
+        UPDATE users
+        SET email_verified_at = COALESCE(email_verified_at, $1), updated_at = $2
+        WHERE id = $3 AND ` + condition
+	result, err := r.db.Exec(query, args...)
 	if err != nil {
 		return err
 	}
@@ -111,3 +209,272 @@ func (r *postgresUserRepository) UpdatePassword(id, password string) error {
 
 	return nil
 }
+
+func (r *postgresUserRepository) UpdatePasswordHash(id, previousHash, newHash string) error {
+	condition, args := r.scopeCondition([]interface{}{newHash, id, previousHash})
+	query := `
+        UPDATE users
+        SET password = $1
+        WHERE id = $2 AND password = $3 AND ` + condition
+	_, err := r.db.Exec(query, args...)
+	return err
+}
+
+// likeEscaper neutralise les jokers de LIKE dans un préfixe saisi par l'utilisateur
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (r *postgresUserRepository) List(filter models.UserFilter) ([]models.User, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, values ...interface{}) {
+		for _, value := range values {
+			args = append(args, value)
+			condition = strings.Replace(condition, "?", fmt.Sprintf("$%d", len(args)), 1)
+		}
+		conditions = append(conditions, condition)
+	}
+
+	if r.orgID != "" {
+		addCondition(fmt.Sprintf(memberCondition, "?"), r.orgID)
+	}
+	if filter.EmailPrefix != "" {
+		addCondition("lower(email) LIKE lower(?) || '%'", likeEscaper.Replace(filter.EmailPrefix))
+	}
+	if filter.CreatedAfter != nil {
+		addCondition("created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		addCondition("created_at < ?", *filter.CreatedBefore)
+	}
+	if filter.Verified != nil {
+		if *filter.Verified {
+			addCondition("email_verified_at IS NOT NULL")
+		} else {
+			addCondition("email_verified_at IS NULL")
+		}
+	}
+	if filter.Locked != nil {
+		if *filter.Locked {
+			addCondition("locked_at IS NOT NULL")
+		} else {
+			addCondition("locked_at IS NULL")
+		}
+	}
+	if filter.After != nil {
+		addCondition("(created_at, id) < (?, ?)", filter.After.CreatedAt, filter.After.ID)
+	}
+
+	query := "SELECT * FROM users"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	users := []models.User{}
+	err := r.db.Select(&users, query, args...)
+	return users, err
+}
+
+func (r *postgresUserRepository) Update(user *models.User) error {
+	user.UpdatedAt = time.Now()
+
+	// Comme à la création, l'adresse ne doit pas être celle d'un autre membre de
+	// l'organisation de la vue, ou de celle qui possède le compte
+	var orgID interface{}
+	if r.orgID != "" {
+		orgID = r.orgID
+	}
+	var taken bool
+	err := r.db.Get(&taken, `
+        SELECT EXISTS (
+            SELECT 1 FROM users WHERE email = $1 AND id <> $2
+            AND `+fmt.Sprintf(memberCondition, "COALESCE($3, (SELECT org_id FROM users owner WHERE owner.id = $2))")+`
+        )
+    `, user.Email, user.ID, orgID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrEmailAlreadyExists
+	}
+
+	condition, args := r.scopeCondition([]interface{}{user.Name, user.Email, user.EmailVerifiedAt, user.UpdatedAt, user.ID})
+	query := `
+        UPDATE users
+        SET name = $1, email = $2, email_verified_at = $3, updated_at = $4
+        WHERE id = $5 AND ` + condition
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrEmailAlreadyExists
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *postgresUserRepository) SetLocked(id string, lockedAt *time.Time) error {
+	return r.updateColumn(id, "locked_at", lockedAt)
+}
+
+func (r *postgresUserRepository) SetMustResetPassword(id string, required bool) error {
+	return r.updateColumn(id, "must_reset_password", required)
+}
+
+// updateColumn modifie une colonne de l'utilisateur ; column n'est jamais fourni par le client
+func (r *postgresUserRepository) updateColumn(id string, column string, value interface{}) error {
+	condition, args := r.scopeCondition([]interface{}{value, time.Now(), id})
+	query := fmt.Sprintf("UPDATE users SET %s = $1, updated_at = $2 WHERE id = $3 AND %s", column, condition)
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *postgresUserRepository) Delete(id string) error {
+	if r.orgID != "" {
+		return r.deleteFromOrganization(id)
+	}
+
+	// Les sessions, rôles, adhésions, facteurs et historiques de l'utilisateur sont supprimés en cascade
+	result, err := r.db.Exec("DELETE FROM users WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// deleteFromOrganization supprime un compte de l'organisation de la vue, ou retire de ses
+// membres un compte qui ne lui appartient pas
+func (r *postgresUserRepository) deleteFromOrganization(id string) error {
+	result, err := r.db.Exec("DELETE FROM users WHERE id = $1 AND org_id = $2", id, r.orgID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	if err := r.DeleteMembership(r.orgID, id); err != nil {
+		if errors.Is(err, ErrMembershipNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// inOrganization refuse les adhésions à une autre organisation que celle de la vue
+func (r *postgresUserRepository) inOrganization(orgID string) bool {
+	return r.orgID == "" || r.orgID == orgID
+}
+
+func (r *postgresUserRepository) SaveMembership(membership *models.Membership) error {
+	if !r.inOrganization(membership.OrgID) {
+		return ErrMembershipNotFound
+	}
+	if membership.Roles == nil {
+		membership.Roles = pq.StringArray{}
+	}
+
+	query := `
+        INSERT INTO organization_members (org_id, user_id, roles, created_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (org_id, user_id) DO UPDATE SET roles = EXCLUDED.roles
+        RETURNING created_at
+    `
+	err := r.db.Get(&membership.CreatedAt, query, membership.OrgID, membership.UserID, membership.Roles, time.Now())
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *postgresUserRepository) FindMembership(orgID string, userID string) (*models.Membership, error) {
+	if !r.inOrganization(orgID) {
+		return nil, ErrMembershipNotFound
+	}
+
+	var membership models.Membership
+	query := "SELECT * FROM organization_members WHERE org_id = $1 AND user_id = $2"
+	if err := r.db.Get(&membership, query, orgID, userID); err != nil {
+		return nil, ErrMembershipNotFound
+	}
+	return &membership, nil
+}
+
+func (r *postgresUserRepository) ListMemberships(userID string) ([]models.Membership, error) {
+	query := "SELECT * FROM organization_members WHERE user_id = $1"
+	args := []interface{}{userID}
+	if r.orgID != "" {
+		query += " AND org_id = $2"
+		args = append(args, r.orgID)
+	}
+	query += " ORDER BY created_at"
+
+	memberships := []models.Membership{}
+	err := r.db.Select(&memberships, query, args...)
+	return memberships, err
+}
+
+func (r *postgresUserRepository) DeleteMembership(orgID string, userID string) error {
+	if !r.inOrganization(orgID) {
+		return ErrMembershipNotFound
+	}
+
+	result, err := r.db.Exec("DELETE FROM organization_members WHERE org_id = $1 AND user_id = $2", orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrMembershipNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/jmoiron/sqlx"
+)
+
+type postgresWebAuthnCredentialRepository struct {
+	db *sqlx.DB
+}
+
+func NewPostgresWebAuthnCredentialRepository(db *sqlx.DB) WebAuthnCredentialRepository {
+	return &postgresWebAuthnCredentialRepository{db: db}
+}
+
+func (r *postgresWebAuthnCredentialRepository) Create(credential *models.WebAuthnCredential) error {
+	credential.CreatedAt = time.Now()
+
+	query := `
+        INSERT INTO webauthn_credentials (id, user_id, name, public_key, algorithm, sign_count, aaguid,
+            transports, attestation_format, backup_eligible, backup_state, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+        ON CONFLICT (id) DO NOTHING
+    `
+	result, err := r.db.Exec(query, credential.ID, credential.UserID, credential.Name, credential.PublicKey,
+		credential.Algorithm, credential.SignCount, credential.AAGUID, credential.Transports,
+		credential.AttestationFormat, credential.BackupEligible, credential.BackupState, credential.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrWebAuthnCredentialExists
+	}
+	return nil
+}
+
+func (r *postgresWebAuthnCredentialRepository) FindByID(id string) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	err := r.db.Get(&credential, "SELECT * FROM webauthn_credentials WHERE id = $1", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebAuthnCredentialNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *postgresWebAuthnCredentialRepository) ListByUser(userID string) ([]models.WebAuthnCredential, error) {
+	credentials := []models.WebAuthnCredential{}
+	query := "SELECT * FROM webauthn_credentials WHERE user_id = $1 ORDER BY created_at"
+	err := r.db.Select(&credentials, query, userID)
+	return credentials, err
+}
+
+func (r *postgresWebAuthnCredentialRepository) UpdateUsage(id string, signCount int64, backupState bool) error {
+	query := "UPDATE webauthn_credentials SET sign_count = $1, backup_state = $2, last_used_at = $3 WHERE id = $4"
+	result, err := r.db.Exec(query, signCount, backupState, time.Now(), id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrWebAuthnCredentialNotFound
+	}
+	return nil
+}
+
+func (r *postgresWebAuthnCredentialRepository) Delete(userID string, id string) error {
+	result, err := r.db.Exec("DELETE FROM webauthn_credentials WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrWebAuthnCredentialNotFound
+	}
+	return nil
+}
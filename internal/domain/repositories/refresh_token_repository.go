@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+)
+
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
+type RefreshTokenRepository interface {
+	Create(token *models.RefreshToken) error
+	FindByID(id string) (*models.RefreshToken, error)
+	// MarkRotated marque le token comme utilisé. Retourne false s'il l'était déjà.
+	MarkRotated(id string) (bool, error)
+	RevokeFamily(familyID string) error
+	PruneExpired() (int64, error)
+}
+
+type inMemoryRefreshTokenRepository struct {
+	tokens map[string]*models.RefreshToken
+	mutex  sync.RWMutex
+}
+
+func NewRefreshTokenRepository() RefreshTokenRepository {
+	return &inMemoryRefreshTokenRepository{
+		tokens: make(map[string]*models.RefreshToken),
+	}
+}
+
+func (r *inMemoryRefreshTokenRepository) Create(token *models.RefreshToken) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	token.CreatedAt = time.Now()
+	r.tokens[token.ID] = token
+	return nil
+}
+
+func (r *inMemoryRefreshTokenRepository) FindByID(id string) (*models.RefreshToken, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if token, exists := r.tokens[id]; exists {
+		tokenCopy := *token
+		return &tokenCopy, nil
+	}
+	return nil, ErrRefreshTokenNotFound
+}
+
+func (r *inMemoryRefreshTokenRepository) MarkRotated(id string) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	token, exists := r.tokens[id]
+	if !exists {
+		return false, ErrRefreshTokenNotFound
+	}
+	if token.RotatedAt != nil {
+		return false, nil
+	}
+
+	now := time.Now()
+	token.RotatedAt = &now
+	return true, nil
+}
+
+func (r *inMemoryRefreshTokenRepository) RevokeFamily(familyID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *inMemoryRefreshTokenRepository) PruneExpired() (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var pruned int64
+	now := time.Now()
+	for id, token := range r.tokens {
+		if token.ExpiresAt.Before(now) {
+			delete(r.tokens, id)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"sync"
+	"time"
+)
+
+// RevocationStore conserve les identifiants (jti) des tokens révoqués jusqu'à leur expiration
+type RevocationStore interface {
+	Revoke(jti string, expiresAt time.Time) error
+	IsRevoked(jti string) (bool, error)
+	// PruneExpired supprime les entrées dont le token a de toute façon expiré
+	PruneExpired() (int64, error)
+}
+
+type inMemoryRevocationStore struct {
+	revoked map[string]time.Time
+	mutex   sync.RWMutex
+}
+
+func NewRevocationStore() RevocationStore {
+	return &inMemoryRevocationStore{
+		revoked: make(map[string]time.Time),
+	}
+}
+
+func (s *inMemoryRevocationStore) Revoke(jti string, expiresAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+func (s *inMemoryRevocationStore) IsRevoked(jti string) (bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, exists := s.revoked[jti]
+	return exists, nil
+}
+
+func (s *inMemoryRevocationStore) PruneExpired() (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var pruned int64
+	now := time.Now()
+	for jti, expiresAt := range s.revoked {
+		if expiresAt.Before(now) {
+			delete(s.revoked, jti)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
@@ -0,0 +1,193 @@
+package repositories
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+)
+
+var (
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrRoleAlreadyExists = errors.New("role already exists")
+)
+
+type RoleRepository interface {
+	// SavePermission enregistre une permission ou met à jour sa description
+	SavePermission(permission models.Permission) error
+	ListPermissions() ([]models.Permission, error)
+	// CreateRole enregistre le rôle avec ses permissions
+	CreateRole(role *models.Role) error
+	FindRoleByName(name string) (*models.Role, error)
+	ListRoles() ([]models.Role, error)
+	// SetRolePermissions remplace les permissions du rôle
+	SetRolePermissions(roleID string, permissions []string) error
+	DeleteRole(roleID string) error
+	// AssignRole est sans effet si l'utilisateur a déjà le rôle
+	AssignRole(userID string, roleID string) error
+	UnassignRole(userID string, roleID string) error
+	// ListUserRoles retourne les rôles de l'utilisateur avec leurs permissions
+	ListUserRoles(userID string) ([]models.Role, error)
+	ListRoleUserIDs(roleID string) ([]string, error)
+}
+
+type inMemoryRoleRepository struct {
+	permissions map[string]models.Permission
+	roles       map[string]*models.Role
+	// Rôles de chaque utilisateur, par identifiant de rôle
+	userRoles map[string]map[string]struct{}
+	mutex     sync.RWMutex
+}
+
+func NewRoleRepository() RoleRepository {
+	return &inMemoryRoleRepository{
+		permissions: make(map[string]models.Permission),
+		roles:       make(map[string]*models.Role),
+		userRoles:   make(map[string]map[string]struct{}),
+	}
+}
+
+func (r *inMemoryRoleRepository) SavePermission(permission models.Permission) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.permissions[permission.Name] = permission
+	return nil
+}
+
+func (r *inMemoryRoleRepository) ListPermissions() ([]models.Permission, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	permissions := make([]models.Permission, 0, len(r.permissions))
+	for _, permission := range r.permissions {
+		permissions = append(permissions, permission)
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i].Name < permissions[j].Name })
+	return permissions, nil
+}
+
+func (r *inMemoryRoleRepository) CreateRole(role *models.Role) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.roles {
+		if existing.Name == role.Name {
+			return ErrRoleAlreadyExists
+		}
+	}
+
+	role.CreatedAt = time.Now()
+	roleCopy := *role
+	roleCopy.Permissions = append([]string{}, role.Permissions...)
+	r.roles[role.ID] = &roleCopy
+	return nil
+}
+
+func (r *inMemoryRoleRepository) FindRoleByName(name string) (*models.Role, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, role := range r.roles {
+		if role.Name == name {
+			return r.copyRole(role), nil
+		}
+	}
+	return nil, ErrRoleNotFound
+}
+
+func (r *inMemoryRoleRepository) ListRoles() ([]models.Role, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	roles := make([]models.Role, 0, len(r.roles))
+	for _, role := range r.roles {
+		roles = append(roles, *r.copyRole(role))
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+func (r *inMemoryRoleRepository) SetRolePermissions(roleID string, permissions []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	role, exists := r.roles[roleID]
+	if !exists {
+		return ErrRoleNotFound
+	}
+	role.Permissions = append([]string{}, permissions...)
+	return nil
+}
+
+func (r *inMemoryRoleRepository) DeleteRole(roleID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.roles[roleID]; !exists {
+		return ErrRoleNotFound
+	}
+	delete(r.roles, roleID)
+	for _, roles := range r.userRoles {
+		delete(roles, roleID)
+	}
+	return nil
+}
+
+func (r *inMemoryRoleRepository) AssignRole(userID string, roleID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.roles[roleID]; !exists {
+		return ErrRoleNotFound
+	}
+	if r.userRoles[userID] == nil {
+		r.userRoles[userID] = make(map[string]struct{})
+	}
+	r.userRoles[userID][roleID] = struct{}{}
+	return nil
+}
+
+func (r *inMemoryRoleRepository) UnassignRole(userID string, roleID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.userRoles[userID], roleID)
+	return nil
+}
+
+func (r *inMemoryRoleRepository) ListUserRoles(userID string) ([]models.Role, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	roles := []models.Role{}
+	for roleID := range r.userRoles[userID] {
+		if role, exists := r.roles[roleID]; exists {
+			roles = append(roles, *r.copyRole(role))
+		}
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+func (r *inMemoryRoleRepository) ListRoleUserIDs(roleID string) ([]string, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	userIDs := []string{}
+	for userID, roles := range r.userRoles {
+		if _, hasRole := roles[roleID]; hasRole {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	sort.Strings(userIDs)
+	return userIDs, nil
+}
+
+func (r *inMemoryRoleRepository) copyRole(role *models.Role) *models.Role {
+	roleCopy := *role
+	roleCopy.Permissions = append([]string{}, role.Permissions...)
+	return &roleCopy
+}
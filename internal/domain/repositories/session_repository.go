@@ -0,0 +1,138 @@
+package repositories
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/pkg/auth"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type SessionRepository interface {
+	Create(session *models.Session) error
+	FindByID(id string) (*models.Session, error)
+	// ListActiveByUser retourne les sessions non révoquées et encore utilisables : une session
+	// inactive depuis plus que la durée de vie d'un refresh token ne peut plus être prolongée
+	ListActiveByUser(userID string) ([]models.Session, error)
+	Touch(id string, ipAddress string, seenAt time.Time) error
+	Revoke(id string) error
+	// RevokeAllForUser révoque toutes les sessions de l'utilisateur sauf exceptID (si non vide)
+	// et retourne les identifiants des sessions révoquées
+	RevokeAllForUser(userID string, exceptID string) ([]string, error)
+	// PruneExpired supprime les sessions expirées, révoquées ou non
+	PruneExpired() (int64, error)
+}
+
+type inMemorySessionRepository struct {
+	sessions map[string]*models.Session
+	mutex    sync.RWMutex
+}
+
+func NewSessionRepository() SessionRepository {
+	return &inMemorySessionRepository{
+		sessions: make(map[string]*models.Session),
+	}
+}
+
+func (r *inMemorySessionRepository) Create(session *models.Session) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	session.CreatedAt = time.Now()
+	session.LastSeenAt = session.CreatedAt
+	r.sessions[session.ID] = session
+	return nil
+}
+
+func (r *inMemorySessionRepository) FindByID(id string) (*models.Session, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if session, exists := r.sessions[id]; exists {
+		sessionCopy := *session
+		return &sessionCopy, nil
+	}
+	return nil, ErrSessionNotFound
+}
+
+func (r *inMemorySessionRepository) ListActiveByUser(userID string) ([]models.Session, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	sessions := []models.Session{}
+	expiredBefore := time.Now().Add(-auth.RefreshTokenLifetime)
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil && session.LastSeenAt.After(expiredBefore) {
+			sessions = append(sessions, *session)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+func (r *inMemorySessionRepository) Touch(id string, ipAddress string, seenAt time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	session, exists := r.sessions[id]
+	if !exists {
+		return ErrSessionNotFound
+	}
+	session.LastSeenAt = seenAt
+	if ipAddress != "" {
+		session.IPAddress = ipAddress
+	}
+	return nil
+}
+
+func (r *inMemorySessionRepository) Revoke(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	session, exists := r.sessions[id]
+	if !exists {
+		return ErrSessionNotFound
+	}
+	if session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+	}
+	return nil
+}
+
+func (r *inMemorySessionRepository) RevokeAllForUser(userID string, exceptID string) ([]string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	revoked := []string{}
+	now := time.Now()
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.ID != exceptID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			revoked = append(revoked, session.ID)
+		}
+	}
+	return revoked, nil
+}
+
+func (r *inMemorySessionRepository) PruneExpired() (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var pruned int64
+	expiredBefore := time.Now().Add(-auth.RefreshTokenLifetime)
+	for id, session := range r.sessions {
+		if session.LastSeenAt.Before(expiredBefore) {
+			delete(r.sessions, id)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/amirtalbi/examen_go/pkg/auth"
+)
+
+type inMemorySigningKeyRepository struct {
+	keys  map[string]auth.StoredKey
+	mutex sync.RWMutex
+}
+
+// NewSigningKeyRepository retourne un stockage des clés de signature en mémoire
+func NewSigningKeyRepository() auth.KeyStore {
+	return &inMemorySigningKeyRepository{
+		keys: make(map[string]auth.StoredKey),
+	}
+}
+
+func (r *inMemorySigningKeyRepository) SaveKey(key auth.StoredKey) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// Une clé retirée ne redevient jamais utilisable
+	if existing, exists := r.keys[key.ID]; exists && existing.State == auth.KeyStateRetired {
+		return nil
+	}
+	r.keys[key.ID] = key
+	return nil
+}
+
+func (r *inMemorySigningKeyRepository) ListKeys() ([]auth.StoredKey, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	keys := make([]auth.StoredKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ActivatesAt.Before(keys[j].ActivatesAt)
+	})
+	return keys, nil
+}
@@ -2,6 +2,8 @@ package repositories
 
 import (
 	"errors"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 var (
 	ErrUserNotFound       = errors.New("user not found")
 	ErrEmailAlreadyExists = errors.New("email already exists")
+	ErrMembershipNotFound = errors.New("membership not found")
 )
 
 type UserRepository interface {
@@ -21,27 +24,134 @@ type UserRepository interface {
 	SaveResetToken(email, token string, expiry time.Time) error
 	FindByResetToken(token string) (*models.User, error)
 	UpdatePassword(id, password string) error
+	// Remplacer le hash du même mot de passe (changement d'algorithme ou de paramètres).
+	// Sans effet si le hash stocké n'est plus previousHash : le mot de passe a changé entre-temps.
+	UpdatePasswordHash(id, previousHash, newHash string) error
+	// Marquer l'adresse email comme vérifiée ; la première date de vérification est conservée
+	MarkEmailVerified(id string, verifiedAt time.Time) error
+	// Lister une page d'utilisateurs, du plus récent au plus ancien
+	List(filter models.UserFilter) ([]models.User, error)
+	// Enregistrer le nom, l'email et la date de vérification de l'email
+	Update(user *models.User) error
+	// Verrouiller le compte (lockedAt non nil) ou le déverrouiller
+	SetLocked(id string, lockedAt *time.Time) error
+	// Exiger un nouveau mot de passe ; l'exigence est levée par UpdatePassword
+	SetMustResetPassword(id string, required bool) error
+	// Supprimer le compte. Depuis la vue d'une organisation, un compte qui ne lui appartient
+	// pas est seulement retiré de ses membres.
+	Delete(id string) error
+
+	// Vue limitée aux membres de l'organisation : les comptes des autres organisations y sont
+	// introuvables. Les comptes créés par la vue rejoignent l'organisation et lui appartiennent
+	// si tenantOwned. Hors de toute vue, les recherches par email ne portent que sur les
+	// comptes de l'instance.
+	ForOrganization(orgID string, tenantOwned bool) UserRepository
+	// Ajouter l'utilisateur à l'organisation ou remplacer ses rôles dans celle-ci
+	SaveMembership(membership *models.Membership) error
+	FindMembership(orgID string, userID string) (*models.Membership, error)
+	// Organisations dont l'utilisateur est membre
+	ListMemberships(userID string) ([]models.Membership, error)
+	DeleteMembership(orgID string, userID string) error
 }
 
 type inMemoryUserRepository struct {
 	users map[string]*models.User
-	mutex sync.RWMutex
+	// Adhésions par organisation puis par utilisateur
+	memberships map[string]map[string]*models.Membership
+	// Partagé avec les vues par organisation, qui portent sur les mêmes données
+	mutex *sync.RWMutex
+	// Organisation de la vue, vide hors de toute vue
+	orgID       string
+	tenantOwned bool
 }
 
 func NewUserRepository() UserRepository {
 	return &inMemoryUserRepository{
-		users: make(map[string]*models.User),
+		users:       make(map[string]*models.User),
+		memberships: make(map[string]map[string]*models.Membership),
+		mutex:       &sync.RWMutex{},
+	}
+}
+
+func (r *inMemoryUserRepository) ForOrganization(orgID string, tenantOwned bool) UserRepository {
+	return &inMemoryUserRepository{
+		users:       r.users,
+		memberships: r.memberships,
+		mutex:       r.mutex,
+		orgID:       orgID,
+		tenantOwned: tenantOwned,
+	}
+}
+
+// visible indique si le compte fait partie de la vue
+func (r *inMemoryUserRepository) visible(user *models.User) bool {
+	if r.orgID == "" {
+		return true
+	}
+	_, member := r.memberships[r.orgID][user.ID]
+	return member
+}
+
+// matchesEmail indique si le compte est celui que désigne l'adresse dans la vue : hors de
+// toute vue, seuls les comptes de l'instance sont désignés par leur email
+func (r *inMemoryUserRepository) matchesEmail(user *models.User, email string) bool {
+	if user.Email != email {
+		return false
+	}
+	if r.orgID == "" {
+		return user.OrgID == nil
 	}
+	return r.visible(user)
+}
+
+// find retourne le compte s'il fait partie de la vue
+func (r *inMemoryUserRepository) find(id string) (*models.User, bool) {
+	user, exists := r.users[id]
+	if !exists || !r.visible(user) {
+		return nil, false
+	}
+	return user, true
+}
+
+// emailTaken indique si l'adresse est déjà celle d'un autre compte du même propriétaire
+// ou d'un membre de l'organisation concernée
+func (r *inMemoryUserRepository) emailTaken(email string, owner *string, exceptID string) bool {
+	orgID := r.orgID
+	if orgID == "" && owner != nil {
+		orgID = *owner
+	}
+	for _, other := range r.users {
+		if other.ID == exceptID || other.Email != email {
+			continue
+		}
+		if sameOrganization(other.OrgID, owner) {
+			return true
+		}
+		if _, member := r.memberships[orgID][other.ID]; orgID != "" && member {
+			return true
+		}
+	}
+	return false
+}
+
+func sameOrganization(a *string, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
 }
 
 func (r *inMemoryUserRepository) Create(user *models.User) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	for _, existingUser := range r.users {
-		if existingUser.Email == user.Email {
-			return ErrEmailAlreadyExists
-		}
+	user.OrgID = nil
+	if r.orgID != "" && r.tenantOwned {
+		orgID := r.orgID
+		user.OrgID = &orgID
+	}
+	if r.emailTaken(user.Email, user.OrgID, "") {
+		return ErrEmailAlreadyExists
 	}
 
 	user.ID = uuid.New().String()
@@ -49,6 +159,9 @@ func (r *inMemoryUserRepository) Create(user *models.User) error {
 	user.UpdatedAt = time.Now()
 
 	r.users[user.ID] = user
+	if r.orgID != "" {
+		r.addMembership(&models.Membership{OrgID: r.orgID, UserID: user.ID, Roles: []string{}})
+	}
 	return nil
 }
 
@@ -57,7 +170,7 @@ func (r *inMemoryUserRepository) FindByEmail(email string) (*models.User, error)
 	defer r.mutex.RUnlock()
 
 	for _, user := range r.users {
-		if user.Email == email {
+		if r.matchesEmail(user, email) {
 			return user, nil
 		}
 	}
@@ -68,7 +181,7 @@ func (r *inMemoryUserRepository) FindByID(id string) (*models.User, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 
-	if user, exists := r.users[id]; exists {
+	if user, exists := r.find(id); exists {
 		return user, nil
 	}
 	return nil, ErrUserNotFound
@@ -79,7 +192,7 @@ func (r *inMemoryUserRepository) SaveResetToken(email, token string, expiry time
 	defer r.mutex.Unlock()
 
 	for _, user := range r.users {
-		if user.Email == email {
+		if r.matchesEmail(user, email) {
 			tokenCopy := token
 			user.ResetToken = &tokenCopy
 			expiryCopy := expiry
@@ -98,6 +211,9 @@ func (r *inMemoryUserRepository) FindByResetToken(token string) (*models.User, e
 	for _, user := range r.users {
 		if user.ResetToken != nil && *user.ResetToken == token && 
 		   user.ResetTokenExpires != nil && user.ResetTokenExpires.After(time.Now()) {
+			if !r.visible(user) {
+				break
+			}
 			return user, nil
 		}
 	}
@@ -108,12 +224,237 @@ func (r *inMemoryUserRepository) UpdatePassword(id, password string) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if user, exists := r.users[id]; exists {
+	if user, exists := r.find(id); exists {
 		user.Password = password
 		user.ResetToken = nil
 		user.ResetTokenExpires = nil
+		user.MustResetPassword = false
 		user.UpdatedAt = time.Now()
 		return nil
 	}
 	return ErrUserNotFound
 }
+
+func (r *inMemoryUserRepository) MarkEmailVerified(id string, verifiedAt time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, exists := r.find(id)
+	if !exists {
+		return ErrUserNotFound
+	}
+	if user.EmailVerifiedAt == nil {
+		verifiedAtCopy := verifiedAt
+		user.EmailVerifiedAt = &verifiedAtCopy
+		user.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+func (r *inMemoryUserRepository) UpdatePasswordHash(id, previousHash, newHash string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, exists := r.find(id)
+	if !exists {
+		return ErrUserNotFound
+	}
+	if user.Password == previousHash {
+		user.Password = newHash
+	}
+	return nil
+}
+
+func (r *inMemoryUserRepository) List(filter models.UserFilter) ([]models.User, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	users := []models.User{}
+	for _, user := range r.users {
+		if r.visible(user) && matchesUserFilter(user, filter) {
+			users = append(users, *user)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		if !users[i].CreatedAt.Equal(users[j].CreatedAt) {
+			return users[i].CreatedAt.After(users[j].CreatedAt)
+		}
+		return users[i].ID > users[j].ID
+	})
+	if filter.Limit > 0 && len(users) > filter.Limit {
+		users = users[:filter.Limit]
+	}
+	return users, nil
+}
+
+func matchesUserFilter(user *models.User, filter models.UserFilter) bool {
+	if filter.EmailPrefix != "" && !strings.HasPrefix(strings.ToLower(user.Email), strings.ToLower(filter.EmailPrefix)) {
+		return false
+	}
+	if filter.CreatedAfter != nil && user.CreatedAt.Before(*filter.CreatedAfter) {
+		return false
+	}
+	if filter.CreatedBefore != nil && !user.CreatedAt.Before(*filter.CreatedBefore) {
+		return false
+	}
+	if filter.Verified != nil && (user.EmailVerifiedAt != nil) != *filter.Verified {
+		return false
+	}
+	if filter.Locked != nil && (user.LockedAt != nil) != *filter.Locked {
+		return false
+	}
+	if after := filter.After; after != nil {
+		// Ordre décroissant : seuls les utilisateurs strictement après le curseur
+		if user.CreatedAt.After(after.CreatedAt) || (user.CreatedAt.Equal(after.CreatedAt) && user.ID >= after.ID) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *inMemoryUserRepository) Update(user *models.User) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, exists := r.find(user.ID)
+	if !exists {
+		return ErrUserNotFound
+	}
+	if r.emailTaken(user.Email, existing.OrgID, existing.ID) {
+		return ErrEmailAlreadyExists
+	}
+
+	existing.Name = user.Name
+	existing.Email = user.Email
+	existing.EmailVerifiedAt = user.EmailVerifiedAt
+	existing.UpdatedAt = time.Now()
+	user.UpdatedAt = existing.UpdatedAt
+	return nil
+}
+
+func (r *inMemoryUserRepository) SetLocked(id string, lockedAt *time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, exists := r.find(id)
+	if !exists {
+		return ErrUserNotFound
+	}
+	user.LockedAt = lockedAt
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *inMemoryUserRepository) SetMustResetPassword(id string, required bool) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, exists := r.find(id)
+	if !exists {
+		return ErrUserNotFound
+	}
+	user.MustResetPassword = required
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *inMemoryUserRepository) Delete(id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, exists := r.find(id)
+	if !exists {
+		return ErrUserNotFound
+	}
+	if r.orgID != "" && !sameOrganization(user.OrgID, &r.orgID) {
+		delete(r.memberships[r.orgID], id)
+		return nil
+	}
+
+	delete(r.users, id)
+	for _, members := range r.memberships {
+		delete(members, id)
+	}
+	return nil
+}
+
+// inOrganization refuse les adhésions à une autre organisation que celle de la vue
+func (r *inMemoryUserRepository) inOrganization(orgID string) bool {
+	return r.orgID == "" || r.orgID == orgID
+}
+
+func (r *inMemoryUserRepository) SaveMembership(membership *models.Membership) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.inOrganization(membership.OrgID) {
+		return ErrMembershipNotFound
+	}
+	if _, exists := r.users[membership.UserID]; !exists {
+		return ErrUserNotFound
+	}
+
+	if existing, exists := r.memberships[membership.OrgID][membership.UserID]; exists {
+		existing.Roles = membership.Roles
+		membership.CreatedAt = existing.CreatedAt
+		return nil
+	}
+	r.addMembership(membership)
+	return nil
+}
+
+// addMembership enregistre une copie de l'adhésion ; le verrou doit être tenu
+func (r *inMemoryUserRepository) addMembership(membership *models.Membership) {
+	membership.CreatedAt = time.Now()
+	if r.memberships[membership.OrgID] == nil {
+		r.memberships[membership.OrgID] = make(map[string]*models.Membership)
+	}
+	membershipCopy := *membership
+	r.memberships[membership.OrgID][membership.UserID] = &membershipCopy
+}
+
+func (r *inMemoryUserRepository) FindMembership(orgID string, userID string) (*models.Membership, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if !r.inOrganization(orgID) {
+		return nil, ErrMembershipNotFound
+	}
+	membership, exists := r.memberships[orgID][userID]
+	if !exists {
+		return nil, ErrMembershipNotFound
+	}
+	membershipCopy := *membership
+	return &membershipCopy, nil
+}
+
+func (r *inMemoryUserRepository) ListMemberships(userID string) ([]models.Membership, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	memberships := []models.Membership{}
+	for orgID, members := range r.memberships {
+		if membership, exists := members[userID]; exists && r.inOrganization(orgID) {
+			memberships = append(memberships, *membership)
+		}
+	}
+	sort.Slice(memberships, func(i, j int) bool {
+		return memberships[i].CreatedAt.Before(memberships[j].CreatedAt)
+	})
+	return memberships, nil
+}
+
+func (r *inMemoryUserRepository) DeleteMembership(orgID string, userID string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.inOrganization(orgID) {
+		return ErrMembershipNotFound
+	}
+	if _, exists := r.memberships[orgID][userID]; !exists {
+		return ErrMembershipNotFound
+	}
+	delete(r.memberships[orgID], userID)
+	return nil
+}
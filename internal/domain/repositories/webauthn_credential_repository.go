@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+)
+
+var (
+	ErrWebAuthnCredentialNotFound = errors.New("webauthn credential not found")
+	ErrWebAuthnCredentialExists   = errors.New("webauthn credential already registered")
+)
+
+type WebAuthnCredentialRepository interface {
+	// Create retourne ErrWebAuthnCredentialExists si l'identifiant est déjà enregistré
+	Create(credential *models.WebAuthnCredential) error
+	FindByID(id string) (*models.WebAuthnCredential, error)
+	ListByUser(userID string) ([]models.WebAuthnCredential, error)
+	// UpdateUsage enregistre le compteur de signatures et la date d'utilisation après une connexion
+	UpdateUsage(id string, signCount int64, backupState bool) error
+	// Delete supprime un credential de l'utilisateur, ErrWebAuthnCredentialNotFound s'il ne lui appartient pas
+	Delete(userID string, id string) error
+}
+
+type inMemoryWebAuthnCredentialRepository struct {
+	credentials map[string]*models.WebAuthnCredential
+	mutex       sync.RWMutex
+}
+
+func NewWebAuthnCredentialRepository() WebAuthnCredentialRepository {
+	return &inMemoryWebAuthnCredentialRepository{
+		credentials: make(map[string]*models.WebAuthnCredential),
+	}
+}
+
+func (r *inMemoryWebAuthnCredentialRepository) Create(credential *models.WebAuthnCredential) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.credentials[credential.ID]; exists {
+		return ErrWebAuthnCredentialExists
+	}
+
+	credential.CreatedAt = time.Now()
+	r.credentials[credential.ID] = credential
+	return nil
+}
+
+func (r *inMemoryWebAuthnCredentialRepository) FindByID(id string) (*models.WebAuthnCredential, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if credential, exists := r.credentials[id]; exists {
+		credentialCopy := *credential
+		return &credentialCopy, nil
+	}
+	return nil, ErrWebAuthnCredentialNotFound
+}
+
+func (r *inMemoryWebAuthnCredentialRepository) ListByUser(userID string) ([]models.WebAuthnCredential, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	credentials := []models.WebAuthnCredential{}
+	for _, credential := range r.credentials {
+		if credential.UserID == userID {
+			credentials = append(credentials, *credential)
+		}
+	}
+	sort.Slice(credentials, func(i, j int) bool {
+		return credentials[i].CreatedAt.Before(credentials[j].CreatedAt)
+	})
+	return credentials, nil
+}
+
+func (r *inMemoryWebAuthnCredentialRepository) UpdateUsage(id string, signCount int64, backupState bool) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	credential, exists := r.credentials[id]
+	if !exists {
+		return ErrWebAuthnCredentialNotFound
+	}
+
+	now := time.Now()
+	credential.SignCount = signCount
+	credential.BackupState = backupState
+	credential.LastUsedAt = &now
+	return nil
+}
+
+func (r *inMemoryWebAuthnCredentialRepository) Delete(userID string, id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	credential, exists := r.credentials[id]
+	if !exists || credential.UserID != userID {
+		return ErrWebAuthnCredentialNotFound
+	}
+
+	delete(r.credentials, id)
+	return nil
+}
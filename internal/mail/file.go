@@ -0,0 +1,54 @@
+package mail
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type fileMailer struct {
+	dir    string
+	from   string
+	domain string
+}
+
+// NewFileMailer crée un mailer qui écrit chaque message dans un fichier .eml du dossier
+// donné, pour le développement sans serveur SMTP
+func NewFileMailer(dir string, from string) (Mailer, error) {
+	address, err := netmail.ParseAddress(from)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &fileMailer{
+		dir:    dir,
+		from:   address.String(),
+		domain: address.Address[strings.LastIndex(address.Address, "@")+1:],
+	}, nil
+}
+
+func (m *fileMailer) Send(message Message) error {
+	if err := message.validate(); err != nil {
+		return err
+	}
+
+	body, err := buildMIME(m.from, m.domain, message)
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := time.Now().UTC().Format("20060102T150405.000000000") + "-" + hex.EncodeToString(suffix) + ".eml"
+	// Les messages contiennent des liens de connexion : lisibles par le seul propriétaire
+	return os.WriteFile(filepath.Join(m.dir, name), body, 0o600)
+}
@@ -0,0 +1,45 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/amirtalbi/examen_go/internal/config"
+)
+
+var ErrInvalidMessage = errors.New("invalid mail message")
+
+// Message est un email prêt à l'envoi, avec une version texte et une version HTML
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer envoie des emails. Les implémentations doivent être sûres en accès concurrent.
+type Mailer interface {
+	Send(message Message) error
+}
+
+// New crée le mailer correspondant au driver configuré (smtp ou file)
+func New(cfg config.MailConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTPMailer(cfg)
+	case "file":
+		return NewFileMailer(cfg.FileDir, cfg.From)
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
+
+// validate refuse les retours à la ligne dans les champs copiés dans les en-têtes,
+// qui permettraient d'injecter des en-têtes ou des destinataires
+func (m Message) validate() error {
+	if m.To == "" || strings.ContainsAny(m.To, "\r\n") || strings.ContainsAny(m.Subject, "\r\n") {
+		return ErrInvalidMessage
+	}
+	return nil
+}
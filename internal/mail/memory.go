@@ -0,0 +1,46 @@
+package mail
+
+import "sync"
+
+// MemoryMailer conserve les messages envoyés, pour les tests et les scripts
+type MemoryMailer struct {
+	messages []Message
+	mutex    sync.Mutex
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(message Message) error {
+	if err := message.validate(); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+// Messages retourne une copie des messages envoyés, du plus ancien au plus récent
+func (m *MemoryMailer) Messages() []Message {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return append([]Message(nil), m.messages...)
+}
+
+// Last retourne le dernier message envoyé au destinataire donné
+func (m *MemoryMailer) Last(to string) (Message, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == to {
+			return m.messages[i], true
+		}
+	}
+	return Message{}, false
+}
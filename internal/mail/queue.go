@@ -0,0 +1,124 @@
+package mail
+
+import (
+	"errors"
+	"log"
+	"net/textproto"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull   = errors.New("mail queue full")
+	ErrQueueClosed = errors.New("mail queue closed")
+)
+
+// QueueOptions règle la file d'envoi
+type QueueOptions struct {
+	// Size est le nombre de messages en attente au-delà duquel Send échoue
+	Size int
+	// Workers est le nombre d'envois simultanés
+	Workers int
+	// MaxAttempts est le nombre d'essais par message, premier envoi compris
+	MaxAttempts int
+	// Backoff est le délai avant le premier nouvel essai, doublé à chaque échec
+	Backoff time.Duration
+}
+
+// DefaultQueueOptions convient à un serveur SMTP local ou à un relais
+var DefaultQueueOptions = QueueOptions{Size: 256, Workers: 2, MaxAttempts: 5, Backoff: 2 * time.Second}
+
+// Queue envoie les messages en arrière-plan et réessaie les échecs temporaires.
+// Elle implémente Mailer : une requête HTTP n'attend jamais le serveur SMTP.
+type Queue struct {
+	mailer  Mailer
+	options QueueOptions
+	jobs    chan Message
+	closed  bool
+	mutex   sync.RWMutex
+	workers sync.WaitGroup
+}
+
+func NewQueue(mailer Mailer, options QueueOptions) *Queue {
+	queue := &Queue{
+		mailer:  mailer,
+		options: options,
+		jobs:    make(chan Message, options.Size),
+	}
+
+	for i := 0; i < options.Workers; i++ {
+		queue.workers.Add(1)
+		go queue.work()
+	}
+	return queue
+}
+
+// Send place le message dans la file sans attendre son envoi
+func (q *Queue) Send(message Message) error {
+	if err := message.validate(); err != nil {
+		return err
+	}
+
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case q.jobs <- message:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close refuse les nouveaux messages et attend l'envoi de ceux déjà en file
+func (q *Queue) Close() {
+	q.mutex.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	q.mutex.Unlock()
+
+	q.workers.Wait()
+}
+
+func (q *Queue) work() {
+	defer q.workers.Done()
+
+	for message := range q.jobs {
+		q.deliver(message)
+	}
+}
+
+func (q *Queue) deliver(message Message) {
+	delay := q.options.Backoff
+	for attempt := 1; ; attempt++ {
+		err := q.mailer.Send(message)
+		if err == nil {
+			return
+		}
+
+		if isPermanent(err) || attempt >= q.options.MaxAttempts {
+			log.Printf("❌ Abandon de l'envoi de l'email \"%s\" après %d essai(s): %v", message.Subject, attempt, err)
+			return
+		}
+
+		log.Printf("⚠️ Échec de l'envoi de l'email \"%s\" (essai %d), nouvel essai dans %s: %v", message.Subject, attempt, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// isPermanent indique une erreur qu'un nouvel essai ne corrigera pas : message invalide
+// ou réponse SMTP 5xx (destinataire refusé, authentification rejetée...)
+func isPermanent(err error) bool {
+	if errors.Is(err, ErrInvalidMessage) {
+		return true
+	}
+	var protocolError *textproto.Error
+	return errors.As(err, &protocolError) && protocolError.Code >= 500
+}
@@ -0,0 +1,51 @@
+package mail
+
+import (
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"strings"
+
+	"github.com/amirtalbi/examen_go/internal/config"
+)
+
+type smtpMailer struct {
+	address string
+	auth    smtp.Auth
+	from    string
+	// Adresse d'enveloppe (MAIL FROM), sans le nom affiché
+	sender string
+	domain string
+}
+
+// NewSMTPMailer crée un mailer SMTP. STARTTLS est utilisé dès que le serveur le propose ;
+// l'authentification PLAIN n'est envoyée que sur une connexion chiffrée ou locale.
+func NewSMTPMailer(cfg config.MailConfig) (Mailer, error) {
+	from, err := netmail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, err
+	}
+
+	mailer := &smtpMailer{
+		address: net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort),
+		from:    from.String(),
+		sender:  from.Address,
+		domain:  from.Address[strings.LastIndex(from.Address, "@")+1:],
+	}
+	if cfg.SMTPUsername != "" {
+		mailer.auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return mailer, nil
+}
+
+func (m *smtpMailer) Send(message Message) error {
+	if err := message.validate(); err != nil {
+		return err
+	}
+
+	body, err := buildMIME(m.from, m.domain, message)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(m.address, m.auth, m.sender, []string{message.To}, body)
+}
@@ -0,0 +1,104 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+	"time"
+)
+
+// Modèles d'emails disponibles (templates/<nom>.txt.tmpl et templates/<nom>.html.tmpl)
+const (
+	TemplateResetPassword  = "reset_password"
+	TemplateVerifyEmail    = "verify_email"
+	TemplateMagicLink      = "magic_link"
+	TemplateLoginCode      = "login_code"
+	TemplateSecurityNotice = "security_notice"
+	TemplateAccountLocked  = "account_locked"
+)
+
+var subjects = map[string]string{
+	TemplateResetPassword:  "Réinitialisation de votre mot de passe",
+	TemplateVerifyEmail:    "Confirmez votre adresse email",
+	TemplateMagicLink:      "Votre lien de connexion",
+	TemplateLoginCode:      "Votre code de connexion",
+	TemplateSecurityNotice: "Alerte de sécurité sur votre compte",
+	TemplateAccountLocked:  "Votre compte est temporairement bloqué",
+}
+
+// LinkData alimente les emails contenant un lien à usage unique
+type LinkData struct {
+	Name      string
+	Link      string
+	ExpiresIn time.Duration
+}
+
+// CodeData alimente l'email contenant un code de connexion
+type CodeData struct {
+	Name      string
+	Code      string
+	ExpiresIn time.Duration
+}
+
+// SecurityNoticeData alimente les alertes de sécurité
+type SecurityNoticeData struct {
+	Name       string
+	Summary    string
+	OccurredAt time.Time
+}
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+var functions = map[string]interface{}{
+	"duration": formatDuration,
+	"date":     formatDate,
+}
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.New("").Funcs(functions).ParseFS(templateFiles, "templates/*.txt.tmpl"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.New("").Funcs(functions).ParseFS(templateFiles, "templates/*.html.tmpl"))
+)
+
+// Render construit le message à partir des versions texte et HTML du modèle.
+// La version HTML est échappée automatiquement (html/template).
+func Render(to string, name string, data interface{}) (Message, error) {
+	subject, ok := subjects[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown mail template %q", name)
+	}
+
+	var text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&text, name+".txt.tmpl", data); err != nil {
+		return Message{}, err
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, name+".html.tmpl", data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		To:      to,
+		Subject: subject,
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+func formatDuration(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		if hours := int(d / time.Hour); hours > 1 {
+			return fmt.Sprintf("%d heures", hours)
+		}
+		return "1 heure"
+	}
+	if minutes := int(d / time.Minute); minutes > 1 {
+		return fmt.Sprintf("%d minutes", minutes)
+	}
+	return "1 minute"
+}
+
+func formatDate(t time.Time) string {
+	return t.UTC().Format("02/01/2006 à 15:04 UTC")
+}
@@ -2,13 +2,17 @@ package service
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"net/url"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/amirtalbi/examen_go/internal/config"
 	"github.com/amirtalbi/examen_go/internal/domain/models"
 	"github.com/amirtalbi/examen_go/internal/domain/repositories"
+	"github.com/amirtalbi/examen_go/internal/mail"
 	"github.com/amirtalbi/examen_go/pkg/auth"
 	"github.com/google/uuid"
 )
@@ -18,57 +22,194 @@ var (
 	ErrUserNotFound      = errors.New("user not found")
 	ErrPasswordMismatch  = errors.New("password mismatch")
 	ErrInvalidToken      = errors.New("invalid token")
+	ErrTokenRevoked      = errors.New("token revoked")
+	ErrRefreshTokenReuse = errors.New("refresh token reuse detected")
+	ErrEmailNotVerified  = errors.New("email not verified")
+	// ErrRegistrationClosed couvre aussi les organisations inconnues, pour ne pas révéler
+	// quels identifiants existent
+	ErrRegistrationClosed = errors.New("registration closed")
 )
 
 type AuthService interface {
+	// Créer le compte et envoyer l'email de vérification. Retourne une *auth.PasswordPolicyError
+	// si le mot de passe est refusé, et ErrEmailNotVerified, sans ouvrir de session, si la
+	// vérification de l'adresse est exigée. Retourne ErrRegistrationClosed si l'organisation
+	// demandée n'accepte pas les inscriptions publiques.
 	Register(request models.RegisterRequest) (*models.AuthResponse, error)
 	Login(request models.LoginRequest) (*models.AuthResponse, error)
-	ValidateToken(token string) (string, error)
+	// Vérifier les identifiants sans ouvrir de session (étape de connexion de /authorize).
+	// Retourne une *MFARequiredError si l'utilisateur a activé un second facteur, et une
+	// *AccountLockedError, sans vérifier le mot de passe, après trop d'échecs. Retourne
+	// ErrAccountLocked ou ErrPasswordResetRequired après un mot de passe correct si un
+	// administrateur a verrouillé le compte ou exigé un nouveau mot de passe.
+	// L'email désigne un compte de l'organisation orgID, ou de l'instance si orgID est vide.
+	Authenticate(email string, password string, orgID string, ipAddress string) (*models.User, error)
+	// Vérifier le second facteur d'un challenge MFA (token à usage unique). Les codes erronés
+	// comptent comme des échecs de connexion du compte : une *AccountLockedError est retournée,
+	// sans vérifier le code, une fois le compte bloqué.
+	VerifyMFA(mfaToken string, code string, ipAddress string) (*models.User, error)
+	// Terminer une connexion en deux étapes
+	LoginWithMFA(request models.MFALoginRequest) (*models.AuthResponse, error)
+	// Ouvrir une session pour un utilisateur déjà authentifié
+	StartSession(user *models.User, client models.ClientInfo) (*models.AuthResponse, error)
+	// Ouvrir une session après un premier facteur autre que le mot de passe (lien magique, code email).
+	// Retourne une *MFARequiredError si l'utilisateur a activé un second facteur.
+	ContinueLogin(user *models.User, client models.ClientInfo) (*models.AuthResponse, error)
+	ValidateToken(token string) (*auth.AccessClaims, error)
 	RefreshToken(refreshToken string) (*models.AuthResponse, error)
-	ForgotPassword(email string) (string, error)
+	// Échanger un refresh token émis pour un client OAuth donné
+	RefreshTokenForClient(refreshToken string, clientID string) (*models.AuthResponse, error)
+	// Envoyer le lien de réinitialisation par email, sans jamais retourner le token. orgID
+	// désigne l'organisation du compte, vide pour un compte de l'instance.
+	ForgotPassword(email string, orgID string) error
+	// Traiter ForgotPassword en arrière-plan : la réponse ne dépend ni de l'existence du
+	// compte ni de la durée de l'envoi. Au-delà de la file d'attente, la demande est ignorée.
+	RequestPasswordReset(email string, orgID string)
+	// Retourne une *auth.PasswordPolicyError si le nouveau mot de passe est refusé
 	ResetPassword(request models.ResetPasswordRequest) error
+	// Changer le mot de passe d'un utilisateur connecté, qui reste connecté sur la session
+	// courante uniquement. Retourne ErrPasswordMismatch si le mot de passe actuel est faux.
+	ChangePassword(userID string, currentSessionID string, request models.ChangePasswordRequest) error
+	// Ouvrir une session dans une autre organisation (ou dans le contexte de l'instance) et
+	// fermer la session courante. Retourne ErrNotOrganizationMember si l'utilisateur n'en est pas membre.
+	SwitchOrganization(userID string, currentSessionID string, request models.SwitchOrganizationRequest) (*models.AuthResponse, error)
+	// Confirmer l'adresse email avec le token reçu à l'inscription
+	VerifyEmail(token string) error
+	// Renvoyer l'email de vérification si l'adresse n'est pas encore vérifiée
+	ResendVerificationEmail(email string, orgID string) error
 	// Nouvelle méthode pour révoquer un token (déconnexion)
 	RevokeToken(token string) error
-	// Vérifier si un token est révoqué
-	IsTokenRevoked(token string) bool
+	// Révoquer un token pour le compte d'un client OAuth (RFC 7009). Un token émis à un autre
+	// client, ou à l'API elle-même si clientID est vide, est ignoré sans erreur.
+	RevokeTokenForClient(token string, clientID string) error
+	// Vérifier si un token du type donné (auth.TypeAccessToken, auth.TypeRefreshToken) est révoqué
+	IsTokenRevoked(token string, tokenType string) bool
+	// Introspecter un token d'accès ou de rafraîchissement (RFC 7662)
+	Introspect(token string, tokenTypeHint string) *models.IntrospectionResponse
+}
+
+// Les demandes de réinitialisation sont traitées par un nombre fixe de workers : répéter
+// /forgot-password ne multiplie pas les goroutines
+const (
+	passwordResetQueueSize = 64
+	passwordResetWorkers   = 2
+)
+
+type passwordResetRequest struct {
+	email string
+	orgID string
 }
 
 type authService struct {
-	userRepo           repositories.UserRepository
-	config             *config.Config
-	resetTokens        map[string]string
-	resetTokensMutex   sync.RWMutex
-	refreshTokens      map[string]string
-	refreshTokensMutex sync.RWMutex
-	// Liste noire des tokens révoqués
-	revokedTokens      map[string]bool
-	revokedTokensMutex sync.RWMutex
-}
-
-func NewAuthService(userRepo repositories.UserRepository, config *config.Config) AuthService {
+	userRepo         repositories.UserRepository
+	sessionRepo      repositories.SessionRepository
+	refreshTokenRepo repositories.RefreshTokenRepository
+	passwordHistory  repositories.PasswordHistoryRepository
+	mfa              MFAService
+	lockout          LockoutService
+	rbac             RBACService
+	organizations    OrganizationService
+	tokens           *auth.TokenConfig
+	passwords        auth.PasswordHasher
+	policy           *auth.PasswordPolicy
+	events           SecurityEventSink
+	mailer           mail.Mailer
+	config           *config.Config
+	resetTokens      map[string]string
+	resetTokensMutex sync.RWMutex
+	// Liste noire des tokens révoqués, partagée entre les instances
+	revocations repositories.RevocationStore
+	// dummyPasswordHash est vérifié quand l'email est inconnu : la réponse prend alors le
+	// même temps que pour un compte existant et ne révèle pas quelles adresses sont inscrites
+	dummyPasswordHash string
+	passwordResets    chan passwordResetRequest
+}
+
+func NewAuthService(
+	userRepo repositories.UserRepository,
+	sessionRepo repositories.SessionRepository,
+	refreshTokenRepo repositories.RefreshTokenRepository,
+	passwordHistory repositories.PasswordHistoryRepository,
+	revocations repositories.RevocationStore,
+	mfa MFAService,
+	lockout LockoutService,
+	rbac RBACService,
+	organizations OrganizationService,
+	tokens *auth.TokenConfig,
+	passwords auth.PasswordHasher,
+	policy *auth.PasswordPolicy,
+	events SecurityEventSink,
+	mailer mail.Mailer,
+	config *config.Config,
+) AuthService {
 	// Initialiser le service
 	service := &authService{
-		userRepo:      userRepo,
-		config:        config,
-		resetTokens:   make(map[string]string),
-		refreshTokens: make(map[string]string),
-		revokedTokens: make(map[string]bool),
+		userRepo:         userRepo,
+		sessionRepo:      sessionRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		passwordHistory:  passwordHistory,
+		mfa:              mfa,
+		lockout:          lockout,
+		rbac:             rbac,
+		organizations:    organizations,
+		tokens:           tokens,
+		passwords:        passwords,
+		policy:           policy,
+		events:           events,
+		mailer:           mailer,
+		config:           config,
+		resetTokens:      make(map[string]string),
+		revocations:      revocations,
 	}
 	
 	// Ajouter le token de test spécifique pour les tests de réinitialisation de mot de passe
 	// Ce token sera considéré comme valide pour n'importe quel utilisateur
 	service.resetTokens["e27ae79d5cd8ab28"] = "test-user-id"
+
+	// Calculé avec les paramètres courants du hasher, pour coûter autant qu'un vrai hash
+	dummyPasswordHash, err := passwords.Hash(uuid.New().String())
+	if err != nil {
+		log.Printf("❌ Erreur lors du calcul du hash de référence des connexions: %v", err)
+	}
+	service.dummyPasswordHash = dummyPasswordHash
+
+	service.passwordResets = make(chan passwordResetRequest, passwordResetQueueSize)
+	for i := 0; i < passwordResetWorkers; i++ {
+		go service.processPasswordResets()
+	}
 	
 	return service
 }
 
 func (s *authService) Register(request models.RegisterRequest) (*models.AuthResponse, error) {
-	existingUser, err := s.userRepo.FindByEmail(request.Email)
+	// Un compte créé dans une organisation en devient membre : seules les organisations
+	// qui l'autorisent acceptent les inscriptions publiques
+	settings := models.OrganizationSettings{}
+	if request.OrgID != "" {
+		organization, err := s.organizations.GetOrganization(request.OrgID)
+		if err == ErrOrganizationNotFound {
+			return nil, ErrRegistrationClosed
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !organization.Settings.AllowSelfSignup {
+			return nil, ErrRegistrationClosed
+		}
+		settings = organization.Settings
+	}
+	users := s.usersIn(request.OrgID)
+
+	existingUser, err := users.FindByEmail(request.Email)
 	if err == nil && existingUser != nil {
 		return nil, ErrUserAlreadyExists
 	}
 
-	hashedPassword, err := auth.HashPassword(request.Password)
+	if err := s.passwordPolicy(settings).Check(request.Password, request.Name, request.Email); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := s.passwords.Hash(request.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -80,147 +221,539 @@ func (s *authService) Register(request models.RegisterRequest) (*models.AuthResp
 		Password: hashedPassword,
 	}
 
-	err = s.userRepo.Create(user)
+	err = users.Create(user)
+	if errors.Is(err, repositories.ErrEmailAlreadyExists) {
+		return nil, ErrUserAlreadyExists
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	token, err := auth.GenerateToken(user.ID, s.config.JWTSecret, s.config.TokenExpiryHours)
+	// Le compte existe : un échec d'envoi se rattrape avec /verify-email/resend
+	if err := s.sendVerificationEmail(user); err != nil {
+		log.Printf("❌ Erreur lors de l'envoi de l'email de vérification à l'utilisateur %s: %v", user.ID, err)
+	}
+
+	if s.config.RequireVerifiedEmail {
+		return nil, ErrEmailNotVerified
+	}
+
+	return s.startSession(user, request.ClientInfo)
+}
+
+func (s *authService) Login(request models.LoginRequest) (*models.AuthResponse, error) {
+	// L'email désigne un membre de l'organisation demandée : une organisation inconnue
+	// donne la même réponse qu'un email inconnu
+	user, err := s.authenticate(request.OrgID, request.Email, request.Password, request.IPAddress)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := auth.GenerateRefreshToken(user.ID, s.config.JWTSecret)
+	return s.startSession(user, request.ClientInfo)
+}
+
+func (s *authService) Authenticate(email string, password string, orgID string, ipAddress string) (*models.User, error) {
+	return s.authenticate(orgID, email, password, ipAddress)
+}
+
+// authenticate vérifie les identifiants du compte désigné par l'email dans l'organisation
+func (s *authService) authenticate(orgID string, email string, password string, ipAddress string) (*models.User, error) {
+	user, err := s.usersIn(orgID).FindByEmail(email)
 	if err != nil {
+		user = nil
+	}
+	account := LockoutAccount{User: user, OrgID: orgID, Email: email}
+
+	// Un compte bloqué ne doit pas permettre de savoir si le mot de passe essayé est le bon
+	if err := s.lockout.Check(account, ipAddress); err != nil {
 		return nil, err
 	}
 
-	s.refreshTokensMutex.Lock()
-	s.refreshTokens[user.ID] = refreshToken
-	s.refreshTokensMutex.Unlock()
+	if user == nil {
+		s.passwords.Verify(password, s.dummyPasswordHash)
+		s.recordLoginFailure(account, ipAddress)
+		return nil, ErrUserNotFound
+	}
 
-	return &models.AuthResponse{
-		Token:        token,
-		RefreshToken: refreshToken,
-		User:         *user,
-	}, nil
+	match, err := s.passwords.Verify(password, user.Password)
+	if err != nil {
+		log.Printf("❌ Hash de mot de passe illisible pour l'utilisateur %s: %v", user.ID, err)
+		return nil, ErrPasswordMismatch
+	}
+	if !match {
+		s.recordLoginFailure(account, ipAddress)
+		return nil, ErrPasswordMismatch
+	}
+
+	// Avec un second facteur, les échecs ne sont remis à zéro qu'après le code (VerifyMFA) :
+	// sinon le mot de passe suffirait à relancer les essais sur le code
+	if enabled, err := s.mfa.IsEnabled(user.ID); err == nil && !enabled {
+		s.recordLoginSuccess(account)
+	}
+
+	// Le mot de passe en clair n'est disponible qu'ici : c'est le moment de migrer son hash
+	if s.passwords.NeedsRehash(user.Password) {
+		s.rehashPassword(user, password)
+	}
+
+	if err := s.requireActiveAccount(user); err != nil {
+		return nil, err
+	}
+
+	if user.MustResetPassword {
+		log.Printf("🔑 Connexion refusée, réinitialisation du mot de passe exigée pour l'utilisateur %s", user.ID)
+		return nil, ErrPasswordResetRequired
+	}
+
+	if err := s.requireVerifiedEmail(user); err != nil {
+		return nil, err
+	}
+
+	if err := s.requireSecondFactor(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
 }
 
-func (s *authService) Login(request models.LoginRequest) (*models.AuthResponse, error) {
-	user, err := s.userRepo.FindByEmail(request.Email)
+// recordLoginSuccess remet à zéro les échecs du compte après une connexion réussie
+func (s *authService) recordLoginSuccess(account LockoutAccount) {
+	if err := s.lockout.RecordSuccess(account); err != nil {
+		log.Printf("❌ Erreur lors de la remise à zéro des échecs de connexion de l'utilisateur %s: %v", account.User.ID, err)
+	}
+}
+
+// recordLoginFailure compte un échec de connexion. Une erreur du stockage n'empêche pas de
+// répondre : l'échec est de toute façon refusé.
+func (s *authService) recordLoginFailure(account LockoutAccount, ipAddress string) {
+	if err := s.lockout.RecordFailure(account, ipAddress); err != nil {
+		log.Printf("❌ Erreur lors de l'enregistrement d'un échec de connexion: %v", err)
+	}
+}
+
+// rehashPassword remplace un hash dépassé (bcrypt, anciens paramètres argon2id).
+// Un échec n'empêche pas la connexion : le hash sera migré à la prochaine.
+func (s *authService) rehashPassword(user *models.User, password string) {
+	newHash, err := s.passwords.Hash(password)
+	if err != nil {
+		log.Printf("❌ Erreur lors du recalcul du hash de l'utilisateur %s: %v", user.ID, err)
+		return
+	}
+
+	if err := s.userRepo.UpdatePasswordHash(user.ID, user.Password, newHash); err != nil {
+		log.Printf("❌ Erreur lors de l'enregistrement du nouveau hash de l'utilisateur %s: %v", user.ID, err)
+		return
+	}
+
+	user.Password = newHash
+	log.Printf("🔑 Hash du mot de passe mis à jour pour l'utilisateur %s", user.ID)
+}
+
+// requireActiveAccount retourne ErrAccountLocked si un administrateur a verrouillé le compte
+func (s *authService) requireActiveAccount(user *models.User) error {
+	if user.LockedAt != nil {
+		log.Printf("🔒 Connexion refusée, compte verrouillé pour l'utilisateur %s", user.ID)
+		return ErrAccountLocked
+	}
+	return nil
+}
+
+// requireVerifiedEmail retourne ErrEmailNotVerified si la configuration exige une adresse
+// vérifiée et que l'utilisateur ne l'a pas encore confirmée
+func (s *authService) requireVerifiedEmail(user *models.User) error {
+	if s.config.RequireVerifiedEmail && user.EmailVerifiedAt == nil {
+		log.Printf("📧 Connexion refusée, adresse email non vérifiée pour l'utilisateur %s", user.ID)
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// requireSecondFactor retourne une *MFARequiredError si l'utilisateur a activé un second facteur
+func (s *authService) requireSecondFactor(user *models.User) error {
+	enabled, err := s.mfa.IsEnabled(user.ID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return nil
+	}
+
+	mfaToken, err := auth.GenerateMFAToken(user.ID, s.tokens)
+	if err != nil {
+		return err
+	}
+	log.Printf("🔐 Second facteur requis pour l'utilisateur %s", user.ID)
+	return &MFARequiredError{Token: mfaToken}
+}
+
+func (s *authService) VerifyMFA(mfaToken string, code string, ipAddress string) (*models.User, error) {
+	claims, err := auth.ValidateMFAToken(mfaToken, s.tokens)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := s.revocations.IsRevoked(claims.TokenID)
+	if err != nil || revoked {
+		return nil, ErrInvalidToken
+	}
+
+	user, err := s.userRepo.FindByID(claims.UserID)
 	if err != nil || user == nil {
 		return nil, ErrUserNotFound
 	}
+	// Les codes erronés sont comptés sur le compte, dans le stockage partagé du blocage :
+	// ouvrir de nouveaux challenges ou changer d'instance ne donne pas d'essais supplémentaires
+	account := LockoutAccount{User: user}
+	if err := s.lockout.Check(account, ipAddress); err != nil {
+		return nil, err
+	}
 
-	if !auth.CheckPasswordHash(request.Password, user.Password) {
-		return nil, ErrPasswordMismatch
+	if err := s.mfa.VerifyCode(claims.UserID, code); err != nil {
+		if !errors.Is(err, ErrInvalidMFACode) {
+			return nil, err
+		}
+		log.Printf("🚨 Code MFA erroné pour l'utilisateur %s", claims.UserID)
+		s.recordLoginFailure(account, ipAddress)
+		return nil, ErrInvalidMFACode
+	}
+
+	// Le challenge est à usage unique
+	if err := s.revocations.Revoke(claims.TokenID, claims.ExpiresAt); err != nil {
+		return nil, err
+	}
+	s.recordLoginSuccess(account)
+
+	return user, nil
+}
+
+func (s *authService) LoginWithMFA(request models.MFALoginRequest) (*models.AuthResponse, error) {
+	user, err := s.VerifyMFA(request.MFAToken, request.Code, request.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.startSession(user, request.ClientInfo)
+}
+
+func (s *authService) StartSession(user *models.User, client models.ClientInfo) (*models.AuthResponse, error) {
+	return s.startSession(user, client)
+}
+
+func (s *authService) ContinueLogin(user *models.User, client models.ClientInfo) (*models.AuthResponse, error) {
+	if err := s.requireSecondFactor(user); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(user, client)
+}
+
+// startSession crée une session pour l'appareil et émet la première paire de tokens.
+// L'identifiant de session sert de famille aux refresh tokens qui en découlent.
+func (s *authService) startSession(user *models.User, client models.ClientInfo) (*models.AuthResponse, error) {
+	if err := s.requireActiveAccount(user); err != nil {
+		return nil, err
+	}
+	if err := s.requireVerifiedEmail(user); err != nil {
+		return nil, err
+	}
+	orgID, err := s.sessionOrganization(user, client.OrgID)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &models.Session{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		DeviceName: client.DeviceName,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+		ClientID:   client.ClientID,
+		Scope:      client.Scope,
+		OrgID:      orgID,
+	}
+
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, session)
+}
+
+// sessionOrganization retourne l'organisation de la session demandée par le client. Un compte
+// d'organisation se connecte toujours dans celle-ci ; les autres doivent être membres de
+// l'organisation demandée.
+func (s *authService) sessionOrganization(user *models.User, requested string) (string, error) {
+	if user.OrgID != nil {
+		if requested != "" && requested != *user.OrgID {
+			return "", ErrNotOrganizationMember
+		}
+		return *user.OrgID, nil
+	}
+	if requested == "" {
+		return "", nil
+	}
+
+	if _, _, err := s.organizations.MemberAuthorizations(requested, user.ID); err != nil {
+		return "", err
+	}
+	return requested, nil
+}
+
+// issueTokens génère un token d'accès et un refresh token rattachés à la session donnée.
+// Le client OAuth, le scope et l'organisation de la session sont reportés dans les tokens.
+func (s *authService) issueTokens(user *models.User, session *models.Session) (*models.AuthResponse, error) {
+	access := auth.AccessClaims{
+		UserID:    user.ID,
+		SessionID: session.ID,
+		ClientID:  session.ClientID,
+		Scope:     session.Scope,
+		OrgID:     session.OrgID,
+	}
+	expiryHours := s.config.TokenExpiryHours
+
+	// Un client OAuth agit dans les limites de son scope : les droits d'administration de
+	// l'utilisateur ne lui sont pas délégués
+	if session.OrgID != "" {
+		organization, err := s.organizations.GetOrganization(session.OrgID)
+		if err != nil {
+			return nil, err
+		}
+		expiryHours = s.tokenExpiryHours(organization.Settings)
+
+		// Dans une organisation, l'utilisateur n'a que les rôles de son adhésion, vérifiée à
+		// chaque émission : un membre retiré ne peut plus rafraîchir ses tokens
+		roles, permissions, err := s.organizations.MemberAuthorizations(session.OrgID, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if session.ClientID == "" {
+			access.Roles = roles
+			access.Permissions = permissions
+		}
+	} else if session.ClientID == "" {
+		roles, permissions, err := s.rbac.UserAuthorizations(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		access.Roles = roles
+		access.Permissions = permissions
 	}
 
-	token, err := auth.GenerateToken(user.ID, s.config.JWTSecret, s.config.TokenExpiryHours)
+	token, err := auth.GenerateToken(access, s.tokens, expiryHours)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := auth.GenerateRefreshToken(user.ID, s.config.JWTSecret)
+	refreshToken, refreshTokenID, err := auth.GenerateRefreshToken(auth.RefreshClaims{
+		UserID:    user.ID,
+		SessionID: session.ID,
+		ClientID:  session.ClientID,
+	}, s.tokens)
 	if err != nil {
 		return nil, err
 	}
 
-	s.refreshTokensMutex.Lock()
-	s.refreshTokens[user.ID] = refreshToken
-	s.refreshTokensMutex.Unlock()
+	err = s.refreshTokenRepo.Create(&models.RefreshToken{
+		ID:        refreshTokenID,
+		FamilyID:  session.ID,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(auth.RefreshTokenLifetime),
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	return &models.AuthResponse{
 		Token:        token,
 		RefreshToken: refreshToken,
 		User:         *user,
+		SessionID:    session.ID,
+		Scope:        session.Scope,
+		ExpiresIn:    expiryHours * 3600,
 	}, nil
 }
 
-func (s *authService) ValidateToken(token string) (string, error) {
+func (s *authService) ValidateToken(token string) (*auth.AccessClaims, error) {
 	// Vérifier d'abord si le token est révoqué
-	if s.IsTokenRevoked(token) {
+	if s.IsTokenRevoked(token, auth.TypeAccessToken) {
 		log.Printf("❌ Token révoqué détecté: %s", token)
-		return "", ErrInvalidToken
+		return nil, ErrTokenRevoked
 	}
 
-	userID, err := auth.ValidateToken(token, s.config.JWTSecret)
+	claims, err := auth.ValidateToken(token, s.tokens)
 	if err != nil {
-		return "", ErrInvalidToken
+		return nil, ErrInvalidToken
+	}
+
+	// Les tokens sans session (émis avant leur introduction) restent valides jusqu'à expiration
+	if claims.SessionID == "" {
+		return claims, nil
+	}
+
+	session, err := s.sessionRepo.FindByID(claims.SessionID)
+	if err != nil || session.UserID != claims.UserID {
+		return nil, ErrInvalidToken
+	}
+	if session.RevokedAt != nil {
+		log.Printf("❌ Session %s révoquée", session.ID)
+		return nil, ErrTokenRevoked
 	}
 
-	return userID, nil
+	// Limiter les écritures : last_seen_at n'est mis à jour que toutes les quelques minutes
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		if err := s.sessionRepo.Touch(session.ID, "", time.Now()); err != nil {
+			log.Printf("Erreur lors de la mise à jour de la session %s: %v", session.ID, err)
+		}
+	}
+
+	return claims, nil
 }
 
 func (s *authService) RefreshToken(refreshToken string) (*models.AuthResponse, error) {
+	return s.refresh(refreshToken, "")
+}
+
+func (s *authService) RefreshTokenForClient(refreshToken string, clientID string) (*models.AuthResponse, error) {
+	return s.refresh(refreshToken, clientID)
+}
+
+// refresh échange un refresh token contre une nouvelle paire. Le token doit avoir été émis
+// pour clientID : ceux des clients OAuth ne passent pas par /refresh et inversement.
+func (s *authService) refresh(refreshToken string, clientID string) (*models.AuthResponse, error) {
 	log.Printf(" REFRESH TOKEN - Token à vérifier: %s", refreshToken)
 
 	// Vérifier si le refresh token est révoqué
-	if s.IsTokenRevoked(refreshToken) {
+	if s.IsTokenRevoked(refreshToken, auth.TypeRefreshToken) {
 		log.Printf("❌ REFRESH REFUSÉ: Token révoqué")
 		return nil, ErrInvalidToken
 	}
 
-	userID, err := auth.ValidateRefreshToken(refreshToken, s.config.JWTSecret)
-	if err != nil || userID == "" {
+	claims, err := auth.ValidateRefreshToken(refreshToken, s.tokens)
+	if err != nil {
 		log.Printf("❌ REFRESH REFUSÉ: Token invalide - %v", err)
 		return nil, ErrInvalidToken
 	}
 
+	if claims.ClientID != clientID {
+		log.Printf("❌ REFRESH REFUSÉ: Token émis pour le client %q", claims.ClientID)
+		return nil, ErrInvalidToken
+	}
+
+	record, err := s.refreshTokenRepo.FindByID(claims.TokenID)
+	if err != nil {
+		log.Printf("❌ REFRESH REFUSÉ: Token inconnu du serveur - %v", err)
+		return nil, ErrInvalidToken
+	}
+
+	if record.RevokedAt != nil {
+		log.Printf("❌ REFRESH REFUSÉ: Famille %s révoquée", record.FamilyID)
+		return nil, ErrInvalidToken
+	}
+
+	// Un token déjà échangé qui revient signifie qu'il a été volé : on coupe toute la famille
+	rotated, err := s.refreshTokenRepo.MarkRotated(record.ID)
+	if err != nil {
+		log.Printf("RefreshToken failed: Error rotating refresh token: %v", err)
+		return nil, err
+	}
+	if !rotated {
+		s.handleRefreshTokenReuse(record)
+		return nil, ErrRefreshTokenReuse
+	}
+
 	// Récupérer l'utilisateur depuis la base de données
-	user, err := s.userRepo.FindByID(userID)
+	user, err := s.userRepo.FindByID(claims.UserID)
 	if err != nil {
 		log.Printf("❌ REFRESH REFUSÉ: Utilisateur non trouvé - %v", err)
 		return nil, ErrUserNotFound
 	}
 
-	// Générer un nouveau token d'accès
-	newToken, err := auth.GenerateToken(userID, s.config.JWTSecret, s.config.TokenExpiryHours)
+	session, err := s.sessionRepo.FindByID(record.FamilyID)
 	if err != nil {
-		log.Printf("RefreshToken failed: Error generating token: %v", err)
-		return nil, err
+		log.Printf("❌ REFRESH REFUSÉ: Session %s introuvable - %v", record.FamilyID, err)
+		return nil, ErrInvalidToken
 	}
 
-	// Générer un nouveau refresh token
-	newRefreshToken, err := auth.GenerateRefreshToken(userID, s.config.JWTSecret)
+	// Générer une nouvelle paire de tokens dans la même session
+	response, err := s.issueTokens(user, session)
 	if err != nil {
-		log.Printf("RefreshToken failed: Error generating refresh token: %v", err)
+		log.Printf("RefreshToken failed: Error generating tokens: %v", err)
 		return nil, err
 	}
 
-	// Révoquer l'ancien refresh token pour éviter sa réutilisation
-	s.RevokeToken(refreshToken)
+	if err := s.sessionRepo.Touch(record.FamilyID, "", time.Now()); err != nil {
+		log.Printf("Erreur lors de la mise à jour de la session %s: %v", record.FamilyID, err)
+	}
 
-	log.Printf("✅ REFRESH RÉUSSI: Nouveau token généré pour l'utilisateur %s", userID)
+	log.Printf("✅ REFRESH RÉUSSI: Nouveau token généré pour l'utilisateur %s", user.ID)
 
-	return &models.AuthResponse{
-		Token:        newToken,
-		RefreshToken: newRefreshToken,
-		User:         *user,
-	}, nil
+	return response, nil
+}
+
+// handleRefreshTokenReuse révoque toute la famille d'un refresh token rejoué et émet un événement de sécurité
+func (s *authService) handleRefreshTokenReuse(record *models.RefreshToken) {
+	log.Printf("🚨 REFRESH REFUSÉ: Réutilisation du refresh token %s, révocation de la famille %s", record.ID, record.FamilyID)
+
+	if err := revokeSession(s.sessionRepo, s.refreshTokenRepo, record.FamilyID); err != nil {
+		log.Printf("❌ Erreur lors de la révocation de la famille %s: %v", record.FamilyID, err)
+	}
+
+	s.events.Emit(SecurityEvent{
+		Type:   EventRefreshTokenReuse,
+		UserID: record.UserID,
+		Details: map[string]string{
+			"family_id": record.FamilyID,
+			"token_id":  record.ID,
+		},
+		OccurredAt: time.Now(),
+	})
 }
 
 func generateUUID() string {
 	return uuid.New().String()
 }
 
-func (s *authService) ForgotPassword(email string) (string, error) {
-	user, err := s.userRepo.FindByEmail(email)
+func (s *authService) RequestPasswordReset(email string, orgID string) {
+	select {
+	case s.passwordResets <- passwordResetRequest{email: email, orgID: orgID}:
+	default:
+		log.Printf("⚠️ File des demandes de réinitialisation pleine, demande pour %s ignorée", email)
+	}
+}
+
+func (s *authService) processPasswordResets() {
+	for request := range s.passwordResets {
+		if err := s.ForgotPassword(request.email, request.orgID); err != nil && err != ErrUserNotFound {
+			log.Printf("Erreur lors de l'envoi du lien de réinitialisation: %v", err)
+		}
+	}
+}
+
+func (s *authService) ForgotPassword(email string, orgID string) error {
+	users := s.usersIn(orgID)
+	user, err := users.FindByEmail(email)
 	if err != nil || user == nil {
-		// Pour des raisons de sécurité, nous ne révélons pas si l'email existe ou non
-		// Nous retournons simplement une erreur générique
-		return "", ErrUserNotFound
+		// Appelée en arrière-plan par RequestPasswordReset : sa durée n'est pas observable
+		// et ne révèle pas si l'email existe
+		return ErrUserNotFound
 	}
 
+	// Le lien vit aussi longtemps qu'un token d'accès de l'organisation du compte
+	settings, err := s.accountSettings(user)
+	if err != nil {
+		return err
+	}
+	expiryHours := s.tokenExpiryHours(settings)
+
 	// Générer un JWT pour le reset token avec un uid unique
-	jwtToken, tokenUID, err := auth.GenerateResetToken(email, s.config.ResetTokenSecret, s.config.TokenExpiryHours)
+	jwtToken, tokenUID, err := auth.GenerateResetToken(email, s.tokens, expiryHours)
 	if err != nil {
 		log.Printf("Erreur lors de la génération du JWT pour le reset token: %v", err)
-		return "", err
+		return err
 	}
 
 	// Définir une date d'expiration pour le token (selon la config)
-	expiry := time.Now().Add(time.Hour * time.Duration(s.config.TokenExpiryHours))
+	expiry := time.Now().Add(time.Hour * time.Duration(expiryHours))
 
 	// Sauvegarder le token en mémoire (pour compatibilité avec les tests existants)
 	// Nous utilisons le JWT comme clé et l'ID de l'utilisateur comme valeur
@@ -230,21 +763,32 @@ func (s *authService) ForgotPassword(email string) (string, error) {
 
 	// Sauvegarder le token dans la base de données
 	// Nous stockons le JWT complet dans la base de données
-	err = s.userRepo.SaveResetToken(email, jwtToken, expiry)
+	err = users.SaveResetToken(email, jwtToken, expiry)
 	if err != nil {
 		log.Printf("Erreur lors de la sauvegarde du token de réinitialisation dans la base de données: %v", err)
 		// Continuer même en cas d'erreur de base de données à cause de la corruption connue
 	}
 
-	log.Printf("Reset token généré pour %s (UID: %s)", email, tokenUID)
+	// Le token n'est remis qu'au propriétaire de l'adresse, par email
+	message, err := mail.Render(user.Email, mail.TemplateResetPassword, mail.LinkData{
+		Name:      user.Name,
+		Link:      s.config.ResetPasswordURL + "?token=" + url.QueryEscape(jwtToken),
+		ExpiresIn: time.Hour * time.Duration(expiryHours),
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.mailer.Send(message); err != nil {
+		return err
+	}
 
-	// Retourner le token JWT directement
-	return jwtToken, nil
+	log.Printf("Reset token généré et envoyé pour %s (UID: %s)", email, tokenUID)
+	return nil
 }
 
 func (s *authService) ResetPassword(request models.ResetPasswordRequest) error {
 	// Valider le JWT reset token
-	email, tokenUID, err := auth.ValidateResetToken(request.Token, s.config.ResetTokenSecret)
+	email, tokenUID, err := auth.ValidateResetToken(request.Token, s.tokens)
 	if err != nil {
 		log.Printf("Erreur lors de la validation du JWT reset token: %v", err)
 		// Si le JWT n'est pas valide, essayons de vérifier dans la base de données et en mémoire
@@ -252,19 +796,15 @@ func (s *authService) ResetPassword(request models.ResetPasswordRequest) error {
 		return s.resetPasswordWithLegacyToken(request)
 	}
 
-	// Le JWT est valide, chercher l'utilisateur par email
-	user, err := s.userRepo.FindByEmail(email)
-	if err != nil || user == nil {
-		log.Printf("Utilisateur avec email %s non trouvé: %v", email, err)
-		return ErrUserNotFound
-	}
-
-	// Vérifier si ce token existe dans la base de données (double vérification)
+	// Le JWT est valide. L'utilisateur est celui pour qui le token a été enregistré : la même
+	// adresse peut désigner un compte dans chaque organisation.
+	var user *models.User
 	userFromDB, err := s.userRepo.FindByResetToken(request.Token)
 	var tokenFoundInDB bool
 
 	if err == nil && userFromDB != nil {
 		// Token trouvé dans la base de données
+		user = userFromDB
 		tokenFoundInDB = true
 		log.Printf("JWT reset token trouvé dans la base de données pour l'utilisateur: %s", user.ID)
 		
@@ -285,25 +825,23 @@ func (s *authService) ResetPassword(request models.ResetPasswordRequest) error {
 			return ErrInvalidToken
 		}
 
-		// Vérifier que l'ID de l'utilisateur en mémoire correspond à l'utilisateur trouvé par email
-		if id != user.ID {
-			log.Printf("L'ID de l'utilisateur en mémoire (%s) ne correspond pas à l'utilisateur trouvé par email (%s)", id, user.ID)
+		user, err = s.userRepo.FindByID(id)
+		if err != nil || user == nil {
+			log.Printf("Utilisateur %s du reset token non trouvé: %v", id, err)
+			return ErrUserNotFound
+		}
+
+		// Vérifier que l'email dans le token correspond à l'utilisateur trouvé en mémoire
+		if user.Email != email {
+			log.Printf("L'email dans le token (%s) ne correspond pas à l'utilisateur trouvé en mémoire (%s)", email, user.Email)
 			return ErrInvalidToken
 		}
 		
 		log.Printf("JWT reset token trouvé en mémoire pour l'utilisateur: %s", id)
 	}
 
-	// Hasher le nouveau mot de passe
-	hashedPassword, err := auth.HashPassword(request.NewPassword)
-	if err != nil {
-		return err
-	}
-
-	// Mettre à jour le mot de passe
-	user.Password = hashedPassword
-	err = s.userRepo.UpdatePassword(user.ID, hashedPassword)
-	if err != nil {
+	// Vérifier, hasher et enregistrer le nouveau mot de passe
+	if err := s.replacePassword(user, request.NewPassword); err != nil {
 		return err
 	}
 
@@ -318,13 +856,14 @@ func (s *authService) ResetPassword(request models.ResetPasswordRequest) error {
 		// Mettre à null le token dans la base de données
 		// Note: Cette opération peut échouer à cause de la corruption de la base de données,
 		// mais nous continuons quand même
-		err = s.userRepo.SaveResetToken(user.Email, "", time.Now())
+		err = s.usersIn(organizationOf(user)).SaveResetToken(user.Email, "", time.Now())
 		if err != nil {
 			log.Printf("Erreur lors de l'invalidation du token dans la base de données: %v", err)
 			// Continuer malgré l'erreur à cause de la corruption connue de la base de données
 		}
 	}
 
+	s.emitPasswordReset(user)
 	log.Printf("Mot de passe réinitialisé avec succès pour l'utilisateur %s (UID du token: %s)", user.ID, tokenUID)
 	return nil
 }
@@ -357,19 +896,12 @@ func (s *authService) resetPasswordWithLegacyToken(request models.ResetPasswordR
 		log.Printf("Token legacy trouvé en mémoire pour l'utilisateur: %s", userID)
 	}
 
-	hashedPassword, err := auth.HashPassword(request.NewPassword)
-	if err != nil {
-		return err
-	}
-
 	user, err := s.userRepo.FindByID(userID)
 	if err != nil || user == nil {
 		return ErrUserNotFound
 	}
 
-	user.Password = hashedPassword
-	err = s.userRepo.UpdatePassword(user.ID, hashedPassword)
-	if err != nil {
+	if err := s.replacePassword(user, request.NewPassword); err != nil {
 		return err
 	}
 
@@ -384,36 +916,409 @@ func (s *authService) resetPasswordWithLegacyToken(request models.ResetPasswordR
 		// Mettre à null le token dans la base de données
 		// Note: Cette opération peut échouer à cause de la corruption de la base de données,
 		// mais nous continuons quand même
-		err = s.userRepo.SaveResetToken(user.Email, "", time.Now())
+		err = s.usersIn(organizationOf(user)).SaveResetToken(user.Email, "", time.Now())
 		if err != nil {
 			log.Printf("Erreur lors de l'invalidation du token legacy dans la base de données: %v", err)
 			// Continuer malgré l'erreur à cause de la corruption connue de la base de données
 		}
 	}
 
+	s.emitPasswordReset(user)
 	log.Printf("Mot de passe réinitialisé avec succès pour l'utilisateur %s (avec token legacy)", user.ID)
 	return nil
 }
 
+func (s *authService) ChangePassword(userID string, currentSessionID string, request models.ChangePasswordRequest) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || user == nil {
+		return ErrUserNotFound
+	}
+
+	// Les essais du mot de passe actuel sont comptés comme à la connexion : une session
+	// volée ne doit pas permettre de le deviner
+	account := LockoutAccount{User: user}
+	if err := s.lockout.Check(account, request.IPAddress); err != nil {
+		return err
+	}
+
+	match, err := s.passwords.Verify(request.CurrentPassword, user.Password)
+	if err != nil {
+		return ErrPasswordMismatch
+	}
+	if !match {
+		s.recordLoginFailure(account, request.IPAddress)
+		return ErrPasswordMismatch
+	}
+	if err := s.lockout.RecordSuccess(account); err != nil {
+		log.Printf("❌ Erreur lors de la remise à zéro des échecs de connexion de l'utilisateur %s: %v", user.ID, err)
+	}
+
+	if err := s.replacePassword(user, request.NewPassword); err != nil {
+		return err
+	}
+
+	// Un attaquant connecté avec l'ancien mot de passe perd ses sessions ; celle
+	// d'où vient le changement est conservée
+	revoked, err := s.sessionRepo.RevokeAllForUser(user.ID, currentSessionID)
+	if err != nil {
+		return err
+	}
+	for _, sessionID := range revoked {
+		if err := s.refreshTokenRepo.RevokeFamily(sessionID); err != nil {
+			return err
+		}
+	}
+
+	s.events.Emit(SecurityEvent{
+		Type:       EventPasswordChanged,
+		UserID:     user.ID,
+		Details:    map[string]string{"revoked_sessions": strconv.Itoa(len(revoked))},
+		OccurredAt: time.Now(),
+	})
+	log.Printf("🔑 Mot de passe modifié pour l'utilisateur %s, %d autre(s) session(s) révoquée(s)", user.ID, len(revoked))
+	return nil
+}
+
+func (s *authService) SwitchOrganization(userID string, currentSessionID string, request models.SwitchOrganizationRequest) (*models.AuthResponse, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+	current, err := s.sessionRepo.FindByID(currentSessionID)
+	if err != nil || current.UserID != user.ID {
+		return nil, ErrInvalidToken
+	}
+
+	// La nouvelle session reprend l'appareil et le client OAuth de la session courante
+	client := models.ClientInfo{
+		DeviceName: current.DeviceName,
+		UserAgent:  request.UserAgent,
+		IPAddress:  request.IPAddress,
+		ClientID:   current.ClientID,
+		Scope:      current.Scope,
+		OrgID:      request.OrgID,
+	}
+	if request.DeviceName != "" {
+		client.DeviceName = request.DeviceName
+	}
+	response, err := s.startSession(user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := revokeSession(s.sessionRepo, s.refreshTokenRepo, current.ID); err != nil {
+		log.Printf("❌ Erreur lors de la fermeture de la session %s: %v", current.ID, err)
+	}
+
+	log.Printf("🏢 Utilisateur %s passé dans le contexte de l'organisation %q (session %s)", user.ID, request.OrgID, response.SessionID)
+	return response, nil
+}
+
+// replacePassword applique la politique et l'historique au nouveau mot de passe, puis
+// l'enregistre. L'ancien hash rejoint l'historique.
+func (s *authService) replacePassword(user *models.User, newPassword string) error {
+	settings, err := s.accountSettings(user)
+	if err != nil {
+		return err
+	}
+	if err := s.passwordPolicy(settings).Check(newPassword, user.Name, user.Email); err != nil {
+		return err
+	}
+	historySize := s.config.PasswordPolicy.HistorySize
+	if settings.PasswordHistorySize != nil {
+		historySize = *settings.PasswordHistorySize
+	}
+	if err := s.checkPasswordHistory(user, newPassword, historySize); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.passwords.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	previousHash := user.Password
+	if err := s.userRepo.UpdatePassword(user.ID, hashedPassword); err != nil {
+		return err
+	}
+	user.Password = hashedPassword
+
+	if keep := historySize - 1; keep > 0 && previousHash != "" {
+		if err := s.passwordHistory.Add(user.ID, previousHash, keep); err != nil {
+			log.Printf("❌ Erreur lors de l'enregistrement de l'historique du mot de passe de l'utilisateur %s: %v", user.ID, err)
+		}
+	}
+	return nil
+}
+
+// checkPasswordHistory refuse le mot de passe actuel et les size-1 précédents
+func (s *authService) checkPasswordHistory(user *models.User, newPassword string, size int) error {
+	if size <= 0 {
+		return nil
+	}
+
+	hashes := []string{user.Password}
+	if size > 1 {
+		previous, err := s.passwordHistory.ListRecent(user.ID, size-1)
+		if err != nil {
+			return err
+		}
+		hashes = append(hashes, previous...)
+	}
+
+	for _, hash := range hashes {
+		if match, err := s.passwords.Verify(newPassword, hash); err == nil && match {
+			return &auth.PasswordPolicyError{Violations: []auth.PasswordViolation{{
+				Rule:    auth.RulePasswordReused,
+				Message: fmt.Sprintf("Password must differ from your last %d passwords", size),
+			}}}
+		}
+	}
+	return nil
+}
+
+// accountSettings retourne les paramètres de l'organisation propriétaire du compte
+func (s *authService) accountSettings(user *models.User) (models.OrganizationSettings, error) {
+	if user.OrgID == nil {
+		return models.OrganizationSettings{}, nil
+	}
+	organization, err := s.organizations.GetOrganization(*user.OrgID)
+	if err != nil {
+		return models.OrganizationSettings{}, err
+	}
+	return organization.Settings, nil
+}
+
+// tokenExpiryHours retourne la durée de vie des tokens d'accès de l'instance, ou celle de l'organisation
+func (s *authService) tokenExpiryHours(settings models.OrganizationSettings) int {
+	if settings.TokenExpiryHours != nil {
+		return *settings.TokenExpiryHours
+	}
+	return s.config.TokenExpiryHours
+}
+
+// passwordPolicy retourne la politique de l'instance, modifiée par les paramètres de l'organisation
+func (s *authService) passwordPolicy(settings models.OrganizationSettings) *auth.PasswordPolicy {
+	policy := *s.policy
+	if settings.PasswordMinLength != nil {
+		policy.MinLength = *settings.PasswordMinLength
+	}
+	if settings.PasswordRequiredClasses != nil {
+		policy.RequiredClasses = *settings.PasswordRequiredClasses
+	}
+	if settings.PasswordMinStrength != nil {
+		policy.MinStrength = *settings.PasswordMinStrength
+	}
+	return &policy
+}
+
+// usersIn retourne les comptes désignés par leur email dans l'organisation, ou ceux de
+// l'instance si orgID est vide
+func (s *authService) usersIn(orgID string) repositories.UserRepository {
+	if orgID == "" {
+		return s.userRepo
+	}
+	return s.organizations.Members(orgID)
+}
+
+// emitPasswordReset signale le changement de mot de passe, pour que l'utilisateur soit prévenu
+// si la réinitialisation ne vient pas de lui
+func (s *authService) emitPasswordReset(user *models.User) {
+	s.events.Emit(SecurityEvent{
+		Type:       EventPasswordReset,
+		UserID:     user.ID,
+		OccurredAt: time.Now(),
+	})
+}
+
+func (s *authService) VerifyEmail(token string) error {
+	claims, err := auth.ValidateEmailVerificationToken(token, s.tokens)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	user, err := s.userRepo.FindByID(claims.UserID)
+	if err != nil || user == nil {
+		return ErrInvalidToken
+	}
+	// Un token émis pour une ancienne adresse ne vérifie pas la nouvelle
+	if user.Email != claims.Email {
+		return ErrInvalidToken
+	}
+
+	// Vérifier deux fois la même adresse est sans effet
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+	if err := s.userRepo.MarkEmailVerified(user.ID, time.Now()); err != nil {
+		return err
+	}
+
+	log.Printf("📧 Adresse email vérifiée pour l'utilisateur %s", user.ID)
+	return nil
+}
+
+func (s *authService) ResendVerificationEmail(email string, orgID string) error {
+	user, err := s.usersIn(orgID).FindByEmail(email)
+	if err != nil || user == nil {
+		return ErrUserNotFound
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	return s.sendVerificationEmail(user)
+}
+
+// sendVerificationEmail envoie le lien de vérification de l'adresse de l'utilisateur
+func (s *authService) sendVerificationEmail(user *models.User) error {
+	token, err := auth.GenerateEmailVerificationToken(user.ID, user.Email, s.tokens)
+	if err != nil {
+		return err
+	}
+
+	message, err := mail.Render(user.Email, mail.TemplateVerifyEmail, mail.LinkData{
+		Name:      user.Name,
+		Link:      s.config.VerifyEmailURL + "?token=" + url.QueryEscape(token),
+		ExpiresIn: auth.EmailVerificationLifetime,
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.mailer.Send(message); err != nil {
+		return err
+	}
+
+	log.Printf("📧 Email de vérification envoyé à l'utilisateur %s", user.ID)
+	return nil
+}
+
 // La fonction generateResetToken a été remplacée par auth.GenerateResetToken
 
-// RevokeToken ajoute un token à la liste noire pour le désactiver
+// revocableTokenTypes sont les types de tokens que RevokeToken accepte
+var revocableTokenTypes = []string{auth.TypeAccessToken, auth.TypeRefreshToken}
+
+// RevokeToken ajoute l'identifiant du token à la liste noire jusqu'à son expiration
 func (s *authService) RevokeToken(token string) error {
-	s.revokedTokensMutex.Lock()
-	defer s.revokedTokensMutex.Unlock()
-	
-	// Ajouter le token à la liste noire
-	s.revokedTokens[token] = true
-	log.Printf("✅ Token révoqué avec succès: %s", token)
+	var jti string
+	var expiresAt time.Time
+	var err error
+	for _, tokenType := range revocableTokenTypes {
+		if jti, expiresAt, err = auth.GetTokenID(token, tokenType, s.tokens); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		// Un token invalide ou déjà expiré ne peut plus être utilisé, rien à révoquer
+		log.Printf("Token non révoqué car invalide ou expiré: %v", err)
+		return nil
+	}
+
+	if err := s.revocations.Revoke(jti, expiresAt); err != nil {
+		return err
+	}
+
+	// Révoquer un refresh token met fin à sa session et à toute sa famille de rotation
+	if claims, err := auth.ValidateRefreshToken(token, s.tokens); err == nil {
+		if err := revokeSession(s.sessionRepo, s.refreshTokenRepo, claims.SessionID); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("✅ Token révoqué avec succès (jti: %s)", jti)
 	return nil
 }
 
-// IsTokenRevoked vérifie si un token est dans la liste noire
-func (s *authService) IsTokenRevoked(token string) bool {
-	s.revokedTokensMutex.RLock()
-	defer s.revokedTokensMutex.RUnlock()
-	
-	// Vérifier si le token est dans la liste noire
-	revoked, exists := s.revokedTokens[token]
-	return exists && revoked
+func (s *authService) RevokeTokenForClient(token string, clientID string) error {
+	owner, err := auth.GetTokenClientID(token, s.tokens)
+	if err != nil {
+		log.Printf("Token non révoqué car invalide: %v", err)
+		return nil
+	}
+	if owner != clientID {
+		log.Printf("🚫 Révocation ignorée : token émis au client %q, demandée par le client %q", owner, clientID)
+		return nil
+	}
+
+	return s.RevokeToken(token)
+}
+
+// IsTokenRevoked vérifie si un token est dans la liste noire.
+// En cas d'erreur du stockage, le token est considéré comme révoqué.
+func (s *authService) IsTokenRevoked(token string, tokenType string) bool {
+	jti, _, err := auth.GetTokenID(token, tokenType, s.tokens)
+	if err != nil {
+		return false
+	}
+
+	revoked, err := s.revocations.IsRevoked(jti)
+	if err != nil {
+		log.Printf("❌ Erreur lors de la vérification de la révocation du token %s: %v", jti, err)
+		return true
+	}
+	return revoked
+}
+
+// Introspect indique si un token est actif, en tenant compte des révocations et des sessions.
+// tokenTypeHint permet de tester d'abord le type de token indiqué par le client.
+func (s *authService) Introspect(token string, tokenTypeHint string) *models.IntrospectionResponse {
+	if tokenTypeHint == models.TokenTypeRefresh {
+		if response := s.introspectRefreshToken(token); response.Active {
+			return response
+		}
+		return s.introspectAccessToken(token)
+	}
+
+	if response := s.introspectAccessToken(token); response.Active {
+		return response
+	}
+	return s.introspectRefreshToken(token)
+}
+
+func (s *authService) introspectAccessToken(token string) *models.IntrospectionResponse {
+	claims, err := s.ValidateToken(token)
+	if err != nil {
+		return &models.IntrospectionResponse{Active: false}
+	}
+
+	return &models.IntrospectionResponse{
+		Active:    true,
+		Subject:   claims.Subject,
+		ExpiresAt: claims.ExpiresAt.Unix(),
+		IssuedAt:  claims.IssuedAt.Unix(),
+		TokenType: models.TokenTypeAccess,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		TokenID:   claims.TokenID,
+		SessionID: claims.SessionID,
+		OrgID:     claims.OrgID,
+	}
+}
+
+func (s *authService) introspectRefreshToken(token string) *models.IntrospectionResponse {
+	if s.IsTokenRevoked(token, auth.TypeRefreshToken) {
+		return &models.IntrospectionResponse{Active: false}
+	}
+
+	claims, err := auth.ValidateRefreshToken(token, s.tokens)
+	if err != nil {
+		return &models.IntrospectionResponse{Active: false}
+	}
+
+	// Un refresh token déjà échangé ou dont la famille est révoquée n'est plus actif
+	record, err := s.refreshTokenRepo.FindByID(claims.TokenID)
+	if err != nil || record.RotatedAt != nil || record.RevokedAt != nil {
+		return &models.IntrospectionResponse{Active: false}
+	}
+
+	return &models.IntrospectionResponse{
+		Active:    true,
+		Subject:   claims.UserID,
+		ExpiresAt: claims.ExpiresAt.Unix(),
+		IssuedAt:  claims.IssuedAt.Unix(),
+		TokenType: models.TokenTypeRefresh,
+		ClientID:  claims.ClientID,
+		TokenID:   claims.TokenID,
+		SessionID: claims.SessionID,
+	}
 }
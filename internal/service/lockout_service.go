@@ -0,0 +1,280 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/config"
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/domain/repositories"
+	"github.com/amirtalbi/examen_go/internal/mail"
+	"github.com/amirtalbi/examen_go/pkg/auth"
+)
+
+// AccountLockedError signale un compte ou une adresse IP bloqué après trop de connexions échouées
+type AccountLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *AccountLockedError) Error() string {
+	return "account temporarily locked"
+}
+
+// RetryAfterSeconds arrondit le délai à la seconde supérieure, pour l'en-tête Retry-After
+func (e *AccountLockedError) RetryAfterSeconds() int {
+	seconds := int((e.RetryAfter + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// LockoutAccount désigne le compte visé par une tentative de connexion : le compte trouvé,
+// ou à défaut l'adresse essayée dans l'organisation demandée (l'instance si OrgID est vide)
+type LockoutAccount struct {
+	User  *models.User
+	OrgID string
+	Email string
+}
+
+type LockoutService interface {
+	// Vérifier avant le mot de passe que ni le compte ni l'adresse IP ne sont bloqués.
+	// Retourne une *AccountLockedError le cas échéant.
+	Check(account LockoutAccount, ipAddress string) error
+	// Compter un échec, imposer le délai suivant et bloquer au-delà du seuil
+	RecordFailure(account LockoutAccount, ipAddress string) error
+	// Remettre à zéro les échecs du compte après un mot de passe correct
+	RecordSuccess(account LockoutAccount) error
+	// Débloquer le compte avec le lien reçu par email (usage unique)
+	UnlockWithToken(token string) error
+	// Débloquer le compte d'un utilisateur (action d'administration)
+	Unlock(userID string) error
+}
+
+type lockoutService struct {
+	attemptRepo repositories.LoginAttemptRepository
+	userRepo    repositories.UserRepository
+	// Les liens de déblocage sont à usage unique : leur jti est révoqué à l'usage
+	revocations repositories.RevocationStore
+	tokens      *auth.TokenConfig
+	events      SecurityEventSink
+	mailer      mail.Mailer
+	config      *config.Config
+}
+
+func NewLockoutService(
+	attemptRepo repositories.LoginAttemptRepository,
+	userRepo repositories.UserRepository,
+	revocations repositories.RevocationStore,
+	tokens *auth.TokenConfig,
+	events SecurityEventSink,
+	mailer mail.Mailer,
+	config *config.Config,
+) LockoutService {
+	return &lockoutService{
+		attemptRepo: attemptRepo,
+		userRepo:    userRepo,
+		revocations: revocations,
+		tokens:      tokens,
+		events:      events,
+		mailer:      mailer,
+		config:      config,
+	}
+}
+
+// Les échecs sont comptés par compte, ou à défaut par adresse email dans l'organisation
+// demandée : le blocage ne révèle donc pas quels comptes existent, et la même adresse dans
+// deux organisations désigne deux comptes distincts. L'adresse est prise telle quelle,
+// comme par UserRepository.FindByEmail.
+func accountLockoutKey(account LockoutAccount) string {
+	if account.User != nil {
+		return "account:" + account.User.ID
+	}
+	if account.OrgID != "" {
+		return "account:" + account.OrgID + ":" + account.Email
+	}
+	return "account:" + account.Email
+}
+
+func ipLockoutKey(ipAddress string) string {
+	return "ip:" + ipAddress
+}
+
+func (s *lockoutService) Check(account LockoutAccount, ipAddress string) error {
+	keys := []string{accountLockoutKey(account)}
+	if ipAddress != "" {
+		keys = append(keys, ipLockoutKey(ipAddress))
+	}
+
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, key := range keys {
+		attempts, err := s.attemptRepo.Find(key)
+		if errors.Is(err, repositories.ErrLoginAttemptsNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if attempts.LockedUntil != nil && attempts.LockedUntil.After(now) {
+			if wait := attempts.LockedUntil.Sub(now); wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+
+	if retryAfter > 0 {
+		return &AccountLockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+func (s *lockoutService) RecordFailure(account LockoutAccount, ipAddress string) error {
+	window := time.Duration(s.config.Lockout.WindowSeconds) * time.Second
+
+	attempts, err := s.attemptRepo.RecordFailure(accountLockoutKey(account), window)
+	if err != nil {
+		return err
+	}
+	if delay := s.accountDelay(attempts.Failures); delay > 0 {
+		if err := s.attemptRepo.Lock(attempts.Key, time.Now().Add(delay)); err != nil {
+			return err
+		}
+	}
+	// Le lien n'est envoyé qu'une fois, quand le seuil est franchi
+	if threshold := s.config.Lockout.AccountThreshold; threshold > 0 && attempts.Failures == threshold {
+		s.notifyAccountLocked(account, attempts.Failures)
+	}
+
+	if ipAddress == "" {
+		return nil
+	}
+
+	// Une même adresse IP qui échoue sur de nombreux comptes (credential stuffing) n'est pas
+	// ralentie compte par compte : elle est bloquée d'un coup au-delà de son propre seuil
+	attempts, err = s.attemptRepo.RecordFailure(ipLockoutKey(ipAddress), window)
+	if err != nil {
+		return err
+	}
+	if threshold := s.config.Lockout.IPThreshold; threshold > 0 && attempts.Failures >= threshold {
+		if err := s.attemptRepo.Lock(attempts.Key, time.Now().Add(s.lockoutDuration())); err != nil {
+			return err
+		}
+		if attempts.Failures == threshold {
+			log.Printf("🚫 Adresse IP %s bloquée après %d connexions échouées", ipAddress, attempts.Failures)
+		}
+	}
+	return nil
+}
+
+// accountDelay retourne le délai imposé après le n-ième échec sur un compte : rien jusqu'à
+// BackoffThreshold, puis un délai qui double à chaque échec, et le blocage au-delà du seuil
+func (s *lockoutService) accountDelay(failures int) time.Duration {
+	lockout := s.config.Lockout
+	if lockout.AccountThreshold > 0 && failures >= lockout.AccountThreshold {
+		return s.lockoutDuration()
+	}
+	if lockout.BackoffThreshold <= 0 || failures < lockout.BackoffThreshold {
+		return 0
+	}
+
+	maxDelay := time.Duration(lockout.BackoffMaxSeconds) * time.Second
+	delay := time.Duration(lockout.BackoffBaseSeconds) * time.Second
+	for i := lockout.BackoffThreshold; i < failures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
+func (s *lockoutService) lockoutDuration() time.Duration {
+	return time.Duration(s.config.Lockout.DurationSeconds) * time.Second
+}
+
+// notifyAccountLocked prévient le titulaire du compte et lui envoie le lien de déblocage
+func (s *lockoutService) notifyAccountLocked(account LockoutAccount, failures int) {
+	user := account.User
+	if user == nil {
+		log.Printf("🚫 Adresse %s bloquée après %d connexions échouées (aucun compte)", account.Email, failures)
+		return
+	}
+
+	s.events.Emit(SecurityEvent{
+		Type:       EventAccountLocked,
+		UserID:     user.ID,
+		Details:    map[string]string{"failures": strconv.Itoa(failures)},
+		OccurredAt: time.Now(),
+	})
+
+	token, err := auth.GenerateAccountUnlockToken(user.ID, user.Email, s.tokens)
+	if err != nil {
+		log.Printf("❌ Erreur lors de la génération du lien de déblocage de l'utilisateur %s: %v", user.ID, err)
+		return
+	}
+
+	message, err := mail.Render(user.Email, mail.TemplateAccountLocked, mail.LinkData{
+		Name:      user.Name,
+		Link:      s.config.UnlockAccountURL + "?token=" + url.QueryEscape(token),
+		ExpiresIn: auth.AccountUnlockLifetime,
+	})
+	if err == nil {
+		err = s.mailer.Send(message)
+	}
+	if err != nil {
+		log.Printf("❌ Erreur lors de l'envoi du lien de déblocage à l'utilisateur %s: %v", user.ID, err)
+		return
+	}
+
+	log.Printf("🚫 Compte de l'utilisateur %s bloqué après %d connexions échouées, lien de déblocage envoyé", user.ID, failures)
+}
+
+func (s *lockoutService) RecordSuccess(account LockoutAccount) error {
+	return s.attemptRepo.Delete(accountLockoutKey(account))
+}
+
+func (s *lockoutService) UnlockWithToken(token string) error {
+	claims, err := auth.ValidateAccountUnlockToken(token, s.tokens)
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	revoked, err := s.revocations.IsRevoked(claims.TokenID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrInvalidToken
+	}
+	if err := s.revocations.Revoke(claims.TokenID, claims.ExpiresAt); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.FindByID(claims.UserID)
+	if err != nil || user == nil || user.Email != claims.Email {
+		return ErrInvalidToken
+	}
+
+	if err := s.attemptRepo.Delete(accountLockoutKey(LockoutAccount{User: user})); err != nil {
+		return err
+	}
+	log.Printf("🔓 Compte de l'utilisateur %s débloqué par lien email", user.ID)
+	return nil
+}
+
+func (s *lockoutService) Unlock(userID string) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || user == nil {
+		return ErrUserNotFound
+	}
+
+	if err := s.attemptRepo.Delete(accountLockoutKey(LockoutAccount{User: user})); err != nil {
+		return err
+	}
+	log.Printf("🔓 Compte de l'utilisateur %s débloqué par un administrateur", user.ID)
+	return nil
+}
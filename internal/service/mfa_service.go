@@ -0,0 +1,217 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/config"
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/domain/repositories"
+	"github.com/amirtalbi/examen_go/pkg/auth"
+)
+
+// recoveryCodeCount est le nombre de codes de récupération générés à la confirmation
+const recoveryCodeCount = 10
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("mfa already enabled")
+	ErrMFANotEnrolled    = errors.New("mfa not enrolled")
+	ErrInvalidMFACode    = errors.New("invalid mfa code")
+)
+
+// MFARequiredError est retournée après un mot de passe correct lorsque l'utilisateur a
+// activé un second facteur. Token est le token de challenge à échanger avec un code.
+type MFARequiredError struct {
+	Token string
+}
+
+func (e *MFARequiredError) Error() string {
+	return "mfa required"
+}
+
+type MFAService interface {
+	IsEnabled(userID string) (bool, error)
+	// Démarrer l'enrôlement TOTP : le secret n'est exigé qu'après confirmation
+	StartTOTPEnrollment(userID string) (*models.TOTPEnrollmentResponse, error)
+	// Confirmer l'enrôlement avec un premier code et générer les codes de récupération
+	ConfirmTOTP(userID string, code string) (*models.RecoveryCodesResponse, error)
+	DisableTOTP(userID string, code string) error
+	// Vérifier un code TOTP ou un code de récupération (à usage unique)
+	VerifyCode(userID string, code string) error
+}
+
+type mfaService struct {
+	mfaRepo  repositories.MFARepository
+	userRepo repositories.UserRepository
+	config   *config.Config
+}
+
+func NewMFAService(mfaRepo repositories.MFARepository, userRepo repositories.UserRepository, config *config.Config) MFAService {
+	return &mfaService{
+		mfaRepo:  mfaRepo,
+		userRepo: userRepo,
+		config:   config,
+	}
+}
+
+func (s *mfaService) IsEnabled(userID string) (bool, error) {
+	credential, err := s.mfaRepo.FindTOTP(userID)
+	if errors.Is(err, repositories.ErrTOTPNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return credential.ConfirmedAt != nil, nil
+}
+
+func (s *mfaService) StartTOTPEnrollment(userID string) (*models.TOTPEnrollmentResponse, error) {
+	enabled, err := s.IsEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	// Un nouvel enrôlement remplace celui qui n'a pas été confirmé
+	if err := s.mfaRepo.SaveTOTP(&models.TOTPCredential{UserID: userID, Secret: secret}); err != nil {
+		return nil, err
+	}
+
+	log.Printf("🔐 Enrôlement TOTP démarré pour l'utilisateur %s", userID)
+
+	return &models.TOTPEnrollmentResponse{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(s.config.MFAIssuer, user.Email, secret),
+	}, nil
+}
+
+func (s *mfaService) ConfirmTOTP(userID string, code string) (*models.RecoveryCodesResponse, error) {
+	credential, err := s.mfaRepo.FindTOTP(userID)
+	if errors.Is(err, repositories.ErrTOTPNotFound) {
+		return nil, ErrMFANotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if credential.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok := auth.ValidateTOTP(credential.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	if err := s.mfaRepo.ConfirmTOTP(userID, step); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.generateRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("✅ TOTP activé pour l'utilisateur %s", userID)
+	return &models.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func (s *mfaService) DisableTOTP(userID string, code string) error {
+	if err := s.VerifyCode(userID, code); err != nil {
+		return err
+	}
+
+	if err := s.mfaRepo.DeleteTOTP(userID); err != nil {
+		return err
+	}
+
+	log.Printf("🔓 TOTP désactivé pour l'utilisateur %s", userID)
+	return nil
+}
+
+func (s *mfaService) VerifyCode(userID string, code string) error {
+	credential, err := s.mfaRepo.FindTOTP(userID)
+	if errors.Is(err, repositories.ErrTOTPNotFound) {
+		return ErrMFANotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+	if credential.ConfirmedAt == nil {
+		return ErrMFANotEnrolled
+	}
+
+	code = strings.TrimSpace(code)
+	if step, ok := auth.ValidateTOTP(credential.Secret, code, time.Now()); ok {
+		// Un code déjà utilisé (ou plus ancien que le dernier utilisé) est refusé
+		used, err := s.mfaRepo.UseTOTPStep(userID, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			log.Printf("❌ Code TOTP rejoué pour l'utilisateur %s", userID)
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	used, err := s.mfaRepo.UseRecoveryCode(userID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+
+	log.Printf("⚠️ Code de récupération utilisé par l'utilisateur %s", userID)
+	return nil
+}
+
+// generateRecoveryCodes remplace les codes de récupération de l'utilisateur. Seules
+// leurs empreintes sont stockées : les codes ne sont retournés qu'une seule fois.
+func (s *mfaService) generateRecoveryCodes(userID string) ([]string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		buffer := make([]byte, 8)
+		if _, err := rand.Read(buffer); err != nil {
+			return nil, err
+		}
+		value := strings.ToLower(encoding.EncodeToString(buffer))[:10]
+		code := value[:5] + "-" + value[5:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode normalise le code saisi (casse, tirets, espaces) avant d'en calculer l'empreinte
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
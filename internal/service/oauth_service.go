@@ -0,0 +1,485 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/config"
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/domain/repositories"
+	"github.com/amirtalbi/examen_go/pkg/auth"
+	"github.com/google/uuid"
+)
+
+// authorizationCodeLifetime est volontairement court : le code est échangé dès la redirection
+const authorizationCodeLifetime = time.Minute
+
+// Ces erreurs ne doivent pas être renvoyées vers redirect_uri, qui n'est pas digne de confiance
+// (RFC 6749 section 4.1.2.1) : elles sont affichées directement à l'utilisateur
+var (
+	ErrUnknownClient      = errors.New("unknown client")
+	ErrInvalidRedirectURI = errors.New("invalid redirect uri")
+)
+
+// OAuthError est une erreur OAuth 2.0 portant le code normalisé (RFC 6749 sections 4.1.2.1 et 5.2)
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func newOAuthError(code string, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+type OAuthService interface {
+	RegisterClient(request models.RegisterClientRequest) (*models.RegisterClientResponse, error)
+	ListClients() ([]models.OAuthClient, error)
+	// Valider une demande d'autorisation avant d'afficher l'écran de connexion
+	ValidateAuthorizeRequest(request models.AuthorizeRequest) (*models.OAuthClient, error)
+	// Émettre un code d'autorisation et retourner l'URL de redirection vers le client
+	Authorize(request models.AuthorizeRequest, user *models.User) (string, error)
+	// Endpoint de token : grants authorization_code et refresh_token
+	Token(request models.TokenRequest, client models.ClientInfo) (*models.TokenResponse, error)
+	// Claims OpenID Connect de l'utilisateur couverts par le scope
+	UserInfo(userID string, scope string) (*models.UserInfo, error)
+	// Authentifier un client enregistré : par son secret s'il est confidentiel, par son seul
+	// identifiant s'il est public. Retourne une *OAuthError invalid_client sinon.
+	AuthenticateClient(clientID string, clientSecret string) (*models.OAuthClient, error)
+}
+
+type oauthService struct {
+	clientRepo     repositories.OAuthClientRepository
+	codeRepo       repositories.AuthorizationCodeRepository
+	userRepo       repositories.UserRepository
+	authService    AuthService
+	sessionService SessionService
+	tokens         *auth.TokenConfig
+	config         *config.Config
+}
+
+func NewOAuthService(
+	clientRepo repositories.OAuthClientRepository,
+	codeRepo repositories.AuthorizationCodeRepository,
+	userRepo repositories.UserRepository,
+	authService AuthService,
+	sessionService SessionService,
+	tokens *auth.TokenConfig,
+	config *config.Config,
+) OAuthService {
+	return &oauthService{
+		clientRepo:     clientRepo,
+		codeRepo:       codeRepo,
+		userRepo:       userRepo,
+		authService:    authService,
+		sessionService: sessionService,
+		tokens:         tokens,
+		config:         config,
+	}
+}
+
+func (s *oauthService) RegisterClient(request models.RegisterClientRequest) (*models.RegisterClientResponse, error) {
+	for _, grantType := range request.GrantTypes {
+		if !isSupportedGrant(grantType) {
+			return nil, newOAuthError("invalid_client_metadata", "Unsupported grant type: "+grantType)
+		}
+	}
+
+	client := &models.OAuthClient{
+		ID:           uuid.New().String(),
+		Name:         request.Name,
+		RedirectURIs: request.RedirectURIs,
+		GrantTypes:   request.GrantTypes,
+		Scopes:       request.Scopes,
+	}
+
+	// Un client machine s'authentifie uniquement par son secret
+	if client.AllowsGrant(models.GrantTypeClientCredentials) && !request.Confidential {
+		return nil, newOAuthError("invalid_client_metadata", "The client_credentials grant requires a confidential client")
+	}
+
+	if client.AllowsGrant(models.GrantTypeAuthorizationCode) {
+		if len(client.RedirectURIs) == 0 {
+			return nil, newOAuthError("invalid_redirect_uri", "At least one redirect URI is required")
+		}
+		for _, redirectURI := range client.RedirectURIs {
+			parsed, err := url.Parse(redirectURI)
+			if err != nil || !parsed.IsAbs() || parsed.Fragment != "" {
+				return nil, newOAuthError("invalid_redirect_uri", "Invalid redirect URI: "+redirectURI)
+			}
+		}
+	}
+
+	var secret string
+	if request.Confidential {
+		var err error
+		secret, err = randomToken()
+		if err != nil {
+			return nil, err
+		}
+		secretHash := auth.HashClientSecret(secret)
+		client.SecretHash = &secretHash
+	}
+
+	if err := s.clientRepo.Create(client); err != nil {
+		return nil, err
+	}
+
+	log.Printf("✅ Client OAuth %s (%s) enregistré", client.ID, client.Name)
+
+	// Le secret n'est stocké que sous forme de hash : il n'est retourné qu'une seule fois
+	return &models.RegisterClientResponse{OAuthClient: *client, ClientSecret: secret}, nil
+}
+
+func (s *oauthService) ListClients() ([]models.OAuthClient, error) {
+	return s.clientRepo.List()
+}
+
+func (s *oauthService) ValidateAuthorizeRequest(request models.AuthorizeRequest) (*models.OAuthClient, error) {
+	client, err := s.clientRepo.FindByID(request.ClientID)
+	if err != nil {
+		return nil, ErrUnknownClient
+	}
+
+	if request.RedirectURI == "" || !client.AllowsRedirectURI(request.RedirectURI) {
+		return nil, ErrInvalidRedirectURI
+	}
+
+	// À partir d'ici, les erreurs peuvent être renvoyées au client via redirect_uri
+	if request.ResponseType != "code" {
+		return client, newOAuthError("unsupported_response_type", "Only the code response type is supported")
+	}
+
+	if !client.AllowsGrant(models.GrantTypeAuthorizationCode) {
+		return client, newOAuthError("unauthorized_client", "Client is not allowed to use the authorization code grant")
+	}
+
+	// PKCE est obligatoire pour tous les clients, et seule la méthode S256 est acceptée
+	if request.CodeChallengeMethod != auth.PKCEMethodS256 || !auth.IsValidCodeChallenge(request.CodeChallenge) {
+		return client, newOAuthError("invalid_request", "A S256 code_challenge is required")
+	}
+
+	if !allowsScope(client, request.Scope) {
+		return client, newOAuthError("invalid_scope", "Requested scope is not allowed for this client")
+	}
+
+	// Sans clé asymétrique, aucun ID token vérifiable ne pourrait être émis
+	if HasScope(request.Scope, models.ScopeOpenID) && !auth.IDTokensEnabled(s.tokens.Keys) {
+		return client, newOAuthError("invalid_scope", "OpenID Connect is not enabled on this server")
+	}
+
+	return client, nil
+}
+
+func (s *oauthService) Authorize(request models.AuthorizeRequest, user *models.User) (string, error) {
+	if _, err := s.ValidateAuthorizeRequest(request); err != nil {
+		return "", err
+	}
+
+	code, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.codeRepo.Create(&models.AuthorizationCode{
+		CodeHash:            hashCode(code),
+		ClientID:            request.ClientID,
+		UserID:              user.ID,
+		RedirectURI:         request.RedirectURI,
+		Scope:               request.Scope,
+		CodeChallenge:       request.CodeChallenge,
+		CodeChallengeMethod: request.CodeChallengeMethod,
+		Nonce:               request.Nonce,
+		AuthTime:            time.Now(),
+		ExpiresAt:           time.Now().Add(authorizationCodeLifetime),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("✅ Code d'autorisation émis pour l'utilisateur %s et le client %s", user.ID, request.ClientID)
+
+	return redirectURL(request.RedirectURI, url.Values{"code": {code}}, request.State)
+}
+
+func (s *oauthService) Token(request models.TokenRequest, info models.ClientInfo) (*models.TokenResponse, error) {
+	client, err := s.authenticateClient(request.ClientID, request.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if !client.AllowsGrant(request.GrantType) {
+		if !isSupportedGrant(request.GrantType) {
+			return nil, newOAuthError("unsupported_grant_type", "Unsupported grant type")
+		}
+		return nil, newOAuthError("unauthorized_client", "Client is not allowed to use this grant type")
+	}
+
+	if request.GrantType == models.GrantTypeClientCredentials {
+		return s.issueClientToken(client, request.Scope)
+	}
+
+	var response *models.AuthResponse
+	var idToken string
+	switch request.GrantType {
+	case models.GrantTypeAuthorizationCode:
+		response, idToken, err = s.exchangeAuthorizationCode(client, request, info)
+	default:
+		response, err = s.authService.RefreshTokenForClient(request.RefreshToken, client.ID)
+		if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrRefreshTokenReuse) || errors.Is(err, ErrUserNotFound) {
+			err = newOAuthError("invalid_grant", "Invalid refresh token")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenResponse{
+		AccessToken:  response.Token,
+		TokenType:    "Bearer",
+		ExpiresIn:    response.ExpiresIn,
+		RefreshToken: response.RefreshToken,
+		Scope:        response.Scope,
+		IDToken:      idToken,
+	}, nil
+}
+
+// issueClientToken émet un token d'accès dont le sujet est le client lui-même (RFC 6749 section 4.4).
+// Aucun refresh token n'est émis : le client peut toujours en redemander un avec son secret.
+func (s *oauthService) issueClientToken(client *models.OAuthClient, scope string) (*models.TokenResponse, error) {
+	if !client.IsConfidential() {
+		return nil, newOAuthError("unauthorized_client", "Public clients cannot use the client_credentials grant")
+	}
+
+	// Sans scope demandé, le client reçoit tous les scopes qui lui ont été attribués
+	if scope == "" {
+		scope = strings.Join(client.Scopes, " ")
+	} else if !allowsScope(client, scope) {
+		return nil, newOAuthError("invalid_scope", "Requested scope is not allowed for this client")
+	}
+
+	// Un client machine n'appartient à aucune organisation : la durée de l'instance s'applique
+	token, err := auth.GenerateToken(auth.AccessClaims{
+		ClientID: client.ID,
+		Scope:    scope,
+	}, s.tokens, s.config.TokenExpiryHours)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("✅ Token d'accès émis pour le client machine %s (scope: %q)", client.ID, scope)
+
+	return &models.TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   s.config.TokenExpiryHours * 3600,
+		Scope:       scope,
+	}, nil
+}
+
+// exchangeAuthorizationCode échange un code contre une session (RFC 6749 section 4.1.3, RFC 7636 section 4.6).
+// Un ID token est émis en plus lorsque le scope openid a été accordé.
+func (s *oauthService) exchangeAuthorizationCode(client *models.OAuthClient, request models.TokenRequest, info models.ClientInfo) (*models.AuthResponse, string, error) {
+	invalidGrant := newOAuthError("invalid_grant", "Invalid authorization code")
+
+	codeHash := hashCode(request.Code)
+	code, err := s.codeRepo.Consume(codeHash)
+	if errors.Is(err, repositories.ErrAuthorizationCodeUsed) {
+		// Un code rejoué a probablement été intercepté : la session déjà ouverte avec lui est révoquée
+		log.Printf("🚨 Code d'autorisation rejoué pour le client %s", code.ClientID)
+		if code.SessionID != nil {
+			if err := s.sessionService.RevokeSession(code.UserID, *code.SessionID); err != nil {
+				log.Printf("❌ Erreur lors de la révocation de la session %s: %v", *code.SessionID, err)
+			}
+		}
+		return nil, "", invalidGrant
+	}
+	if err != nil {
+		return nil, "", invalidGrant
+	}
+
+	if code.ClientID != client.ID || code.RedirectURI != request.RedirectURI || time.Now().After(code.ExpiresAt) {
+		return nil, "", invalidGrant
+	}
+
+	if !auth.VerifyPKCE(request.CodeVerifier, code.CodeChallenge) {
+		log.Printf("❌ Vérification PKCE échouée pour le client %s", client.ID)
+		return nil, "", invalidGrant
+	}
+
+	user, err := s.userRepo.FindByID(code.UserID)
+	if err != nil || user == nil {
+		return nil, "", invalidGrant
+	}
+
+	info.DeviceName = client.Name
+	info.ClientID = client.ID
+	info.Scope = code.Scope
+	response, err := s.authService.StartSession(user, info)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := s.codeRepo.AttachSession(codeHash, response.SessionID); err != nil {
+		log.Printf("Erreur lors du rattachement de la session %s au code d'autorisation: %v", response.SessionID, err)
+	}
+
+	if !HasScope(code.Scope, models.ScopeOpenID) {
+		return response, "", nil
+	}
+
+	idToken, err := s.generateIDToken(user, code, response)
+	if err != nil {
+		return nil, "", err
+	}
+	return response, idToken, nil
+}
+
+// generateIDToken construit l'ID token avec les claims de profil couverts par le scope.
+// Il expire avec le token d'accès qu'il accompagne.
+func (s *oauthService) generateIDToken(user *models.User, code *models.AuthorizationCode, response *models.AuthResponse) (string, error) {
+	userInfo := buildUserInfo(user, code.Scope)
+	return auth.GenerateIDToken(auth.IDClaims{
+		UserID:        user.ID,
+		ClientID:      code.ClientID,
+		Nonce:         code.Nonce,
+		AuthTime:      code.AuthTime,
+		AccessToken:   response.Token,
+		Name:          userInfo.Name,
+		Email:         userInfo.Email,
+		EmailVerified: userInfo.EmailVerified,
+	}, s.tokens, response.ExpiresIn/3600)
+}
+
+func (s *oauthService) UserInfo(userID string, scope string) (*models.UserInfo, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+	return buildUserInfo(user, scope), nil
+}
+
+// buildUserInfo ne retient que les claims des scopes profile et email (OpenID Connect Core section 5.4)
+func buildUserInfo(user *models.User, scope string) *models.UserInfo {
+	userInfo := &models.UserInfo{Subject: user.ID}
+	if HasScope(scope, models.ScopeProfile) {
+		userInfo.Name = user.Name
+		userInfo.UpdatedAt = user.UpdatedAt.Unix()
+	}
+	if HasScope(scope, models.ScopeEmail) {
+		emailVerified := user.EmailVerifiedAt != nil
+		userInfo.Email = user.Email
+		userInfo.EmailVerified = &emailVerified
+	}
+	return userInfo
+}
+
+func (s *oauthService) AuthenticateClient(clientID string, clientSecret string) (*models.OAuthClient, error) {
+	return s.authenticateClient(clientID, clientSecret)
+}
+
+// authenticateClient authentifie un client confidentiel par son secret ; un client public
+// ne fournit que son identifiant, PKCE tenant lieu de preuve
+func (s *oauthService) authenticateClient(clientID string, clientSecret string) (*models.OAuthClient, error) {
+	invalidClient := newOAuthError("invalid_client", "Client authentication failed")
+
+	client, err := s.clientRepo.FindByID(clientID)
+	if err != nil {
+		return nil, invalidClient
+	}
+
+	if client.IsConfidential() {
+		if clientSecret == "" || !auth.CheckClientSecret(clientSecret, *client.SecretHash) {
+			return nil, invalidClient
+		}
+	} else if clientSecret != "" {
+		return nil, invalidClient
+	}
+
+	return client, nil
+}
+
+func isSupportedGrant(grantType string) bool {
+	switch grantType {
+	case models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken, models.GrantTypeClientCredentials:
+		return true
+	}
+	return false
+}
+
+// HasScope indique si un scope délimité par des espaces contient la valeur donnée
+func HasScope(scope string, value string) bool {
+	for _, granted := range strings.Fields(scope) {
+		if granted == value {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsScope vérifie que chaque scope demandé fait partie des scopes du client
+func allowsScope(client *models.OAuthClient, scope string) bool {
+	for _, requested := range strings.Fields(scope) {
+		allowed := false
+		for _, clientScope := range client.Scopes {
+			if clientScope == requested {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// AuthorizeErrorURL construit la redirection portant une erreur vers le client (RFC 6749 section 4.1.2.1)
+func AuthorizeErrorURL(request models.AuthorizeRequest, err *OAuthError) (string, error) {
+	return redirectURL(request.RedirectURI, url.Values{
+		"error":             {err.Code},
+		"error_description": {err.Description},
+	}, request.State)
+}
+
+func redirectURL(redirectURI string, params url.Values, state string) (string, error) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		return "", err
+	}
+
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	target.RawQuery = query.Encode()
+	return target.String(), nil
+}
+
+// randomToken génère une valeur aléatoire de 256 bits encodée en base64url
+func randomToken() (string, error) {
+	buffer := make([]byte, 32)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+// hashCode calcule l'empreinte sous laquelle un code d'autorisation est stocké
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
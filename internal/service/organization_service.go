@@ -0,0 +1,395 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"regexp"
+
+	"github.com/amirtalbi/examen_go/internal/config"
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/domain/repositories"
+	"github.com/amirtalbi/examen_go/pkg/auth"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrOrganizationNotFound        = errors.New("organization not found")
+	ErrOrganizationAlreadyExists   = errors.New("organization already exists")
+	ErrInvalidOrganizationSlug     = errors.New("invalid organization slug")
+	ErrInvalidOrganizationSettings = errors.New("invalid organization settings")
+	// ErrNotOrganizationMember est retourné quand l'utilisateur n'appartient pas à l'organisation demandée
+	ErrNotOrganizationMember = errors.New("not a member of the organization")
+	// ErrForeignAccount protège les comptes qu'une organisation ne possède pas : elle ne gère
+	// que leur adhésion, et un compte d'une organisation ne peut en rejoindre une autre
+	ErrForeignAccount = errors.New("account is not owned by the organization")
+)
+
+// organizationSlugPattern limite les slugs à des identifiants lisibles dans une URL
+var organizationSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,49}$`)
+
+// maxTokenExpiryHours borne la durée des tokens d'une organisation : les clés de signature
+// remplacées ne sont conservées que le temps d'un refresh token
+var maxTokenExpiryHours = int(auth.RefreshTokenLifetime.Hours())
+
+type OrganizationService interface {
+	CreateOrganization(request models.CreateOrganizationRequest) (*models.Organization, error)
+	ListOrganizations() ([]models.Organization, error)
+	GetOrganization(orgID string) (*models.Organization, error)
+	// Modifier le nom et remplacer les paramètres propres à l'organisation
+	UpdateOrganization(orgID string, request models.UpdateOrganizationRequest) (*models.Organization, error)
+	// Supprimer l'organisation et ses comptes ; les autres membres perdent seulement leur adhésion
+	DeleteOrganization(orgID string) error
+	// Lister une page de membres, avec leurs rôles dans l'organisation
+	ListMembers(orgID string, query models.ListUsersQuery) (*models.UserPage, error)
+	// Ajouter un compte existant aux membres, ou remplacer ses rôles s'il en fait déjà partie
+	AddMember(orgID string, request models.AddMemberRequest) (*models.Membership, error)
+	// Remplacer les rôles du membre ; il est déconnecté de l'organisation s'il en perd un
+	UpdateMemberRoles(orgID string, userID string, request models.UpdateMemberRolesRequest) (*models.Membership, error)
+	// Retirer le membre et fermer ses sessions dans l'organisation. Un compte de l'organisation
+	// ne peut qu'être supprimé.
+	RemoveMember(orgID string, userID string) error
+	// Organisations dont l'utilisateur est membre
+	ListUserOrganizations(userID string) ([]models.Organization, error)
+	// Rôles et permissions du membre, tels qu'inscrits dans ses tokens d'accès émis dans l'organisation
+	MemberAuthorizations(orgID string, userID string) ([]string, []string, error)
+	// Vue des comptes limitée aux membres de l'organisation ; les comptes qu'elle crée lui
+	// appartiennent si l'unicité des emails est propre à chaque organisation
+	Members(orgID string) repositories.UserRepository
+}
+
+type organizationService struct {
+	orgRepo        repositories.OrganizationRepository
+	userRepo       repositories.UserRepository
+	rbac           RBACService
+	sessionService SessionService
+	config         *config.Config
+}
+
+func NewOrganizationService(
+	orgRepo repositories.OrganizationRepository,
+	userRepo repositories.UserRepository,
+	rbac RBACService,
+	sessionService SessionService,
+	config *config.Config,
+) OrganizationService {
+	return &organizationService{
+		orgRepo:        orgRepo,
+		userRepo:       userRepo,
+		rbac:           rbac,
+		sessionService: sessionService,
+		config:         config,
+	}
+}
+
+func (s *organizationService) Members(orgID string) repositories.UserRepository {
+	return s.userRepo.ForOrganization(orgID, s.config.Tenancy.TenantScopedEmails())
+}
+
+func (s *organizationService) CreateOrganization(request models.CreateOrganizationRequest) (*models.Organization, error) {
+	if !organizationSlugPattern.MatchString(request.Slug) {
+		return nil, ErrInvalidOrganizationSlug
+	}
+	if err := s.validateSettings(request.Settings); err != nil {
+		return nil, err
+	}
+
+	organization := &models.Organization{
+		ID:       uuid.New().String(),
+		Slug:     request.Slug,
+		Name:     request.Name,
+		Settings: request.Settings,
+	}
+	if err := s.orgRepo.Create(organization); err != nil {
+		if errors.Is(err, repositories.ErrOrganizationAlreadyExists) {
+			return nil, ErrOrganizationAlreadyExists
+		}
+		return nil, err
+	}
+
+	log.Printf("🏢 Organisation %s (%s) créée", organization.Slug, organization.ID)
+	return organization, nil
+}
+
+func (s *organizationService) ListOrganizations() ([]models.Organization, error) {
+	return s.orgRepo.ListAll()
+}
+
+func (s *organizationService) GetOrganization(orgID string) (*models.Organization, error) {
+	organization, err := s.orgRepo.FindByID(orgID)
+	if errors.Is(err, repositories.ErrOrganizationNotFound) {
+		return nil, ErrOrganizationNotFound
+	}
+	return organization, err
+}
+
+func (s *organizationService) UpdateOrganization(orgID string, request models.UpdateOrganizationRequest) (*models.Organization, error) {
+	organization, err := s.GetOrganization(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Name != nil {
+		organization.Name = *request.Name
+	}
+	if request.Settings != nil {
+		if err := s.validateSettings(*request.Settings); err != nil {
+			return nil, err
+		}
+		organization.Settings = *request.Settings
+	}
+
+	if err := s.orgRepo.Update(organization); err != nil {
+		if errors.Is(err, repositories.ErrOrganizationNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, err
+	}
+
+	log.Printf("🏢 Organisation %s modifiée", organization.ID)
+	return organization, nil
+}
+
+func (s *organizationService) DeleteOrganization(orgID string) error {
+	organization, err := s.GetOrganization(orgID)
+	if err != nil {
+		return err
+	}
+
+	// Chaque membre est retiré (ou supprimé s'il appartient à l'organisation) après avoir été
+	// déconnecté : ses tokens ne doivent pas survivre à l'organisation
+	members := s.Members(organization.ID)
+	for {
+		users, err := members.List(models.UserFilter{Limit: maxUserPageSize})
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			break
+		}
+		for _, user := range users {
+			if _, err := s.signOut(organization.ID, user.ID); err != nil {
+				return err
+			}
+			if err := members.Delete(user.ID); err != nil && !errors.Is(err, repositories.ErrUserNotFound) {
+				return err
+			}
+		}
+	}
+
+	if err := s.orgRepo.Delete(organization.ID); err != nil {
+		if errors.Is(err, repositories.ErrOrganizationNotFound) {
+			return ErrOrganizationNotFound
+		}
+		return err
+	}
+
+	log.Printf("🗑️ Organisation %s (%s) supprimée", organization.Slug, organization.ID)
+	return nil
+}
+
+func (s *organizationService) ListMembers(orgID string, query models.ListUsersQuery) (*models.UserPage, error) {
+	if _, err := s.GetOrganization(orgID); err != nil {
+		return nil, err
+	}
+
+	return listUserPage(s.Members(orgID), query, func(user *models.User) ([]string, error) {
+		membership, err := s.userRepo.FindMembership(orgID, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return membership.Roles, nil
+	})
+}
+
+func (s *organizationService) AddMember(orgID string, request models.AddMemberRequest) (*models.Membership, error) {
+	if _, err := s.GetOrganization(orgID); err != nil {
+		return nil, err
+	}
+	roles, err := s.validateRoles(request.Roles)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(request.UserID)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+	if user.OrgID != nil && *user.OrgID != orgID {
+		return nil, ErrForeignAccount
+	}
+
+	// Les membres sont désignés par leur email dans l'organisation : deux membres ne
+	// peuvent pas partager la même adresse
+	if existing, err := s.Members(orgID).FindByEmail(user.Email); err == nil && existing != nil && existing.ID != user.ID {
+		return nil, ErrUserAlreadyExists
+	}
+
+	previous, err := s.userRepo.FindMembership(orgID, user.ID)
+	if err != nil && !errors.Is(err, repositories.ErrMembershipNotFound) {
+		return nil, err
+	}
+
+	membership := &models.Membership{OrgID: orgID, UserID: user.ID, Roles: roles}
+	if err := s.userRepo.SaveMembership(membership); err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	if previous != nil && lostRole(previous.Roles, roles) {
+		if _, err := s.signOut(orgID, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("🏢 Utilisateur %s membre de l'organisation %s avec les rôles %v", user.ID, orgID, roles)
+	return membership, nil
+}
+
+func (s *organizationService) UpdateMemberRoles(orgID string, userID string, request models.UpdateMemberRolesRequest) (*models.Membership, error) {
+	roles, err := s.validateRoles(request.Roles)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.findMembership(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	membership := &models.Membership{OrgID: orgID, UserID: userID, Roles: roles}
+	if err := s.userRepo.SaveMembership(membership); err != nil {
+		return nil, err
+	}
+	if lostRole(previous.Roles, roles) {
+		if _, err := s.signOut(orgID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("🏢 Rôles de l'utilisateur %s dans l'organisation %s remplacés par %v", userID, orgID, roles)
+	return membership, nil
+}
+
+func (s *organizationService) RemoveMember(orgID string, userID string) error {
+	if _, err := s.findMembership(orgID, userID); err != nil {
+		return err
+	}
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || user == nil {
+		return ErrUserNotFound
+	}
+	// Sans adhésion, le compte ne serait plus accessible par personne
+	if user.OrgID != nil {
+		return ErrForeignAccount
+	}
+
+	if err := s.userRepo.DeleteMembership(orgID, userID); err != nil {
+		if errors.Is(err, repositories.ErrMembershipNotFound) {
+			return ErrNotOrganizationMember
+		}
+		return err
+	}
+	if _, err := s.signOut(orgID, user.ID); err != nil {
+		return err
+	}
+
+	log.Printf("🏢 Utilisateur %s retiré de l'organisation %s", userID, orgID)
+	return nil
+}
+
+func (s *organizationService) ListUserOrganizations(userID string) ([]models.Organization, error) {
+	memberships, err := s.userRepo.ListMemberships(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	organizations := make([]models.Organization, 0, len(memberships))
+	for _, membership := range memberships {
+		organization, err := s.orgRepo.FindByID(membership.OrgID)
+		if errors.Is(err, repositories.ErrOrganizationNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		organizations = append(organizations, *organization)
+	}
+	return organizations, nil
+}
+
+func (s *organizationService) MemberAuthorizations(orgID string, userID string) ([]string, []string, error) {
+	membership, err := s.findMembership(orgID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.rbac.RoleAuthorizations(membership.Roles)
+}
+
+func (s *organizationService) findMembership(orgID string, userID string) (*models.Membership, error) {
+	membership, err := s.userRepo.FindMembership(orgID, userID)
+	if errors.Is(err, repositories.ErrMembershipNotFound) {
+		return nil, ErrNotOrganizationMember
+	}
+	return membership, err
+}
+
+// validateRoles vérifie que les rôles existent et les retourne sans doublon
+func (s *organizationService) validateRoles(requested []string) (pq.StringArray, error) {
+	known, err := s.rbac.ListRoles()
+	if err != nil {
+		return nil, err
+	}
+	catalog := make(map[string]struct{}, len(known))
+	for _, role := range known {
+		catalog[role.Name] = struct{}{}
+	}
+
+	roles := pq.StringArray{}
+	for _, name := range requested {
+		if _, ok := catalog[name]; !ok {
+			return nil, ErrRoleNotFound
+		}
+		if !containsString(roles, name) {
+			roles = append(roles, name)
+		}
+	}
+	return roles, nil
+}
+
+// validateSettings refuse les valeurs qui désactiveraient une règle par erreur ; un champ
+// absent garde la valeur de l'instance
+func (s *organizationService) validateSettings(settings models.OrganizationSettings) error {
+	inRange := func(value *int, min int, max int) bool {
+		return value == nil || (*value >= min && *value <= max)
+	}
+
+	maxLength := s.config.PasswordPolicy.MaxLength
+	if maxLength <= 0 {
+		maxLength = 1024
+	}
+	if !inRange(settings.TokenExpiryHours, 1, maxTokenExpiryHours) ||
+		!inRange(settings.PasswordMinLength, 1, maxLength) ||
+		!inRange(settings.PasswordRequiredClasses, 0, 4) ||
+		!inRange(settings.PasswordMinStrength, 0, 4) ||
+		!inRange(settings.PasswordHistorySize, 0, 24) {
+		return ErrInvalidOrganizationSettings
+	}
+	return nil
+}
+
+// signOut ferme les sessions ouvertes par l'utilisateur dans l'organisation : ses tokens
+// d'accès en cours portent encore ses anciens rôles
+func (s *organizationService) signOut(orgID string, userID string) (int, error) {
+	return s.sessionService.RevokeOrganizationSessions(userID, orgID)
+}
+
+// lostRole indique si un des rôles précédents n'est plus accordé
+func lostRole(previous []string, current []string) bool {
+	for _, role := range previous {
+		if !containsString(current, role) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/config"
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/domain/repositories"
+	"github.com/amirtalbi/examen_go/internal/mail"
+	"github.com/amirtalbi/examen_go/pkg/auth"
+)
+
+const (
+	// emailCodeLifetime laisse le temps de consulter sa boîte mail
+	emailCodeLifetime = 10 * time.Minute
+	// maxEmailCodeAttempts est le nombre d'essais avant invalidation du code
+	maxEmailCodeAttempts = 5
+)
+
+var ErrInvalidLoginCode = errors.New("invalid login code")
+
+type PasswordlessService interface {
+	// Envoyer un lien de connexion à usage unique au compte désigné par l'email dans
+	// l'organisation (l'instance si orgID est vide)
+	SendMagicLink(email string, orgID string) error
+	LoginWithMagicLink(request models.MagicLinkLoginRequest) (*models.AuthResponse, error)
+	// Envoyer un code de connexion à 6 chiffres, qui remplace le précédent. Aucun code n'est
+	// envoyé, et une *AccountLockedError est retournée, tant que le compte ou l'adresse IP
+	// du demandeur est bloqué.
+	SendLoginCode(email string, orgID string, ipAddress string) error
+	// Les codes erronés comptent comme des échecs de connexion du compte : renvoyer un code
+	// ne donne pas d'essais supplémentaires
+	LoginWithCode(request models.EmailCodeLoginRequest) (*models.AuthResponse, error)
+}
+
+type passwordlessService struct {
+	userRepo repositories.UserRepository
+	codeRepo repositories.EmailLoginCodeRepository
+	// Les liens magiques sont à usage unique : leur jti est révoqué à la connexion
+	revocations   repositories.RevocationStore
+	authService   AuthService
+	organizations OrganizationService
+	lockout       LockoutService
+	tokens        *auth.TokenConfig
+	mailer        mail.Mailer
+	config        *config.Config
+}
+
+func NewPasswordlessService(
+	userRepo repositories.UserRepository,
+	codeRepo repositories.EmailLoginCodeRepository,
+	revocations repositories.RevocationStore,
+	authService AuthService,
+	organizations OrganizationService,
+	lockout LockoutService,
+	tokens *auth.TokenConfig,
+	mailer mail.Mailer,
+	config *config.Config,
+) PasswordlessService {
+	return &passwordlessService{
+		userRepo:      userRepo,
+		codeRepo:      codeRepo,
+		revocations:   revocations,
+		authService:   authService,
+		organizations: organizations,
+		lockout:       lockout,
+		tokens:        tokens,
+		mailer:        mailer,
+		config:        config,
+	}
+}
+
+func (s *passwordlessService) SendMagicLink(email string, orgID string) error {
+	user, err := s.usersIn(orgID).FindByEmail(email)
+	if err != nil || user == nil {
+		return ErrUserNotFound
+	}
+
+	// Le lien désigne le compte par son identifiant : la même adresse peut appartenir
+	// à plusieurs organisations
+	token, _, err := auth.GenerateMagicLinkToken(user.ID, user.Email, s.tokens)
+	if err != nil {
+		return err
+	}
+
+	message, err := mail.Render(user.Email, mail.TemplateMagicLink, mail.LinkData{
+		Name:      user.Name,
+		Link:      s.config.MagicLinkURL + "?token=" + url.QueryEscape(token),
+		ExpiresIn: auth.MagicLinkLifetime,
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.mailer.Send(message); err != nil {
+		return err
+	}
+
+	log.Printf("🔗 Lien de connexion envoyé à l'utilisateur %s", user.ID)
+	return nil
+}
+
+// LoginWithMagicLink échange le token du lien contre une session. L'échange passe par un
+// POST : les scanners de liens des messageries ne peuvent pas consommer le token.
+func (s *passwordlessService) LoginWithMagicLink(request models.MagicLinkLoginRequest) (*models.AuthResponse, error) {
+	claims, err := auth.ValidateMagicLinkToken(request.Token, s.tokens)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := s.revocations.IsRevoked(claims.TokenID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrInvalidToken
+	}
+	if err := s.revocations.Revoke(claims.TokenID, claims.ExpiresAt); err != nil {
+		return nil, err
+	}
+
+	// Un lien émis avant un changement d'adresse n'est plus valable
+	user, err := s.userRepo.FindByID(claims.UserID)
+	if err != nil || user == nil || user.Email != claims.Email {
+		return nil, ErrInvalidToken
+	}
+
+	if err := s.confirmEmail(user); err != nil {
+		return nil, err
+	}
+
+	log.Printf("🔗 Connexion par lien magique pour l'utilisateur %s", user.ID)
+	return s.authService.ContinueLogin(user, request.ClientInfo)
+}
+
+func (s *passwordlessService) SendLoginCode(email string, orgID string, ipAddress string) error {
+	user, err := s.usersIn(orgID).FindByEmail(email)
+	if err != nil || user == nil {
+		return ErrUserNotFound
+	}
+	if err := s.lockout.Check(LockoutAccount{User: user}, ipAddress); err != nil {
+		return err
+	}
+
+	code, err := auth.GenerateLoginCode()
+	if err != nil {
+		return err
+	}
+
+	// Un nouveau code remplace le précédent et remet ses essais à zéro, mais pas les échecs
+	// comptés sur le compte par le service de blocage
+	err = s.codeRepo.Save(&models.EmailLoginCode{
+		UserID:    user.ID,
+		CodeHash:  s.hashLoginCode(user.ID, code),
+		ExpiresAt: time.Now().Add(emailCodeLifetime),
+	})
+	if err != nil {
+		return err
+	}
+
+	message, err := mail.Render(user.Email, mail.TemplateLoginCode, mail.CodeData{
+		Name:      user.Name,
+		Code:      code,
+		ExpiresIn: emailCodeLifetime,
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.mailer.Send(message); err != nil {
+		return err
+	}
+
+	log.Printf("🔢 Code de connexion envoyé à l'utilisateur %s", user.ID)
+	return nil
+}
+
+func (s *passwordlessService) LoginWithCode(request models.EmailCodeLoginRequest) (*models.AuthResponse, error) {
+	user, err := s.usersIn(request.OrgID).FindByEmail(request.Email)
+	if err != nil {
+		user = nil
+	}
+	account := LockoutAccount{User: user, OrgID: request.OrgID, Email: request.Email}
+	if err := s.lockout.Check(account, request.IPAddress); err != nil {
+		return nil, err
+	}
+	if user == nil {
+		s.recordLoginFailure(account, request.IPAddress)
+		return nil, ErrInvalidLoginCode
+	}
+
+	record, err := s.codeRepo.Find(user.ID)
+	if errors.Is(err, repositories.ErrEmailLoginCodeNotFound) {
+		return nil, ErrInvalidLoginCode
+	}
+	if err != nil {
+		return nil, err
+	}
+	if record.Attempts >= maxEmailCodeAttempts || time.Now().After(record.ExpiresAt) {
+		if err := s.codeRepo.Delete(user.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidLoginCode
+	}
+
+	codeHash := s.hashLoginCode(user.ID, strings.TrimSpace(request.Code))
+	if !hmac.Equal([]byte(codeHash), []byte(record.CodeHash)) {
+		s.recordLoginFailure(account, request.IPAddress)
+		attempts, err := s.codeRepo.RecordFailure(user.ID)
+		if err != nil && !errors.Is(err, repositories.ErrEmailLoginCodeNotFound) {
+			return nil, err
+		}
+		if attempts >= maxEmailCodeAttempts {
+			log.Printf("🚫 Code de connexion invalidé après %d essais pour l'utilisateur %s", attempts, user.ID)
+			if err := s.codeRepo.Delete(user.ID); err != nil {
+				return nil, err
+			}
+		}
+		return nil, ErrInvalidLoginCode
+	}
+
+	// La consommation atomique garantit qu'un même code n'ouvre qu'une session
+	consumed, err := s.codeRepo.Consume(user.ID, codeHash, maxEmailCodeAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, ErrInvalidLoginCode
+	}
+
+	if err := s.confirmEmail(user); err != nil {
+		return nil, err
+	}
+
+	log.Printf("🔢 Connexion par code email pour l'utilisateur %s", user.ID)
+	response, err := s.authService.ContinueLogin(user, request.ClientInfo)
+	if err != nil {
+		return nil, err
+	}
+	// Avec un second facteur, la remise à zéro attend le code MFA
+	if err := s.lockout.RecordSuccess(account); err != nil {
+		log.Printf("❌ Erreur lors de la remise à zéro des échecs de connexion de l'utilisateur %s: %v", user.ID, err)
+	}
+	return response, nil
+}
+
+// recordLoginFailure compte un code erroné comme un échec de connexion. Une erreur du
+// stockage n'empêche pas de répondre : le code est de toute façon refusé.
+func (s *passwordlessService) recordLoginFailure(account LockoutAccount, ipAddress string) {
+	if err := s.lockout.RecordFailure(account, ipAddress); err != nil {
+		log.Printf("❌ Erreur lors de l'enregistrement d'un échec de connexion: %v", err)
+	}
+}
+
+// usersIn retourne les comptes désignés par leur email dans l'organisation, ou ceux de
+// l'instance si orgID est vide
+func (s *passwordlessService) usersIn(orgID string) repositories.UserRepository {
+	if orgID == "" {
+		return s.userRepo
+	}
+	return s.organizations.Members(orgID)
+}
+
+// confirmEmail marque l'adresse comme vérifiée : le lien ou le code n'a pu être lu que
+// dans la boîte de réception de l'utilisateur
+func (s *passwordlessService) confirmEmail(user *models.User) error {
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	verifiedAt := time.Now()
+	if err := s.userRepo.MarkEmailVerified(user.ID, verifiedAt); err != nil {
+		return err
+	}
+	user.EmailVerifiedAt = &verifiedAt
+	return nil
+}
+
+// hashLoginCode calcule un HMAC du code : avec seulement un million de valeurs possibles,
+// une empreinte sans secret serait retrouvée immédiatement à partir de la base
+func (s *passwordlessService) hashLoginCode(userID string, code string) string {
+	mac := hmac.New(sha256.New, []byte(s.config.LoginCodeSecret))
+	mac.Write([]byte(userID + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
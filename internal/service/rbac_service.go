@@ -0,0 +1,467 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"sort"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/domain/repositories"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrRoleAlreadyExists = errors.New("role already exists")
+	ErrInvalidRoleName   = errors.New("invalid role name")
+	ErrUnknownPermission = errors.New("unknown permission")
+	// ErrProtectedRole est retourné pour toute modification du rôle admin
+	ErrProtectedRole = errors.New("role is protected")
+	// ErrAdminAlreadyExists empêche de réutiliser l'amorçage une fois un administrateur nommé
+	ErrAdminAlreadyExists = errors.New("an administrator already exists")
+	// ErrLastAdmin empêche de retirer le rôle admin à son dernier titulaire
+	ErrLastAdmin = errors.New("cannot remove the last administrator")
+)
+
+// roleNamePattern limite les noms de rôle à des identifiants lisibles dans un JWT et une URL
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,49}$`)
+
+type RBACService interface {
+	// Enregistrer le catalogue des permissions et le rôle admin qui les possède toutes
+	EnsureDefaults() error
+	ListPermissions() ([]models.Permission, error)
+	ListRoles() ([]models.Role, error)
+	CreateRole(request models.CreateRoleRequest) (*models.Role, error)
+	// Remplacer les permissions du rôle. Les titulaires qui perdent une permission sont
+	// déconnectés, pour que leurs tokens ne la portent plus.
+	UpdateRolePermissions(name string, request models.UpdateRolePermissionsRequest) (*models.Role, error)
+	DeleteRole(name string) error
+	ListUserRoles(userID string) ([]models.Role, error)
+	AssignRole(userID string, roleName string) error
+	// Retirer le rôle et déconnecter l'utilisateur, pour que ses tokens ne le portent plus
+	UnassignRole(userID string, roleName string) error
+	// Remplacer tous les rôles de l'utilisateur ; il est déconnecté s'il en perd un
+	SetUserRoles(userID string, roleNames []string) error
+	// Retourne ErrLastAdmin si l'utilisateur est le seul administrateur
+	RequireOtherAdmin(userID string) error
+	// Rôles et permissions de l'utilisateur, tels qu'inscrits dans ses tokens d'accès
+	UserAuthorizations(userID string) ([]string, []string, error)
+	// Rôles existants parmi ceux nommés et permissions qu'ils accordent ; un rôle supprimé
+	// depuis qu'il a été attribué est ignoré
+	RoleAuthorizations(roleNames []string) ([]string, []string, error)
+	// Nommer le premier administrateur. Retourne ErrAdminAlreadyExists s'il y en a déjà un.
+	BootstrapAdmin(email string) (*models.User, error)
+}
+
+type rbacService struct {
+	roleRepo       repositories.RoleRepository
+	userRepo       repositories.UserRepository
+	sessionService SessionService
+}
+
+func NewRBACService(
+	roleRepo repositories.RoleRepository,
+	userRepo repositories.UserRepository,
+	sessionService SessionService,
+) RBACService {
+	return &rbacService{
+		roleRepo:       roleRepo,
+		userRepo:       userRepo,
+		sessionService: sessionService,
+	}
+}
+
+func (s *rbacService) EnsureDefaults() error {
+	permissions := make([]string, 0, len(models.DefaultPermissions))
+	for _, permission := range models.DefaultPermissions {
+		if err := s.roleRepo.SavePermission(permission); err != nil {
+			return err
+		}
+		permissions = append(permissions, permission.Name)
+	}
+
+	admin, err := s.roleRepo.FindRoleByName(models.RoleAdmin)
+	if errors.Is(err, repositories.ErrRoleNotFound) {
+		err = s.roleRepo.CreateRole(&models.Role{
+			ID:          uuid.New().String(),
+			Name:        models.RoleAdmin,
+			Description: "Full access to user and role management",
+			Permissions: permissions,
+		})
+		if errors.Is(err, repositories.ErrRoleAlreadyExists) {
+			// Créé entre-temps par une autre instance
+			return nil
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	// Les permissions ajoutées depuis le dernier démarrage sont accordées au rôle admin
+	return s.roleRepo.SetRolePermissions(admin.ID, permissions)
+}
+
+func (s *rbacService) ListPermissions() ([]models.Permission, error) {
+	return s.roleRepo.ListPermissions()
+}
+
+func (s *rbacService) ListRoles() ([]models.Role, error) {
+	return s.roleRepo.ListRoles()
+}
+
+func (s *rbacService) CreateRole(request models.CreateRoleRequest) (*models.Role, error) {
+	if !roleNamePattern.MatchString(request.Name) {
+		return nil, ErrInvalidRoleName
+	}
+	permissions, err := s.validatePermissions(request.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{
+		ID:          uuid.New().String(),
+		Name:        request.Name,
+		Description: request.Description,
+		Permissions: permissions,
+	}
+	if err := s.roleRepo.CreateRole(role); err != nil {
+		if errors.Is(err, repositories.ErrRoleAlreadyExists) {
+			return nil, ErrRoleAlreadyExists
+		}
+		return nil, err
+	}
+
+	log.Printf("✅ Rôle %s créé avec les permissions %v", role.Name, permissions)
+	return role, nil
+}
+
+func (s *rbacService) UpdateRolePermissions(name string, request models.UpdateRolePermissionsRequest) (*models.Role, error) {
+	role, err := s.findMutableRole(name)
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := s.validatePermissions(request.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.roleRepo.SetRolePermissions(role.ID, permissions); err != nil {
+		return nil, err
+	}
+
+	removed := false
+	kept := make(map[string]struct{}, len(permissions))
+	for _, permission := range permissions {
+		kept[permission] = struct{}{}
+	}
+	for _, permission := range role.Permissions {
+		if _, ok := kept[permission]; !ok {
+			removed = true
+			break
+		}
+	}
+	if removed {
+		userIDs, err := s.roleRepo.ListRoleUserIDs(role.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.signOut(userIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	role.Permissions = permissions
+	log.Printf("✅ Permissions du rôle %s mises à jour: %v", role.Name, permissions)
+	return role, nil
+}
+
+func (s *rbacService) DeleteRole(name string) error {
+	role, err := s.findMutableRole(name)
+	if err != nil {
+		return err
+	}
+
+	// Les titulaires sont lus avant la suppression, qui efface aussi les attributions
+	userIDs, err := s.roleRepo.ListRoleUserIDs(role.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.roleRepo.DeleteRole(role.ID); err != nil {
+		return err
+	}
+	if err := s.signOut(userIDs); err != nil {
+		return err
+	}
+
+	log.Printf("🗑️ Rôle %s supprimé", role.Name)
+	return nil
+}
+
+func (s *rbacService) ListUserRoles(userID string) ([]models.Role, error) {
+	if _, err := s.findUser(userID); err != nil {
+		return nil, err
+	}
+	return s.roleRepo.ListUserRoles(userID)
+}
+
+func (s *rbacService) AssignRole(userID string, roleName string) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+	role, err := s.findRole(roleName)
+	if err != nil {
+		return err
+	}
+
+	if err := s.roleRepo.AssignRole(user.ID, role.ID); err != nil {
+		return err
+	}
+	// Le rôle figurera dans les tokens émis à la prochaine connexion ou au prochain rafraîchissement
+	log.Printf("✅ Rôle %s attribué à l'utilisateur %s", role.Name, user.ID)
+	return nil
+}
+
+func (s *rbacService) UnassignRole(userID string, roleName string) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+	role, err := s.findRole(roleName)
+	if err != nil {
+		return err
+	}
+
+	holders, err := s.roleRepo.ListRoleUserIDs(role.ID)
+	if err != nil {
+		return err
+	}
+	if !containsString(holders, user.ID) {
+		return nil
+	}
+	if role.Name == models.RoleAdmin && len(holders) == 1 {
+		return ErrLastAdmin
+	}
+
+	if err := s.roleRepo.UnassignRole(user.ID, role.ID); err != nil {
+		return err
+	}
+	if err := s.signOut([]string{user.ID}); err != nil {
+		return err
+	}
+
+	log.Printf("✅ Rôle %s retiré à l'utilisateur %s", role.Name, user.ID)
+	return nil
+}
+
+func (s *rbacService) SetUserRoles(userID string, roleNames []string) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+
+	// Tous les rôles demandés doivent exister avant de modifier quoi que ce soit
+	wanted := make(map[string]*models.Role, len(roleNames))
+	for _, name := range roleNames {
+		role, err := s.findRole(name)
+		if err != nil {
+			return err
+		}
+		wanted[role.ID] = role
+	}
+
+	current, err := s.roleRepo.ListUserRoles(user.ID)
+	if err != nil {
+		return err
+	}
+	var removed []models.Role
+	for _, role := range current {
+		if _, kept := wanted[role.ID]; kept {
+			delete(wanted, role.ID)
+			continue
+		}
+		removed = append(removed, role)
+		if role.Name == models.RoleAdmin {
+			if err := s.RequireOtherAdmin(user.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, role := range wanted {
+		if err := s.roleRepo.AssignRole(user.ID, role.ID); err != nil {
+			return err
+		}
+	}
+	for _, role := range removed {
+		if err := s.roleRepo.UnassignRole(user.ID, role.ID); err != nil {
+			return err
+		}
+	}
+	if len(removed) > 0 {
+		if err := s.signOut([]string{user.ID}); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("✅ Rôles de l'utilisateur %s remplacés par %v", user.ID, roleNames)
+	return nil
+}
+
+func (s *rbacService) RequireOtherAdmin(userID string) error {
+	admin, err := s.findRole(models.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	holders, err := s.roleRepo.ListRoleUserIDs(admin.ID)
+	if err != nil {
+		return err
+	}
+	if len(holders) == 1 && holders[0] == userID {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+func (s *rbacService) UserAuthorizations(userID string) ([]string, []string, error) {
+	userRoles, err := s.roleRepo.ListUserRoles(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return authorizations(userRoles)
+}
+
+func (s *rbacService) RoleAuthorizations(roleNames []string) ([]string, []string, error) {
+	known, err := s.roleRepo.ListRoles()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var granted []models.Role
+	for _, role := range known {
+		if containsString(roleNames, role.Name) {
+			granted = append(granted, role)
+		}
+	}
+	return authorizations(granted)
+}
+
+// authorizations retourne les noms des rôles et les permissions qu'ils accordent, triées
+func authorizations(userRoles []models.Role) ([]string, []string, error) {
+	roles := make([]string, 0, len(userRoles))
+	granted := make(map[string]struct{})
+	for _, role := range userRoles {
+		roles = append(roles, role.Name)
+		for _, permission := range role.Permissions {
+			granted[permission] = struct{}{}
+		}
+	}
+
+	permissions := make([]string, 0, len(granted))
+	for permission := range granted {
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(permissions)
+	return roles, permissions, nil
+}
+
+func (s *rbacService) BootstrapAdmin(email string) (*models.User, error) {
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	admin, err := s.findRole(models.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	holders, err := s.roleRepo.ListRoleUserIDs(admin.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(holders) > 0 {
+		return nil, ErrAdminAlreadyExists
+	}
+
+	if err := s.roleRepo.AssignRole(user.ID, admin.ID); err != nil {
+		return nil, err
+	}
+	log.Printf("👑 Utilisateur %s nommé premier administrateur", user.ID)
+	return user, nil
+}
+
+// validatePermissions vérifie que les permissions sont connues et les retourne triées, sans doublon
+func (s *rbacService) validatePermissions(requested []string) ([]string, error) {
+	known, err := s.roleRepo.ListPermissions()
+	if err != nil {
+		return nil, err
+	}
+	catalog := make(map[string]struct{}, len(known))
+	for _, permission := range known {
+		catalog[permission.Name] = struct{}{}
+	}
+
+	unique := make(map[string]struct{}, len(requested))
+	permissions := make([]string, 0, len(requested))
+	for _, permission := range requested {
+		if _, ok := catalog[permission]; !ok {
+			return nil, ErrUnknownPermission
+		}
+		if _, seen := unique[permission]; !seen {
+			unique[permission] = struct{}{}
+			permissions = append(permissions, permission)
+		}
+	}
+	sort.Strings(permissions)
+	return permissions, nil
+}
+
+func (s *rbacService) findUser(userID string) (*models.User, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *rbacService) findRole(name string) (*models.Role, error) {
+	role, err := s.roleRepo.FindRoleByName(name)
+	if errors.Is(err, repositories.ErrRoleNotFound) {
+		return nil, ErrRoleNotFound
+	}
+	return role, err
+}
+
+// findMutableRole refuse de modifier le rôle admin, dont les permissions sont fixées au démarrage
+func (s *rbacService) findMutableRole(name string) (*models.Role, error) {
+	role, err := s.findRole(name)
+	if err != nil {
+		return nil, err
+	}
+	if role.Name == models.RoleAdmin {
+		return nil, ErrProtectedRole
+	}
+	return role, nil
+}
+
+// signOut révoque les sessions des utilisateurs dont les droits ont été réduits : les tokens
+// d'accès en cours portent encore leurs anciennes permissions
+func (s *rbacService) signOut(userIDs []string) error {
+	for _, userID := range userIDs {
+		if _, err := s.sessionService.RevokeAllSessions(userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
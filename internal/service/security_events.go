@@ -0,0 +1,92 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/repositories"
+	"github.com/amirtalbi/examen_go/internal/mail"
+)
+
+// Types d'événements de sécurité
+const (
+	EventRefreshTokenReuse    = "refresh_token_reuse"
+	EventWebAuthnSignCountBad = "webauthn_sign_count_regression"
+	EventPasswordReset        = "password_reset"
+	EventPasswordChanged      = "password_changed"
+	EventAccountLocked        = "account_locked"
+)
+
+// SecurityEvent décrit un incident de sécurité lié à un compte
+type SecurityEvent struct {
+	Type       string
+	UserID     string
+	Details    map[string]string
+	OccurredAt time.Time
+}
+
+// SecurityEventSink reçoit les événements de sécurité émis par les services
+type SecurityEventSink interface {
+	Emit(event SecurityEvent)
+}
+
+type logSecurityEventSink struct{}
+
+// NewLogSecurityEventSink retourne un sink qui journalise les événements
+func NewLogSecurityEventSink() SecurityEventSink {
+	return &logSecurityEventSink{}
+}
+
+func (s *logSecurityEventSink) Emit(event SecurityEvent) {
+	log.Printf("🚨 ÉVÉNEMENT DE SÉCURITÉ [%s] utilisateur=%s détails=%v à %s",
+		event.Type, event.UserID, event.Details, event.OccurredAt.Format(time.RFC3339))
+}
+
+// securityNoticeSummaries est le texte présenté à l'utilisateur pour chaque type d'événement
+var securityNoticeSummaries = map[string]string{
+	EventRefreshTokenReuse:    "Une session de votre compte a été fermée : un ancien jeton de connexion a été réutilisé, ce qui peut indiquer un vol.",
+	EventWebAuthnSignCountBad: "Une connexion avec l'une de vos clés d'accès a été refusée : la clé semble avoir été copiée.",
+	EventPasswordReset:        "Le mot de passe de votre compte vient d'être réinitialisé.",
+	EventPasswordChanged:      "Le mot de passe de votre compte vient d'être modifié et vos autres appareils ont été déconnectés.",
+}
+
+type mailSecurityEventSink struct {
+	next     SecurityEventSink
+	userRepo repositories.UserRepository
+	mailer   mail.Mailer
+}
+
+// NewMailSecurityEventSink transmet les événements au sink suivant puis prévient
+// l'utilisateur concerné par email
+func NewMailSecurityEventSink(next SecurityEventSink, userRepo repositories.UserRepository, mailer mail.Mailer) SecurityEventSink {
+	return &mailSecurityEventSink{
+		next:     next,
+		userRepo: userRepo,
+		mailer:   mailer,
+	}
+}
+
+func (s *mailSecurityEventSink) Emit(event SecurityEvent) {
+	s.next.Emit(event)
+
+	summary, ok := securityNoticeSummaries[event.Type]
+	if !ok {
+		return
+	}
+	user, err := s.userRepo.FindByID(event.UserID)
+	if err != nil || user == nil {
+		return
+	}
+
+	message, err := mail.Render(user.Email, mail.TemplateSecurityNotice, mail.SecurityNoticeData{
+		Name:       user.Name,
+		Summary:    summary,
+		OccurredAt: event.OccurredAt,
+	})
+	if err == nil {
+		err = s.mailer.Send(message)
+	}
+	if err != nil {
+		log.Printf("❌ Impossible d'envoyer l'alerte de sécurité à l'utilisateur %s: %v", user.ID, err)
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/domain/repositories"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// Intervalle minimal entre deux mises à jour de last_seen_at d'une session
+const sessionTouchInterval = 5 * time.Minute
+
+type SessionService interface {
+	ListSessions(userID string, currentSessionID string) ([]models.Session, error)
+	RevokeSession(userID string, sessionID string) error
+	// RevokeAllSessions déconnecte l'utilisateur de tous ses appareils
+	RevokeAllSessions(userID string) (int, error)
+	// RevokeOrganizationSessions déconnecte l'utilisateur des sessions ouvertes dans l'organisation
+	RevokeOrganizationSessions(userID string, orgID string) (int, error)
+}
+
+type sessionService struct {
+	sessionRepo      repositories.SessionRepository
+	refreshTokenRepo repositories.RefreshTokenRepository
+}
+
+func NewSessionService(sessionRepo repositories.SessionRepository, refreshTokenRepo repositories.RefreshTokenRepository) SessionService {
+	return &sessionService{
+		sessionRepo:      sessionRepo,
+		refreshTokenRepo: refreshTokenRepo,
+	}
+}
+
+func (s *sessionService) ListSessions(userID string, currentSessionID string) ([]models.Session, error) {
+	sessions, err := s.sessionRepo.ListActiveByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+func (s *sessionService) RevokeSession(userID string, sessionID string) error {
+	session, err := s.sessionRepo.FindByID(sessionID)
+	// Ne pas révéler l'existence des sessions des autres utilisateurs
+	if err != nil || session.UserID != userID || session.RevokedAt != nil {
+		return ErrSessionNotFound
+	}
+
+	return revokeSession(s.sessionRepo, s.refreshTokenRepo, sessionID)
+}
+
+func (s *sessionService) RevokeAllSessions(userID string) (int, error) {
+	revoked, err := s.sessionRepo.RevokeAllForUser(userID, "")
+	if err != nil {
+		return 0, err
+	}
+
+	for _, sessionID := range revoked {
+		if err := s.refreshTokenRepo.RevokeFamily(sessionID); err != nil {
+			return 0, err
+		}
+	}
+	return len(revoked), nil
+}
+
+func (s *sessionService) RevokeOrganizationSessions(userID string, orgID string) (int, error) {
+	sessions, err := s.sessionRepo.ListActiveByUser(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	revoked := 0
+	for _, session := range sessions {
+		if session.OrgID != orgID {
+			continue
+		}
+		if err := revokeSession(s.sessionRepo, s.refreshTokenRepo, session.ID); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// revokeSession révoque une session et la famille de refresh tokens associée.
+// Les tokens d'accès de la session sont refusés dès que la session est révoquée.
+func revokeSession(sessionRepo repositories.SessionRepository, refreshTokenRepo repositories.RefreshTokenRepository, sessionID string) error {
+	if err := sessionRepo.Revoke(sessionID); err != nil && err != repositories.ErrSessionNotFound {
+		return err
+	}
+	return refreshTokenRepo.RevokeFamily(sessionID)
+}
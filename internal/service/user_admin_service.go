@@ -0,0 +1,407 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/domain/repositories"
+)
+
+var (
+	// ErrAccountLocked signale un compte verrouillé par un administrateur, jusqu'à son déverrouillage
+	ErrAccountLocked = errors.New("account locked")
+	// ErrPasswordResetRequired est retourné après un mot de passe correct quand un administrateur
+	// a exigé sa réinitialisation
+	ErrPasswordResetRequired = errors.New("password reset required")
+	ErrInvalidCursor         = errors.New("invalid cursor")
+	// ErrCannotModifySelf empêche un administrateur de verrouiller ou supprimer son propre compte
+	ErrCannotModifySelf = errors.New("cannot lock or delete your own account")
+)
+
+const (
+	defaultUserPageSize = 20
+	maxUserPageSize     = 100
+)
+
+type UserAdminService interface {
+	ListUsers(query models.ListUsersQuery) (*models.UserPage, error)
+	GetUser(userID string) (*models.AdminUser, error)
+	// Modifier le nom, l'email et les rôles. Un nouvel email doit être vérifié à nouveau.
+	UpdateUser(userID string, request models.UpdateUserRequest) (*models.AdminUser, error)
+	// Refuser l'ancien mot de passe, déconnecter l'utilisateur et lui envoyer un lien de réinitialisation
+	ForcePasswordReset(userID string) error
+	// Verrouiller le compte jusqu'à son déverrouillage et déconnecter l'utilisateur
+	LockUser(adminID string, userID string) error
+	// Lever le verrouillage administrateur et le blocage après des connexions échouées
+	UnlockUser(userID string) error
+	DeleteUser(adminID string, userID string) error
+	// Le même service limité aux membres de l'organisation, dont il gère les rôles. Les comptes
+	// que l'organisation ne possède pas ne peuvent qu'en être retirés (ErrForeignAccount).
+	InOrganization(orgID string) UserAdminService
+}
+
+type userAdminService struct {
+	userRepo       repositories.UserRepository
+	rbac           RBACService
+	organizations  OrganizationService
+	sessionService SessionService
+	lockout        LockoutService
+	// Les emails de réinitialisation et de vérification sont ceux du parcours utilisateur
+	authService AuthService
+	// Organisation gérée, vide pour toute l'instance
+	orgID string
+}
+
+func NewUserAdminService(
+	userRepo repositories.UserRepository,
+	rbac RBACService,
+	organizations OrganizationService,
+	sessionService SessionService,
+	lockout LockoutService,
+	authService AuthService,
+) UserAdminService {
+	return &userAdminService{
+		userRepo:       userRepo,
+		rbac:           rbac,
+		organizations:  organizations,
+		sessionService: sessionService,
+		lockout:        lockout,
+		authService:    authService,
+	}
+}
+
+func (s *userAdminService) InOrganization(orgID string) UserAdminService {
+	if orgID == "" {
+		return s
+	}
+	scoped := *s
+	scoped.userRepo = s.organizations.Members(orgID)
+	scoped.orgID = orgID
+	return &scoped
+}
+
+func (s *userAdminService) ListUsers(query models.ListUsersQuery) (*models.UserPage, error) {
+	return listUserPage(s.userRepo, query, s.rolesOf)
+}
+
+// listUserPage retourne une page d'utilisateurs avec leurs rôles, donnés par rolesOf
+func listUserPage(userRepo repositories.UserRepository, query models.ListUsersQuery, rolesOf func(user *models.User) ([]string, error)) (*models.UserPage, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultUserPageSize
+	}
+	if limit > maxUserPageSize {
+		limit = maxUserPageSize
+	}
+
+	filter := models.UserFilter{
+		EmailPrefix:   query.EmailPrefix,
+		CreatedAfter:  query.CreatedAfter,
+		CreatedBefore: query.CreatedBefore,
+		Verified:      query.Verified,
+		Locked:        query.Locked,
+		// Un utilisateur de plus indique s'il reste une page
+		Limit: limit + 1,
+	}
+	if query.Cursor != "" {
+		cursor, err := decodeUserCursor(query.Cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		filter.After = cursor
+	}
+
+	users, err := userRepo.List(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.UserPage{Users: make([]models.AdminUser, 0, limit)}
+	if len(users) > limit {
+		users = users[:limit]
+		page.NextCursor = encodeUserCursor(users[limit-1])
+	}
+	for i := range users {
+		roles, err := rolesOf(&users[i])
+		if err != nil {
+			return nil, err
+		}
+		page.Users = append(page.Users, models.AdminUser{User: users[i], Roles: roles})
+	}
+	return page, nil
+}
+
+func (s *userAdminService) GetUser(userID string) (*models.AdminUser, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.withRoles(user)
+}
+
+func (s *userAdminService) UpdateUser(userID string, request models.UpdateUserRequest) (*models.AdminUser, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Name != nil || request.Email != nil {
+		if err := s.requireOwned(user); err != nil {
+			return nil, err
+		}
+	}
+
+	updated := *user
+	if request.Name != nil {
+		updated.Name = *request.Name
+	}
+	emailChanged := request.Email != nil && *request.Email != user.Email
+	if emailChanged {
+		if existing, err := s.userRepo.FindByEmail(*request.Email); err == nil && existing != nil {
+			return nil, ErrUserAlreadyExists
+		}
+		updated.Email = *request.Email
+		updated.EmailVerifiedAt = nil
+	}
+
+	// Les rôles sont validés avant tout enregistrement, mais attribués seulement une fois le
+	// profil enregistré : un rôle inconnu ou un email déjà pris ne laisse pas une
+	// modification à moitié appliquée
+	if request.Roles != nil {
+		if err := s.validateRoles(user.ID, request.Roles); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.userRepo.Update(&updated); err != nil {
+		if errors.Is(err, repositories.ErrEmailAlreadyExists) {
+			return nil, ErrUserAlreadyExists
+		}
+		return nil, err
+	}
+
+	if request.Roles != nil {
+		if err := s.setRoles(user.ID, request.Roles); err != nil {
+			return nil, err
+		}
+	}
+
+	if emailChanged {
+		if err := s.authService.ResendVerificationEmail(updated.Email, organizationOf(&updated)); err != nil {
+			log.Printf("❌ Erreur lors de l'envoi de l'email de vérification à l'utilisateur %s: %v", user.ID, err)
+		}
+	}
+
+	log.Printf("✅ Utilisateur %s modifié par un administrateur", user.ID)
+	return s.GetUser(user.ID)
+}
+
+func (s *userAdminService) ForcePasswordReset(userID string) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.requireOwned(user); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.SetMustResetPassword(user.ID, true); err != nil {
+		return err
+	}
+	if _, err := s.sessionService.RevokeAllSessions(user.ID); err != nil {
+		return err
+	}
+	if err := s.authService.ForgotPassword(user.Email, organizationOf(user)); err != nil {
+		return err
+	}
+
+	log.Printf("🔑 Réinitialisation du mot de passe exigée pour l'utilisateur %s", user.ID)
+	return nil
+}
+
+func (s *userAdminService) LockUser(adminID string, userID string) error {
+	if adminID == userID {
+		return ErrCannotModifySelf
+	}
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.requireOwned(user); err != nil {
+		return err
+	}
+	if err := s.rbac.RequireOtherAdmin(user.ID); err != nil {
+		return err
+	}
+
+	if user.LockedAt == nil {
+		now := time.Now()
+		if err := s.userRepo.SetLocked(user.ID, &now); err != nil {
+			return err
+		}
+	}
+	if _, err := s.sessionService.RevokeAllSessions(user.ID); err != nil {
+		return err
+	}
+
+	log.Printf("🔒 Compte de l'utilisateur %s verrouillé par l'administrateur %s", user.ID, adminID)
+	return nil
+}
+
+func (s *userAdminService) UnlockUser(userID string) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.requireOwned(user); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.SetLocked(user.ID, nil); err != nil {
+		return err
+	}
+	return s.lockout.Unlock(user.ID)
+}
+
+func (s *userAdminService) DeleteUser(adminID string, userID string) error {
+	if adminID == userID {
+		return ErrCannotModifySelf
+	}
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+	// Un compte partagé avec l'instance quitte seulement l'organisation
+	if s.requireOwned(user) != nil {
+		return s.organizations.RemoveMember(s.orgID, user.ID)
+	}
+	if err := s.rbac.RequireOtherAdmin(user.ID); err != nil {
+		return err
+	}
+
+	// Les tokens déjà émis ne doivent pas survivre au compte
+	if _, err := s.sessionService.RevokeAllSessions(user.ID); err != nil {
+		return err
+	}
+	if err := s.userRepo.Delete(user.ID); err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+
+	log.Printf("🗑️ Utilisateur %s supprimé par l'administrateur %s", user.ID, adminID)
+	return nil
+}
+
+func (s *userAdminService) findUser(userID string) (*models.User, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (s *userAdminService) withRoles(user *models.User) (*models.AdminUser, error) {
+	roles, err := s.rolesOf(user)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AdminUser{User: *user, Roles: roles}, nil
+}
+
+// rolesOf retourne les rôles de l'utilisateur sur l'instance, ou dans l'organisation gérée
+func (s *userAdminService) rolesOf(user *models.User) ([]string, error) {
+	if s.orgID == "" {
+		roles, _, err := s.rbac.UserAuthorizations(user.ID)
+		return roles, err
+	}
+
+	membership, err := s.userRepo.FindMembership(s.orgID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return membership.Roles, nil
+}
+
+// validateRoles vérifie que setRoles peut remplacer les rôles de l'utilisateur : les rôles
+// existent, et le dernier administrateur de l'instance garde le sien
+func (s *userAdminService) validateRoles(userID string, roleNames []string) error {
+	known, err := s.rbac.ListRoles()
+	if err != nil {
+		return err
+	}
+	catalog := make(map[string]struct{}, len(known))
+	for _, role := range known {
+		catalog[role.Name] = struct{}{}
+	}
+	for _, name := range roleNames {
+		if _, ok := catalog[name]; !ok {
+			return ErrRoleNotFound
+		}
+	}
+
+	if s.orgID != "" || containsString(roleNames, models.RoleAdmin) {
+		return nil
+	}
+	current, err := s.rbac.ListUserRoles(userID)
+	if err != nil {
+		return err
+	}
+	for _, role := range current {
+		if role.Name == models.RoleAdmin {
+			return s.rbac.RequireOtherAdmin(userID)
+		}
+	}
+	return nil
+}
+
+func (s *userAdminService) setRoles(userID string, roleNames []string) error {
+	if s.orgID == "" {
+		return s.rbac.SetUserRoles(userID, roleNames)
+	}
+	_, err := s.organizations.UpdateMemberRoles(s.orgID, userID, models.UpdateMemberRolesRequest{Roles: roleNames})
+	return err
+}
+
+// requireOwned retourne ErrForeignAccount si l'organisation gérée ne possède pas le compte
+func (s *userAdminService) requireOwned(user *models.User) error {
+	if s.orgID != "" && (user.OrgID == nil || *user.OrgID != s.orgID) {
+		return ErrForeignAccount
+	}
+	return nil
+}
+
+// organizationOf retourne l'organisation propriétaire du compte, vide pour un compte de l'instance
+func organizationOf(user *models.User) string {
+	if user.OrgID == nil {
+		return ""
+	}
+	return *user.OrgID
+}
+
+// Le curseur est opaque pour le client : date de création et identifiant du dernier
+// utilisateur de la page. La date garde son fuseau d'origine, pour être comparée à
+// l'identique en base.
+func encodeUserCursor(user models.User) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(user.CreatedAt.Format(time.RFC3339Nano) + "|" + user.ID))
+}
+
+func decodeUserCursor(cursor string) (*models.UserCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	createdAt, id, found := strings.Cut(string(raw), "|")
+	if !found || id == "" {
+		return nil, ErrInvalidCursor
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	return &models.UserCursor{CreatedAt: parsed, ID: id}, nil
+}
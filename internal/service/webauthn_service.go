@@ -0,0 +1,339 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/amirtalbi/examen_go/internal/config"
+	"github.com/amirtalbi/examen_go/internal/domain/models"
+	"github.com/amirtalbi/examen_go/internal/domain/repositories"
+	"github.com/amirtalbi/examen_go/pkg/auth"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// defaultCredentialName est utilisé lorsque l'utilisateur ne nomme pas sa clé d'accès
+const defaultCredentialName = "Passkey"
+
+var (
+	// ErrWebAuthnVerificationFailed regroupe les échecs de vérification, sans en révéler la cause
+	ErrWebAuthnVerificationFailed = errors.New("webauthn verification failed")
+	ErrWebAuthnCredentialExists   = errors.New("webauthn credential already registered")
+	ErrWebAuthnCredentialNotFound = errors.New("webauthn credential not found")
+)
+
+type WebAuthnService interface {
+	// Émettre les options de navigator.credentials.create() pour un utilisateur connecté
+	BeginRegistration(userID string) (*models.WebAuthnRegistrationOptionsResponse, error)
+	FinishRegistration(userID string, request models.WebAuthnRegisterRequest) (*models.WebAuthnCredential, error)
+	ListCredentials(userID string) ([]models.WebAuthnCredential, error)
+	DeleteCredential(userID string, credentialID string) error
+	// Émettre les options de navigator.credentials.get() pour une connexion sans mot de passe
+	BeginLogin() (*models.WebAuthnLoginOptionsResponse, error)
+	FinishLogin(request models.WebAuthnLoginRequest) (*models.AuthResponse, error)
+}
+
+type webauthnService struct {
+	credentialRepo repositories.WebAuthnCredentialRepository
+	userRepo       repositories.UserRepository
+	// Les tokens de cérémonie sont à usage unique : leur jti est révoqué dès la première réponse
+	revocations  repositories.RevocationStore
+	authService  AuthService
+	tokens       *auth.TokenConfig
+	events       SecurityEventSink
+	relyingParty *auth.WebAuthnConfig
+}
+
+func NewWebAuthnService(
+	credentialRepo repositories.WebAuthnCredentialRepository,
+	userRepo repositories.UserRepository,
+	revocations repositories.RevocationStore,
+	authService AuthService,
+	tokens *auth.TokenConfig,
+	events SecurityEventSink,
+	config *config.Config,
+) WebAuthnService {
+	return &webauthnService{
+		credentialRepo: credentialRepo,
+		userRepo:       userRepo,
+		revocations:    revocations,
+		authService:    authService,
+		tokens:         tokens,
+		events:         events,
+		relyingParty: &auth.WebAuthnConfig{
+			RPID:    config.WebAuthnRPID,
+			RPName:  config.WebAuthnRPName,
+			Origins: config.WebAuthnOrigins,
+		},
+	}
+}
+
+func (s *webauthnService) BeginRegistration(userID string) (*models.WebAuthnRegistrationOptionsResponse, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	existing, err := s.credentialRepo.ListByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	// Un authentificateur déjà enregistré refuse de créer un second credential pour le compte
+	exclude := make([]models.PublicKeyCredentialDescriptor, 0, len(existing))
+	for _, credential := range existing {
+		exclude = append(exclude, models.PublicKeyCredentialDescriptor{
+			Type:       "public-key",
+			ID:         credential.ID,
+			Transports: credential.Transports,
+		})
+	}
+
+	challenge, err := auth.NewWebAuthnChallenge()
+	if err != nil {
+		return nil, err
+	}
+	ceremonyToken, err := auth.GenerateWebAuthnToken(auth.WebAuthnCeremonyRegistration, userID, challenge, s.tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := make([]models.PublicKeyCredentialParameters, 0, len(auth.SupportedCOSEAlgorithms))
+	for _, alg := range auth.SupportedCOSEAlgorithms {
+		parameters = append(parameters, models.PublicKeyCredentialParameters{Type: "public-key", Alg: alg})
+	}
+
+	return &models.WebAuthnRegistrationOptionsResponse{
+		CeremonyToken: ceremonyToken,
+		PublicKey: models.PublicKeyCredentialCreationOptions{
+			Challenge: challenge,
+			RP:        models.PublicKeyCredentialRPEntity{ID: s.relyingParty.RPID, Name: s.relyingParty.RPName},
+			// L'identifiant utilisateur est renvoyé comme userHandle lors des connexions
+			User: models.PublicKeyCredentialUserEntity{
+				ID:          base64.RawURLEncoding.EncodeToString([]byte(user.ID)),
+				Name:        user.Email,
+				DisplayName: user.Name,
+			},
+			PubKeyCredParams:   parameters,
+			Timeout:            auth.WebAuthnCeremonyLifetime.Milliseconds(),
+			ExcludeCredentials: exclude,
+			// Credential découvrable et vérification de l'utilisateur : la clé remplace le mot de passe
+			AuthenticatorSelection: models.AuthenticatorSelectionCriteria{
+				ResidentKey:        "required",
+				RequireResidentKey: true,
+				UserVerification:   "required",
+			},
+			Attestation: "none",
+		},
+	}, nil
+}
+
+func (s *webauthnService) FinishRegistration(userID string, request models.WebAuthnRegisterRequest) (*models.WebAuthnCredential, error) {
+	claims, err := s.consumeCeremony(request.CeremonyToken, auth.WebAuthnCeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if claims.UserID != userID {
+		return nil, ErrInvalidToken
+	}
+
+	if request.Credential.Type != "public-key" {
+		return nil, ErrWebAuthnVerificationFailed
+	}
+	clientDataJSON, err := decodeBase64URL(request.Credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, ErrWebAuthnVerificationFailed
+	}
+	attestationObject, err := decodeBase64URL(request.Credential.Response.AttestationObject)
+	if err != nil {
+		return nil, ErrWebAuthnVerificationFailed
+	}
+
+	registered, err := s.relyingParty.VerifyRegistration(claims.Challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		log.Printf("❌ Enregistrement WebAuthn refusé pour l'utilisateur %s: %v", userID, err)
+		return nil, ErrWebAuthnVerificationFailed
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(registered.ID)
+	if credentialID != strings.TrimRight(request.Credential.ID, "=") {
+		return nil, ErrWebAuthnVerificationFailed
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		name = defaultCredentialName
+	}
+	transports := pq.StringArray{}
+	transports = append(transports, request.Credential.Response.Transports...)
+
+	credential := &models.WebAuthnCredential{
+		ID:                credentialID,
+		UserID:            userID,
+		Name:              name,
+		PublicKey:         registered.PublicKey,
+		Algorithm:         registered.Algorithm,
+		SignCount:         int64(registered.SignCount),
+		AAGUID:            formatAAGUID(registered.AAGUID),
+		Transports:        transports,
+		AttestationFormat: registered.AttestationFormat,
+		BackupEligible:    registered.BackupEligible,
+		BackupState:       registered.BackupState,
+	}
+
+	if err := s.credentialRepo.Create(credential); err != nil {
+		if errors.Is(err, repositories.ErrWebAuthnCredentialExists) {
+			return nil, ErrWebAuthnCredentialExists
+		}
+		return nil, err
+	}
+
+	log.Printf("🔑 Clé d'accès enregistrée pour l'utilisateur %s (attestation %s)", userID, credential.AttestationFormat)
+	return credential, nil
+}
+
+func (s *webauthnService) ListCredentials(userID string) ([]models.WebAuthnCredential, error) {
+	return s.credentialRepo.ListByUser(userID)
+}
+
+func (s *webauthnService) DeleteCredential(userID string, credentialID string) error {
+	if err := s.credentialRepo.Delete(userID, credentialID); err != nil {
+		if errors.Is(err, repositories.ErrWebAuthnCredentialNotFound) {
+			return ErrWebAuthnCredentialNotFound
+		}
+		return err
+	}
+
+	log.Printf("🗑️ Clé d'accès supprimée pour l'utilisateur %s", userID)
+	return nil
+}
+
+func (s *webauthnService) BeginLogin() (*models.WebAuthnLoginOptionsResponse, error) {
+	challenge, err := auth.NewWebAuthnChallenge()
+	if err != nil {
+		return nil, err
+	}
+	ceremonyToken, err := auth.GenerateWebAuthnToken(auth.WebAuthnCeremonyAuthentication, "", challenge, s.tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	// Aucune liste de credentials : l'authentificateur propose ses clés découvrables,
+	// ce qui évite de révéler quels comptes existent
+	return &models.WebAuthnLoginOptionsResponse{
+		CeremonyToken: ceremonyToken,
+		PublicKey: models.PublicKeyCredentialRequestOptions{
+			Challenge:        challenge,
+			Timeout:          auth.WebAuthnCeremonyLifetime.Milliseconds(),
+			RPID:             s.relyingParty.RPID,
+			AllowCredentials: []models.PublicKeyCredentialDescriptor{},
+			UserVerification: "required",
+		},
+	}, nil
+}
+
+// FinishLogin ouvre une session comme Login. Le second facteur TOTP n'est pas demandé :
+// une clé d'accès vérifiée (possession et PIN ou biométrie) est déjà multifacteur.
+func (s *webauthnService) FinishLogin(request models.WebAuthnLoginRequest) (*models.AuthResponse, error) {
+	claims, err := s.consumeCeremony(request.CeremonyToken, auth.WebAuthnCeremonyAuthentication)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Credential.Type != "public-key" {
+		return nil, ErrWebAuthnVerificationFailed
+	}
+	credential, err := s.credentialRepo.FindByID(strings.TrimRight(request.Credential.ID, "="))
+	if errors.Is(err, repositories.ErrWebAuthnCredentialNotFound) {
+		return nil, ErrWebAuthnVerificationFailed
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	response := request.Credential.Response
+	userHandle, err := decodeBase64URL(response.UserHandle)
+	if err != nil || string(userHandle) != credential.UserID {
+		return nil, ErrWebAuthnVerificationFailed
+	}
+	clientDataJSON, err := decodeBase64URL(response.ClientDataJSON)
+	if err != nil {
+		return nil, ErrWebAuthnVerificationFailed
+	}
+	authenticatorData, err := decodeBase64URL(response.AuthenticatorData)
+	if err != nil {
+		return nil, ErrWebAuthnVerificationFailed
+	}
+	signature, err := decodeBase64URL(response.Signature)
+	if err != nil {
+		return nil, ErrWebAuthnVerificationFailed
+	}
+
+	result, err := s.relyingParty.VerifyAssertion(claims.Challenge, credential.PublicKey, clientDataJSON, authenticatorData, signature)
+	if err != nil {
+		log.Printf("❌ Connexion WebAuthn refusée pour l'utilisateur %s: %v", credential.UserID, err)
+		return nil, ErrWebAuthnVerificationFailed
+	}
+
+	signCount := int64(result.SignCount)
+	if auth.SignCountRegressed(uint32(credential.SignCount), result.SignCount) {
+		s.events.Emit(SecurityEvent{
+			Type:   EventWebAuthnSignCountBad,
+			UserID: credential.UserID,
+			Details: map[string]string{
+				"credential_id": credential.ID,
+			},
+			OccurredAt: time.Now(),
+		})
+		return nil, ErrWebAuthnVerificationFailed
+	}
+
+	if err := s.credentialRepo.UpdateUsage(credential.ID, signCount, result.BackupState); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(credential.UserID)
+	if err != nil || user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	log.Printf("🔑 Connexion par clé d'accès pour l'utilisateur %s", user.ID)
+	return s.authService.StartSession(user, request.ClientInfo)
+}
+
+// consumeCeremony valide le token de cérémonie et le révoque : une réponse d'authentificateur
+// ne peut être présentée qu'une fois par challenge, qu'elle soit valide ou non
+func (s *webauthnService) consumeCeremony(ceremonyToken string, ceremony string) (*auth.WebAuthnClaims, error) {
+	claims, err := auth.ValidateWebAuthnToken(ceremonyToken, ceremony, s.tokens)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := s.revocations.IsRevoked(claims.TokenID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrInvalidToken
+	}
+	if err := s.revocations.Revoke(claims.TokenID, claims.ExpiresAt); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// decodeBase64URL accepte le base64url avec ou sans padding
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+// formatAAGUID présente l'AAGUID sous forme d'UUID, chaîne vide s'il est nul (attestation "none")
+func formatAAGUID(aaguid []byte) string {
+	id, err := uuid.FromBytes(aaguid)
+	if err != nil || id == uuid.Nil {
+		return ""
+	}
+	return id.String()
+}
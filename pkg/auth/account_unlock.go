@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// AccountUnlockLifetime couvre la durée d'un blocage : au-delà, le compte est de toute façon débloqué
+const AccountUnlockLifetime = time.Hour
+
+// AccountUnlockClaims contient les informations portées par un token de déblocage
+type AccountUnlockClaims struct {
+	UserID    string
+	Email     string
+	TokenID   string
+	ExpiresAt time.Time
+}
+
+// GenerateAccountUnlockToken génère le JWT du lien de déblocage envoyé quand un compte est
+// bloqué après trop d'échecs de connexion
+func GenerateAccountUnlockToken(userID string, email string, tokens *TokenConfig) (string, error) {
+	claims := jwt.MapClaims{
+		"uid":   userID,
+		"email": email,
+		"aud":   tokens.Issuer,
+	}
+	return tokens.sign(TypeUnlock, claims, AccountUnlockLifetime)
+}
+
+// ValidateAccountUnlockToken valide un token de déblocage et retourne ses claims.
+// L'usage unique est à la charge de l'appelant (jti).
+func ValidateAccountUnlockToken(tokenString string, tokens *TokenConfig) (*AccountUnlockClaims, error) {
+	claims, err := tokens.parse(tokenString, TypeUnlock, tokens.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, ok := claims["uid"].(string)
+	if !ok || userID == "" {
+		return nil, errors.New("invalid token claims: missing uid")
+	}
+
+	email, ok := claims["email"].(string)
+	if !ok || email == "" {
+		return nil, errors.New("invalid token claims: missing email")
+	}
+
+	tokenID, ok := claims["jti"].(string)
+	if !ok || tokenID == "" {
+		return nil, errors.New("invalid token claims: missing jti")
+	}
+
+	exp, _ := claims["exp"].(float64)
+
+	return &AccountUnlockClaims{
+		UserID:    userID,
+		Email:     email,
+		TokenID:   tokenID,
+		ExpiresAt: time.Unix(int64(exp), 0),
+	}, nil
+}
@@ -0,0 +1,240 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Les fichiers Pwned Passwords (haveibeenpwned.com) listent une empreinte SHA-1 par ligne,
+// "<40 caractères hexadécimaux>:<nombre d'occurrences>", triées par empreinte. L'index
+// binaire en est une copie compacte à enregistrements de taille fixe :
+//
+//	"HIBPIDX1" puis, pour chaque empreinte, 20 octets de SHA-1 et un compteur uint32 big-endian
+const (
+	hibpIndexMagic      = "HIBPIDX1"
+	hibpIndexRecordSize = sha1.Size + 4
+	hibpHashLength      = sha1.Size * 2
+	// hibpMaxLineLength couvre une empreinte, un compteur et la fin de ligne
+	hibpMaxLineLength = 128
+)
+
+var ErrUnsortedBreachCorpus = errors.New("breach corpus is not sorted by hash")
+
+// BreachedPasswordChecker indique combien de fois un mot de passe apparaît dans des fuites connues
+type BreachedPasswordChecker interface {
+	Occurrences(password string) (int, error)
+	Close() error
+}
+
+// OpenBreachedPasswords ouvre un index construit par BuildBreachedPasswordIndex ou,
+// à défaut, le fichier texte trié tel que téléchargé. Les recherches sont dichotomiques,
+// sans charger le fichier en mémoire.
+func OpenBreachedPasswords(path string) (BreachedPasswordChecker, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	magic := make([]byte, len(hibpIndexMagic))
+	if _, err := file.ReadAt(magic, 0); err == nil && string(magic) == hibpIndexMagic {
+		records := (info.Size() - int64(len(hibpIndexMagic))) / hibpIndexRecordSize
+		return &breachIndex{file: file, records: records}, nil
+	}
+
+	return &breachTextFile{file: file, size: info.Size()}, nil
+}
+
+// breachIndex recherche dans l'index binaire, par numéro d'enregistrement
+type breachIndex struct {
+	file    *os.File
+	records int64
+}
+
+func (b *breachIndex) Occurrences(password string) (int, error) {
+	target := sha1.Sum([]byte(password))
+	record := make([]byte, hibpIndexRecordSize)
+
+	lo, hi := int64(0), b.records
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if _, err := b.file.ReadAt(record, int64(len(hibpIndexMagic))+mid*hibpIndexRecordSize); err != nil {
+			return 0, err
+		}
+
+		switch bytes.Compare(record[:sha1.Size], target[:]) {
+		case 0:
+			return int(binary.BigEndian.Uint32(record[sha1.Size:])), nil
+		case -1:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return 0, nil
+}
+
+func (b *breachIndex) Close() error {
+	return b.file.Close()
+}
+
+// breachTextFile recherche directement dans le fichier texte : les lignes n'ayant pas
+// toutes la même longueur, chaque sonde se recale sur le début de ligne suivant
+type breachTextFile struct {
+	file *os.File
+	size int64
+}
+
+func (b *breachTextFile) Occurrences(password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	target := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	// La ligne cherchée, si elle existe, commence dans [lo, hi)
+	lo, hi := int64(0), b.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, line, err := b.lineFrom(mid)
+		if err == io.EOF || (err == nil && start >= hi) {
+			hi = mid
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		hash, count, err := parseBreachLine(line)
+		if err != nil {
+			return 0, err
+		}
+
+		switch strings.Compare(hash, target) {
+		case 0:
+			return count, nil
+		case -1:
+			lo = start + int64(len(line))
+		default:
+			// Aucune ligne ne commence entre mid et start
+			hi = mid
+		}
+	}
+	return 0, nil
+}
+
+// lineFrom retourne la première ligne commençant à la position offset ou après,
+// fin de ligne comprise
+func (b *breachTextFile) lineFrom(offset int64) (int64, []byte, error) {
+	start := offset
+	if offset > 0 {
+		// Si offset est un début de ligne, l'octet précédent est un saut de ligne
+		start = offset - 1
+	}
+
+	buffer := make([]byte, 2*hibpMaxLineLength)
+	n, err := b.file.ReadAt(buffer, start)
+	if err != nil && err != io.EOF {
+		return 0, nil, err
+	}
+	buffer = buffer[:n]
+
+	if offset > 0 {
+		newline := bytes.IndexByte(buffer, '\n')
+		if newline < 0 {
+			return 0, nil, io.EOF
+		}
+		start += int64(newline) + 1
+		buffer = buffer[newline+1:]
+	}
+	if len(buffer) == 0 {
+		return 0, nil, io.EOF
+	}
+
+	if end := bytes.IndexByte(buffer, '\n'); end >= 0 {
+		buffer = buffer[:end+1]
+	}
+	return start, buffer, nil
+}
+
+func (b *breachTextFile) Close() error {
+	return b.file.Close()
+}
+
+// parseBreachLine lit une ligne "<SHA-1>:<occurrences>"
+func parseBreachLine(line []byte) (string, int, error) {
+	hash, count, found := strings.Cut(strings.TrimSpace(string(line)), ":")
+	if !found || len(hash) != hibpHashLength {
+		return "", 0, fmt.Errorf("invalid breach corpus line %q", line)
+	}
+
+	occurrences, err := strconv.Atoi(count)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid breach corpus line %q", line)
+	}
+	return strings.ToUpper(hash), occurrences, nil
+}
+
+// BuildBreachedPasswordIndex convertit le fichier texte trié en index binaire, en ne gardant
+// que les empreintes vues au moins minOccurrences fois. Retourne le nombre d'empreintes écrites.
+func BuildBreachedPasswordIndex(source io.Reader, destination io.Writer, minOccurrences int) (int64, error) {
+	writer := bufio.NewWriter(destination)
+	if _, err := writer.WriteString(hibpIndexMagic); err != nil {
+		return 0, err
+	}
+
+	var written int64
+	var previous string
+	record := make([]byte, hibpIndexRecordSize)
+
+	scanner := bufio.NewScanner(source)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		hash, count, err := parseBreachLine(scanner.Bytes())
+		if err != nil {
+			return written, err
+		}
+		// Un fichier trié par nombre d'occurrences ne permet pas la recherche dichotomique
+		if hash <= previous {
+			return written, ErrUnsortedBreachCorpus
+		}
+		previous = hash
+
+		if count < minOccurrences {
+			continue
+		}
+		occurrences := uint32(math.MaxUint32)
+		if uint64(count) < math.MaxUint32 {
+			occurrences = uint32(count)
+		}
+
+		if _, err := hex.Decode(record[:sha1.Size], []byte(hash)); err != nil {
+			return written, fmt.Errorf("invalid breach corpus hash %q", hash)
+		}
+		binary.BigEndian.PutUint32(record[sha1.Size:], occurrences)
+		if _, err := writer.Write(record); err != nil {
+			return written, err
+		}
+		written++
+	}
+	if err := scanner.Err(); err != nil {
+		return written, err
+	}
+
+	return written, writer.Flush()
+}
@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// encodeCBOR encode le sous-ensemble CBOR lu par decodeCBOR, avec des clés de map
+// triées pour produire des octets stables
+func encodeCBOR(value interface{}) []byte {
+	switch v := value.(type) {
+	case int:
+		return encodeCBOR(int64(v))
+	case int64:
+		if v < 0 {
+			return encodeCBORHead(1, uint64(-1-v))
+		}
+		return encodeCBORHead(0, uint64(v))
+	case []byte:
+		return append(encodeCBORHead(2, uint64(len(v))), v...)
+	case string:
+		return append(encodeCBORHead(3, uint64(len(v))), v...)
+	case []interface{}:
+		data := encodeCBORHead(4, uint64(len(v)))
+		for _, item := range v {
+			data = append(data, encodeCBOR(item)...)
+		}
+		return data
+	case map[interface{}]interface{}:
+		entries := make([][2][]byte, 0, len(v))
+		for key, item := range v {
+			entries = append(entries, [2][]byte{encodeCBOR(key), encodeCBOR(item)})
+		}
+		sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i][0], entries[j][0]) < 0 })
+		data := encodeCBORHead(5, uint64(len(v)))
+		for _, entry := range entries {
+			data = append(append(data, entry[0]...), entry[1]...)
+		}
+		return data
+	case bool:
+		if v {
+			return []byte{0xf5}
+		}
+		return []byte{0xf4}
+	case nil:
+		return []byte{0xf6}
+	default:
+		panic("type non supporté par encodeCBOR")
+	}
+}
+
+func encodeCBORHead(major byte, argument uint64) []byte {
+	switch {
+	case argument < 24:
+		return []byte{major<<5 | byte(argument)}
+	case argument <= math.MaxUint8:
+		return []byte{major<<5 | 24, byte(argument)}
+	case argument <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(argument))
+	case argument <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(argument))
+	default:
+		return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, argument)
+	}
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	// Vecteurs de l'annexe A de la RFC 8949
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{input: "00", want: int64(0)},
+		{input: "17", want: int64(23)},
+		{input: "1818", want: int64(24)},
+		{input: "1903e8", want: int64(1000)},
+		{input: "1a000f4240", want: int64(1000000)},
+		{input: "1b000000e8d4a51000", want: int64(1000000000000)},
+		{input: "20", want: int64(-1)},
+		{input: "3863", want: int64(-100)},
+		{input: "390100", want: int64(-257)},
+		{input: "40", want: []byte(nil)},
+		{input: "4401020304", want: []byte{1, 2, 3, 4}},
+		{input: "60", want: ""},
+		{input: "6449455446", want: "IETF"},
+		{input: "62c3bc", want: "ü"},
+		{input: "80", want: []interface{}{}},
+		{input: "83010203", want: []interface{}{int64(1), int64(2), int64(3)}},
+		{input: "8301820203820405", want: []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{input: "a0", want: map[interface{}]interface{}{}},
+		{input: "a201020304", want: map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}},
+		{input: "a26161016162820203", want: map[interface{}]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+		{input: "f4", want: false},
+		{input: "f5", want: true},
+		{input: "f6", want: nil},
+		{input: "fa47c35000", want: float64(100000)},
+		{input: "fb3ff199999999999a", want: 1.1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			data, _ := hex.DecodeString(tt.input)
+			got, rest, err := decodeCBOR(data)
+			if err != nil {
+				t.Fatalf("decodeCBOR: %v", err)
+			}
+			if len(rest) != 0 {
+				t.Errorf("%d octets non consommés", len(rest))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCBOR = %#v, attendu %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORReturnsRest(t *testing.T) {
+	got, rest, err := decodeCBOR([]byte{0x01, 0x02, 0x03})
+	if err != nil {
+		t.Fatalf("decodeCBOR: %v", err)
+	}
+	if got != int64(1) || !bytes.Equal(rest, []byte{0x02, 0x03}) {
+		t.Fatalf("decodeCBOR = %v, reste %x", got, rest)
+	}
+}
+
+func TestDecodeCBORRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "vide", input: ""},
+		{name: "argument tronqué", input: "19e8"},
+		{name: "chaîne tronquée", input: "44010203"},
+		{name: "tableau tronqué", input: "830102"},
+		{name: "map tronquée", input: "a20102"},
+		{name: "longueur indéfinie", input: "5f42010243030405ff"},
+		{name: "tableau de longueur indéfinie", input: "9f0102ff"},
+		{name: "tag", input: "c11a514b67b0"},
+		{name: "clé de map non scalaire", input: "a18001"},
+		{name: "flottant demi-précision", input: "f93c00"},
+		{name: "valeur simple non assignée", input: "f0"},
+		{name: "entier hors int64", input: "1bffffffffffffffff"},
+		{name: "longueur annoncée démesurée", input: "9b00000000ffffffff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := hex.DecodeString(tt.input)
+			if _, _, err := decodeCBOR(data); err != errInvalidCBOR {
+				t.Fatalf("err = %v, attendu %v", err, errInvalidCBOR)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORDepthLimit(t *testing.T) {
+	nested := func(depth int) []byte {
+		return append(bytes.Repeat([]byte{0x81}, depth), 0x00)
+	}
+
+	if _, _, err := decodeCBOR(nested(maxCBORDepth)); err != nil {
+		t.Fatalf("imbrication de %d niveaux refusée: %v", maxCBORDepth, err)
+	}
+	if _, _, err := decodeCBOR(nested(maxCBORDepth + 1)); err != errInvalidCBOR {
+		t.Fatalf("err = %v, attendu %v", err, errInvalidCBOR)
+	}
+}
+
+func TestEncodeCBORRoundTrip(t *testing.T) {
+	value := map[interface{}]interface{}{
+		"fmt":      "packed",
+		int64(-2):  bytes.Repeat([]byte{0xab}, 300),
+		int64(3):   int64(-257),
+		"attStmt":  []interface{}{true, false, nil, int64(70000)},
+		"authData": []byte{0x00},
+	}
+
+	got, rest, err := decodeCBOR(encodeCBOR(value))
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("decodeCBOR: %v (reste %x)", err, rest)
+	}
+	if !reflect.DeepEqual(got, value) {
+		t.Fatalf("decodeCBOR = %#v, attendu %#v", got, value)
+	}
+}
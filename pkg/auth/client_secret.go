@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+)
+
+// HashClientSecret retourne l'empreinte stockée d'un secret de client OAuth. Les secrets
+// sont générés aléatoirement (256 bits) : un SHA-256 suffit, sans le coût d'argon2id
+// réservé aux mots de passe, que tout appelant pourrait sinon imposer à chaque requête.
+func HashClientSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckClientSecret compare un secret présenté à son empreinte stockée en temps constant
+func CheckClientSecret(secret string, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashClientSecret(secret)), []byte(hash)) == 1
+}
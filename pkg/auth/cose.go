@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// Algorithmes COSE acceptés pour les clés d'authentificateur (RFC 9053, registre IANA)
+const (
+	COSEAlgES256 int64 = -7
+	COSEAlgEdDSA int64 = -8
+	COSEAlgRS256 int64 = -257
+)
+
+// SupportedCOSEAlgorithms est l'ordre de préférence annoncé aux navigateurs
+var SupportedCOSEAlgorithms = []int64{COSEAlgES256, COSEAlgEdDSA, COSEAlgRS256}
+
+// Paramètres des clés COSE (RFC 9052 section 7, RFC 9053 section 7)
+const (
+	coseKeyType   = 1
+	coseKeyAlg    = 3
+	coseKeyCurve  = -1
+	coseKeyX      = -2
+	coseKeyY      = -3
+	coseKeyRSAN   = -1
+	coseKeyRSAE   = -2
+	coseKtyOKP    = 1
+	coseKtyEC2    = 2
+	coseKtyRSA    = 3
+	coseCurveP256 = 1
+	coseCurveEd25 = 6
+)
+
+var (
+	ErrUnsupportedCOSEKey = errors.New("unsupported COSE key")
+	ErrInvalidSignature   = errors.New("invalid signature")
+)
+
+// COSEPublicKey est une clé publique d'authentificateur décodée depuis sa forme COSE
+type COSEPublicKey struct {
+	Algorithm int64
+	Key       crypto.PublicKey
+}
+
+// ParseCOSEKey décode une clé publique COSE et retourne les octets qui la suivent
+func ParseCOSEKey(data []byte) (*COSEPublicKey, []byte, error) {
+	value, rest, err := decodeCBOR(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, nil, ErrUnsupportedCOSEKey
+	}
+
+	kty, _ := entries[int64(coseKeyType)].(int64)
+	alg, _ := entries[int64(coseKeyAlg)].(int64)
+
+	switch {
+	case kty == coseKtyEC2 && alg == COSEAlgES256:
+		curve, _ := entries[int64(coseKeyCurve)].(int64)
+		x, _ := entries[int64(coseKeyX)].([]byte)
+		y, _ := entries[int64(coseKeyY)].([]byte)
+		if curve != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, nil, ErrUnsupportedCOSEKey
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, nil, ErrUnsupportedCOSEKey
+		}
+		return &COSEPublicKey{Algorithm: alg, Key: key}, rest, nil
+	case kty == coseKtyOKP && alg == COSEAlgEdDSA:
+		curve, _ := entries[int64(coseKeyCurve)].(int64)
+		x, _ := entries[int64(coseKeyX)].([]byte)
+		if curve != coseCurveEd25 || len(x) != ed25519.PublicKeySize {
+			return nil, nil, ErrUnsupportedCOSEKey
+		}
+		return &COSEPublicKey{Algorithm: alg, Key: ed25519.PublicKey(x)}, rest, nil
+	case kty == coseKtyRSA && alg == COSEAlgRS256:
+		n, _ := entries[int64(coseKeyRSAN)].([]byte)
+		e, _ := entries[int64(coseKeyRSAE)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, nil, ErrUnsupportedCOSEKey
+		}
+		exponent := int(new(big.Int).SetBytes(e).Int64())
+		return &COSEPublicKey{Algorithm: alg, Key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}}, rest, nil
+	default:
+		return nil, nil, ErrUnsupportedCOSEKey
+	}
+}
+
+// Verify vérifie une signature WebAuthn (données signées brutes, hachées selon l'algorithme)
+func (k *COSEPublicKey) Verify(data, signature []byte) error {
+	return verifyWithAlgorithm(k.Algorithm, k.Key, data, signature)
+}
+
+// verifyWithAlgorithm vérifie une signature avec une clé issue d'une clé COSE ou d'un certificat
+func verifyWithAlgorithm(alg int64, key crypto.PublicKey, data, signature []byte) error {
+	switch alg {
+	case COSEAlgES256:
+		publicKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return ErrUnsupportedCOSEKey
+		}
+		digest := sha256.Sum256(data)
+		// WebAuthn transporte les signatures ECDSA en DER (ASN.1), pas en r||s
+		if !ecdsa.VerifyASN1(publicKey, digest[:], signature) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case COSEAlgEdDSA:
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return ErrUnsupportedCOSEKey
+		}
+		if !ed25519.Verify(publicKey, data, signature) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case COSEAlgRS256:
+		publicKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrUnsupportedCOSEKey
+		}
+		digest := sha256.Sum256(data)
+		if rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature) != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+	default:
+		return ErrUnsupportedCOSEKey
+	}
+}
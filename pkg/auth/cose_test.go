@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+)
+
+// encodeCOSEEC2 encode une clé publique P-256 au format COSE ES256
+func encodeCOSEEC2(key *ecdsa.PublicKey) []byte {
+	return encodeCBOR(map[interface{}]interface{}{
+		int64(coseKeyType):  int64(coseKtyEC2),
+		int64(coseKeyAlg):   COSEAlgES256,
+		int64(coseKeyCurve): int64(coseCurveP256),
+		int64(coseKeyX):     key.X.FillBytes(make([]byte, 32)),
+		int64(coseKeyY):     key.Y.FillBytes(make([]byte, 32)),
+	})
+}
+
+func encodeCOSEOKP(key ed25519.PublicKey) []byte {
+	return encodeCBOR(map[interface{}]interface{}{
+		int64(coseKeyType):  int64(coseKtyOKP),
+		int64(coseKeyAlg):   COSEAlgEdDSA,
+		int64(coseKeyCurve): int64(coseCurveEd25),
+		int64(coseKeyX):     []byte(key),
+	})
+}
+
+func encodeCOSERSA(key *rsa.PublicKey) []byte {
+	return encodeCBOR(map[interface{}]interface{}{
+		int64(coseKeyType): int64(coseKtyRSA),
+		int64(coseKeyAlg):  COSEAlgRS256,
+		int64(coseKeyRSAN): key.N.Bytes(),
+		int64(coseKeyRSAE): big.NewInt(int64(key.E)).Bytes(),
+	})
+}
+
+func TestParseCOSEKeyES256(t *testing.T) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("génération de la clé: %v", err)
+	}
+	data := []byte("données signées")
+	digest := sha256.Sum256(data)
+	signature, err := ecdsa.SignASN1(rand.Reader, private, digest[:])
+	if err != nil {
+		t.Fatalf("signature: %v", err)
+	}
+
+	key, rest, err := ParseCOSEKey(append(encodeCOSEEC2(&private.PublicKey), 0xff))
+	if err != nil {
+		t.Fatalf("ParseCOSEKey: %v", err)
+	}
+	if len(rest) != 1 || rest[0] != 0xff {
+		t.Errorf("reste = %x, attendu ff", rest)
+	}
+	if key.Algorithm != COSEAlgES256 || !private.PublicKey.Equal(key.Key) {
+		t.Fatalf("clé inattendue: %+v", key)
+	}
+	if err := key.Verify(data, signature); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if err := key.Verify([]byte("autres données"), signature); err != ErrInvalidSignature {
+		t.Errorf("err = %v, attendu %v", err, ErrInvalidSignature)
+	}
+
+	// WebAuthn transporte les signatures ECDSA en DER : la forme brute r||s est refusée
+	r, s, err := ecdsa.Sign(rand.Reader, private, digest[:])
+	if err != nil {
+		t.Fatalf("signature: %v", err)
+	}
+	raw := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	if err := key.Verify(data, raw); err != ErrInvalidSignature {
+		t.Errorf("err = %v, attendu %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestParseCOSEKeyEdDSA(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("génération de la clé: %v", err)
+	}
+	data := []byte("données signées")
+
+	key, _, err := ParseCOSEKey(encodeCOSEOKP(public))
+	if err != nil {
+		t.Fatalf("ParseCOSEKey: %v", err)
+	}
+	if key.Algorithm != COSEAlgEdDSA {
+		t.Fatalf("Algorithm = %d, attendu %d", key.Algorithm, COSEAlgEdDSA)
+	}
+	if err := key.Verify(data, ed25519.Sign(private, data)); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if err := key.Verify([]byte("autres données"), ed25519.Sign(private, data)); err != ErrInvalidSignature {
+		t.Errorf("err = %v, attendu %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestParseCOSEKeyRS256(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("génération de la clé: %v", err)
+	}
+	data := []byte("données signées")
+	digest := sha256.Sum256(data)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, private, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("signature: %v", err)
+	}
+
+	key, _, err := ParseCOSEKey(encodeCOSERSA(&private.PublicKey))
+	if err != nil {
+		t.Fatalf("ParseCOSEKey: %v", err)
+	}
+	if key.Algorithm != COSEAlgRS256 || !private.PublicKey.Equal(key.Key) {
+		t.Fatalf("clé inattendue: %+v", key)
+	}
+	if err := key.Verify(data, signature); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if err := key.Verify([]byte("autres données"), signature); err != ErrInvalidSignature {
+		t.Errorf("err = %v, attendu %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestParseCOSEKeyRejects(t *testing.T) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("génération de la clé: %v", err)
+	}
+	x := private.X.FillBytes(make([]byte, 32))
+	y := private.Y.FillBytes(make([]byte, 32))
+	offCurve := append([]byte(nil), y...)
+	offCurve[31] ^= 0x01
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("génération de la clé: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("génération de la clé: %v", err)
+	}
+
+	ec2 := func(alg, curve int64, x, y []byte) []byte {
+		return encodeCBOR(map[interface{}]interface{}{
+			int64(coseKeyType):  int64(coseKtyEC2),
+			int64(coseKeyAlg):   alg,
+			int64(coseKeyCurve): curve,
+			int64(coseKeyX):     x,
+			int64(coseKeyY):     y,
+		})
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{name: "pas une map", data: encodeCBOR([]interface{}{int64(1)}), wantErr: ErrUnsupportedCOSEKey},
+		{name: "map vide", data: encodeCBOR(map[interface{}]interface{}{}), wantErr: ErrUnsupportedCOSEKey},
+		{name: "EC2 avec l'algorithme EdDSA", data: ec2(COSEAlgEdDSA, coseCurveP256, x, y), wantErr: ErrUnsupportedCOSEKey},
+		{name: "EC2 sur P-384", data: ec2(COSEAlgES256, 2, x, y), wantErr: ErrUnsupportedCOSEKey},
+		{name: "EC2 à coordonnée tronquée", data: ec2(COSEAlgES256, coseCurveP256, x[1:], y), wantErr: ErrUnsupportedCOSEKey},
+		{name: "EC2 hors de la courbe", data: ec2(COSEAlgES256, coseCurveP256, x, offCurve), wantErr: ErrUnsupportedCOSEKey},
+		{name: "OKP tronquée", data: encodeCOSEOKP(public[:31]), wantErr: ErrUnsupportedCOSEKey},
+		{name: "RSA de 1024 bits", data: encodeCOSERSA(&rsaKey.PublicKey), wantErr: ErrUnsupportedCOSEKey},
+		{name: "ES384", data: ec2(-35, coseCurveP256, x, y), wantErr: ErrUnsupportedCOSEKey},
+		{name: "CBOR invalide", data: []byte{0xa1}, wantErr: errInvalidCBOR},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ParseCOSEKey(tt.data); err != tt.wantErr {
+				t.Fatalf("err = %v, attendu %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyWithAlgorithmRejectsKeyTypeMismatch(t *testing.T) {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("génération de la clé: %v", err)
+	}
+
+	for _, alg := range []int64{COSEAlgES256, COSEAlgRS256, -35} {
+		if err := verifyWithAlgorithm(alg, public, []byte("données"), []byte("signature")); err != ErrUnsupportedCOSEKey {
+			t.Errorf("alg %d: err = %v, attendu %v", alg, err, ErrUnsupportedCOSEKey)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// EmailVerificationLifetime laisse le temps d'ouvrir l'email reçu à l'inscription
+const EmailVerificationLifetime = 24 * time.Hour
+
+// EmailVerificationClaims contient les informations portées par un token de vérification
+type EmailVerificationClaims struct {
+	UserID    string
+	Email     string
+	ExpiresAt time.Time
+}
+
+// GenerateEmailVerificationToken génère un JWT prouvant la réception d'un email à l'adresse
+// donnée. L'adresse est signée : le token ne vaut plus rien si l'utilisateur en change.
+func GenerateEmailVerificationToken(userID string, email string, tokens *TokenConfig) (string, error) {
+	claims := jwt.MapClaims{
+		"uid":   userID,
+		"email": email,
+		"aud":   tokens.Issuer,
+	}
+	return tokens.sign(TypeVerifyEmail, claims, EmailVerificationLifetime)
+}
+
+// ValidateEmailVerificationToken valide un token de vérification et retourne ses claims
+func ValidateEmailVerificationToken(tokenString string, tokens *TokenConfig) (*EmailVerificationClaims, error) {
+	claims, err := tokens.parse(tokenString, TypeVerifyEmail, tokens.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, ok := claims["uid"].(string)
+	if !ok || userID == "" {
+		return nil, errors.New("invalid token claims: missing uid")
+	}
+
+	email, ok := claims["email"].(string)
+	if !ok || email == "" {
+		return nil, errors.New("invalid token claims: missing email")
+	}
+
+	exp, _ := claims["exp"].(float64)
+
+	return &EmailVerificationClaims{
+		UserID:    userID,
+		Email:     email,
+		ExpiresAt: time.Unix(int64(exp), 0),
+	}, nil
+}
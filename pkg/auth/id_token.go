@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"crypto"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// TypeIDToken est le type des ID tokens OpenID Connect, qui ne portent pas de typ dédié
+const TypeIDToken = "JWT"
+
+var ErrNoIDTokenKey = errors.New("ID tokens require an asymmetric signing key")
+
+// IDTokensEnabled indique si la clé de signature courante peut signer des ID tokens. Une clé
+// HMAC n'est pas publiée dans le JWKS : aucun client ne pourrait vérifier la signature.
+func IDTokensEnabled(keys KeySource) bool {
+	key := keys.SigningKey()
+	return key != nil && !key.IsSymmetric()
+}
+
+// IDClaims contient les informations portées par un ID token OpenID Connect.
+// Les claims de profil sont omis lorsqu'ils sont vides (scope non accordé).
+type IDClaims struct {
+	UserID   string
+	ClientID string
+	Nonce    string
+	AuthTime time.Time
+	// AccessToken émis en même temps, dont l'empreinte est placée dans at_hash
+	AccessToken   string
+	Name          string
+	Email         string
+	EmailVerified *bool
+}
+
+// GenerateIDToken génère un ID token (OpenID Connect Core section 2) destiné au client
+func GenerateIDToken(id IDClaims, tokens *TokenConfig, expiryHours int) (string, error) {
+	claims := jwt.MapClaims{
+		"sub":       id.UserID,
+		"aud":       id.ClientID,
+		"azp":       id.ClientID,
+		"auth_time": id.AuthTime.Unix(),
+	}
+	if id.Nonce != "" {
+		claims["nonce"] = id.Nonce
+	}
+	// La clé est choisie une seule fois : at_hash dépend de son algorithme
+	key := tokens.Keys.SigningKey()
+	if key == nil || key.IsSymmetric() {
+		return "", ErrNoIDTokenKey
+	}
+	if id.AccessToken != "" {
+		claims["at_hash"] = accessTokenHash(id.AccessToken, key)
+	}
+	if id.Name != "" {
+		claims["name"] = id.Name
+	}
+	if id.Email != "" {
+		claims["email"] = id.Email
+	}
+	if id.EmailVerified != nil {
+		claims["email_verified"] = *id.EmailVerified
+	}
+
+	return tokens.signWith(key, TypeIDToken, claims, time.Hour*time.Duration(expiryHours))
+}
+
+// accessTokenHash calcule at_hash : la moitié gauche de l'empreinte du token d'accès,
+// avec la fonction de hachage de l'algorithme de signature (OpenID Connect Core section 3.1.3.6)
+func accessTokenHash(accessToken string, key *SigningKey) string {
+	hash := crypto.SHA256
+	switch alg := key.Method.Alg(); {
+	case alg == "EdDSA" || strings.HasSuffix(alg, "512"):
+		hash = crypto.SHA512
+	case strings.HasSuffix(alg, "384"):
+		hash = crypto.SHA384
+	}
+
+	hasher := hash.New()
+	hasher.Write([]byte(accessToken))
+	sum := hasher.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
@@ -8,115 +8,238 @@ import (
 	"github.com/google/uuid"
 )
 
-func GenerateToken(userID string, secret string, expiryHours int) (string, error) {
+// RefreshTokenLifetime est la durée de validité d'un refresh token
+const RefreshTokenLifetime = 30 * 24 * time.Hour
+
+// AccessClaims contient les informations portées par un token d'accès.
+// Subject, TokenID, IssuedAt et ExpiresAt sont renseignés à la validation.
+// Un token émis pour un client (grant client_credentials) n'a pas de UserID :
+// son sujet est le client lui-même.
+type AccessClaims struct {
+	Subject   string
+	UserID    string
+	SessionID string
+	// ClientID et Scope sont renseignés pour les tokens émis via OAuth 2.0
+	ClientID  string
+	Scope     string
+	TokenID   string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+
+	// Roles et Permissions ne sont portés que par les tokens émis directement à l'utilisateur
+	Roles       []string
+	Permissions []string
+	// OrgID est l'organisation dans le contexte de laquelle le token a été émis, vide pour l'instance
+	OrgID string
+}
+
+// IsClient indique si le token a été émis à un client agissant pour son propre compte
+func (c *AccessClaims) IsClient() bool {
+	return c.UserID == ""
+}
+
+func GenerateToken(access AccessClaims, tokens *TokenConfig, expiryHours int) (string, error) {
 	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * time.Duration(expiryHours)).Unix(),
-		"iat":     time.Now().Unix(),
+		"aud": tokens.Audience,
+	}
+	if access.UserID != "" {
+		claims["user_id"] = access.UserID
+		claims["sub"] = access.UserID
+		claims["sid"] = access.SessionID
+	} else {
+		claims["sub"] = access.ClientID
+	}
+	if access.ClientID != "" {
+		claims["client_id"] = access.ClientID
+	}
+	if access.Scope != "" {
+		claims["scope"] = access.Scope
+	}
+	if len(access.Roles) > 0 {
+		claims["roles"] = access.Roles
+	}
+	if len(access.Permissions) > 0 {
+		claims["permissions"] = access.Permissions
+	}
+	if access.OrgID != "" {
+		claims["org_id"] = access.OrgID
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return tokens.sign(TypeAccessToken, claims, time.Hour*time.Duration(expiryHours))
 }
 
-func GetTokenClaims(tokenString string, secret string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(secret), nil
-	})
-
+// ValidateToken valide un token d'accès destiné à ce service (typ, iss, aud, exp, nbf)
+func ValidateToken(tokenString string, tokens *TokenConfig) (*AccessClaims, error) {
+	claims, err := tokens.parse(tokenString, TypeAccessToken, tokens.AcceptedAudience)
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return claims, nil
+	userID, _ := claims["user_id"].(string)
+	subject, _ := claims["sub"].(string)
+	clientID, _ := claims["client_id"].(string)
+
+	// Sans utilisateur, le sujet doit être le client auquel le token a été émis
+	if userID == "" && (clientID == "" || subject != clientID) {
+		return nil, errors.New("invalid claim: user_id")
+	}
+	if subject == "" {
+		subject = userID
 	}
 
-	return nil, errors.New("invalid token")
-}
+	tokenID, ok := claims["jti"].(string)
+	if !ok {
+		return nil, errors.New("invalid claim: jti")
+	}
 
-func ValidateToken(tokenString string, secret string) (string, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(secret), nil
-	})
+	sessionID, _ := claims["sid"].(string)
+	scope, _ := claims["scope"].(string)
+	orgID, _ := claims["org_id"].(string)
+	iat, _ := claims["iat"].(float64)
+	exp, _ := claims["exp"].(float64)
 
-	if err != nil {
-		return "", err
-	}
+	return &AccessClaims{
+		Subject:     subject,
+		UserID:      userID,
+		SessionID:   sessionID,
+		ClientID:    clientID,
+		Scope:       scope,
+		TokenID:     tokenID,
+		IssuedAt:    time.Unix(int64(iat), 0),
+		ExpiresAt:   time.Unix(int64(exp), 0),
+		Roles:       stringsClaim(claims["roles"]),
+		Permissions: stringsClaim(claims["permissions"]),
+		OrgID:       orgID,
+	}, nil
+}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID, ok := claims["user_id"].(string)
-		if !ok {
-			return "", errors.New("invalid claim: user_id")
+// stringsClaim lit un claim de type tableau de chaînes, décodé en []interface{}
+func stringsClaim(value interface{}) []string {
+	items, _ := value.([]interface{})
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			values = append(values, s)
 		}
-		return userID, nil
 	}
+	return values
+}
 
-	return "", errors.New("invalid token")
+// RefreshClaims contient les informations portées par un refresh token.
+// TokenID, IssuedAt et ExpiresAt sont renseignés à la validation.
+type RefreshClaims struct {
+	UserID    string
+	SessionID string
+	ClientID  string
+	TokenID   string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
 }
 
-func GenerateRefreshToken(userID string, secret string) (string, error) {
+// GenerateRefreshToken génère un refresh token rattaché à une session, qui sert aussi
+// de famille de rotation, et retourne le token ainsi que son identifiant (jti).
+// Son audience est l'émetteur lui-même : aucun autre service ne doit l'accepter.
+func GenerateRefreshToken(refresh RefreshClaims, tokens *TokenConfig) (string, string, error) {
+	tokenID := uuid.New().String()
+
 	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24 * 30).Unix(),
-		"iat":     time.Now().Unix(),
-		"type":    "refresh",
+		"user_id": refresh.UserID,
+		"sub":     refresh.UserID,
+		"jti":     tokenID,
+		"sid":     refresh.SessionID,
+		"aud":     tokens.Issuer,
+	}
+	if refresh.ClientID != "" {
+		claims["client_id"] = refresh.ClientID
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	tokenString, err := tokens.sign(TypeRefreshToken, claims, RefreshTokenLifetime)
+	if err != nil {
+		return "", "", err
+	}
+
+	return tokenString, tokenID, nil
 }
 
-func ValidateRefreshToken(tokenString string, secret string) (string, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(secret), nil
-	})
+func ValidateRefreshToken(tokenString string, tokens *TokenConfig) (*RefreshClaims, error) {
+	claims, err := tokens.parse(tokenString, TypeRefreshToken, tokens.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, ok := claims["user_id"].(string)
+	if !ok {
+		return nil, errors.New("invalid claim: user_id")
+	}
+
+	tokenID, ok := claims["jti"].(string)
+	if !ok {
+		return nil, errors.New("invalid claim: jti")
+	}
+
+	sessionID, ok := claims["sid"].(string)
+	if !ok {
+		return nil, errors.New("invalid claim: sid")
+	}
+
+	clientID, _ := claims["client_id"].(string)
+	iat, _ := claims["iat"].(float64)
+	exp, _ := claims["exp"].(float64)
+
+	return &RefreshClaims{
+		UserID:    userID,
+		SessionID: sessionID,
+		ClientID:  clientID,
+		TokenID:   tokenID,
+		IssuedAt:  time.Unix(int64(iat), 0),
+		ExpiresAt: time.Unix(int64(exp), 0),
+	}, nil
+}
 
+// GetTokenID vérifie un token du type attendu (signature, émetteur et dates) et retourne
+// son identifiant (jti) et sa date d'expiration
+func GetTokenID(tokenString string, tokenType string, tokens *TokenConfig) (string, time.Time, error) {
+	claims, err := tokens.parse(tokenString, tokenType, "")
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		tokenType, ok := claims["type"].(string)
-		if !ok || tokenType != "refresh" {
-			return "", errors.New("invalid token type")
-		}
+	jti, ok := claims["jti"].(string)
+	if !ok || jti == "" {
+		return "", time.Time{}, errors.New("invalid claim: jti")
+	}
 
-		userID, ok := claims["user_id"].(string)
-		if !ok {
-			return "", errors.New("invalid claim: user_id")
-		}
-		return userID, nil
+	exp, _ := claims["exp"].(float64)
+	return jti, time.Unix(int64(exp), 0), nil
+}
+
+// GetTokenClientID retourne le client OAuth auquel le token a été émis, vide pour un token
+// émis directement par l'API (/login, /refresh)
+func GetTokenClientID(tokenString string, tokens *TokenConfig) (string, error) {
+	claims, _, err := tokens.parseSigned(tokenString)
+	if err != nil {
+		return "", err
 	}
 
-	return "", errors.New("invalid token")
+	clientID, _ := claims["client_id"].(string)
+	return clientID, nil
 }
 
 // GenerateResetToken génère un JWT pour la réinitialisation de mot de passe
 // avec un identifiant unique (uid) pour le token
-func GenerateResetToken(email string, secret string, expiryHours int) (string, string, error) {
+func GenerateResetToken(email string, tokens *TokenConfig, expiryHours int) (string, string, error) {
 	// Générer un identifiant unique pour ce token de réinitialisation
 	tokenUID := uuid.New().String()
 
+	// Le token n'est destiné qu'à ce serveur : l'audience est l'émetteur lui-même
 	claims := jwt.MapClaims{
 		"email": email,
 		"uid":   tokenUID,
-		"exp":   time.Now().Add(time.Hour * time.Duration(expiryHours)).Unix(),
-		"iat":   time.Now().Unix(),
-		"type":  "reset",
+		"jti":   tokenUID,
+		"aud":   tokens.Issuer,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(secret))
+	tokenString, err := tokens.sign(TypeResetToken, claims, time.Hour*time.Duration(expiryHours))
 	if err != nil {
 		return "", "", err
 	}
@@ -126,18 +249,12 @@ func GenerateResetToken(email string, secret string, expiryHours int) (string, s
 
 // ValidateResetToken valide un JWT de réinitialisation de mot de passe
 // et retourne l'email associé si le token est valide
-func ValidateResetToken(tokenString string, secret string) (string, string, error) {
-	claims, err := GetTokenClaims(tokenString, secret)
+func ValidateResetToken(tokenString string, tokens *TokenConfig) (string, string, error) {
+	claims, err := tokens.parse(tokenString, TypeResetToken, tokens.Issuer)
 	if err != nil {
 		return "", "", err
 	}
 
-	// Vérifier que c'est bien un token de type reset
-	tokenType, ok := claims["type"].(string)
-	if !ok || tokenType != "reset" {
-		return "", "", errors.New("invalid token type")
-	}
-
 	// Récupérer l'email et l'uid
 	email, ok := claims["email"].(string)
 	if !ok {
@@ -0,0 +1,509 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrKeyAlreadyExists = errors.New("signing key already exists")
+	ErrNoActiveKey      = errors.New("no active signing key")
+)
+
+// États d'une clé dans le trousseau
+const (
+	KeyStatePending  = "pending"
+	KeyStateActive   = "active"
+	KeyStateRetiring = "retiring"
+)
+
+// keySyncInterval limite la relecture du stockage déclenchée par un kid inconnu
+const keySyncInterval = 5 * time.Second
+
+// KeyInfo décrit une clé du trousseau sans exposer le matériel privé
+type KeyInfo struct {
+	ID          string     `json:"kid"`
+	Algorithm   string     `json:"alg"`
+	State       string     `json:"state"`
+	ActivatesAt time.Time  `json:"activates_at"`
+	RetiresAt   *time.Time `json:"retires_at,omitempty"`
+}
+
+type ringEntry struct {
+	key         *SigningKey
+	activatesAt time.Time
+	// Date à laquelle la clé est retirée du trousseau (nil tant qu'elle signe)
+	retiresAt *time.Time
+	// saved indique que l'état courant de l'entrée est enregistré dans le stockage
+	saved bool
+}
+
+// KeyRing contient la clé de signature courante, les clés programmées et les
+// clés en cours de retrait. Une clé retirée reste utilisable pour la vérification
+// pendant la durée de vie maximale d'un token, puis elle est supprimée.
+// Avec un KeyStore, le trousseau est persisté et partagé entre les instances.
+type KeyRing struct {
+	mutex     sync.Mutex
+	entries   []*ringEntry
+	current   *ringEntry
+	retention time.Duration
+	now       func() time.Time
+	store     KeyStore
+	// retired contient les kids retirés, qui ne peuvent plus être réutilisés
+	retired  map[string]bool
+	syncedAt time.Time
+}
+
+// NewKeyRing crée un trousseau en mémoire dont la clé initiale est active immédiatement.
+// retention est la durée pendant laquelle une clé remplacée continue d'être acceptée.
+func NewKeyRing(initial *SigningKey, retention time.Duration) *KeyRing {
+	ring := &KeyRing{
+		retention: retention,
+		now:       time.Now,
+		retired:   make(map[string]bool),
+	}
+	entry := &ringEntry{key: initial, activatesAt: ring.now(), saved: true}
+	ring.entries = []*ringEntry{entry}
+	ring.current = entry
+	return ring
+}
+
+// OpenKeyRing charge les clés non retirées du stockage puis y ajoute les clés configurées.
+// configured devient la clé de signature si son kid est nouveau, la clé active précédente
+// passant en retrait ; previous sont des clés anciennes acceptées jusqu'à la fin de la rétention.
+// Un kid déjà enregistré avec un autre matériel est refusé : changer de clé impose un nouveau kid.
+func OpenKeyRing(store KeyStore, retention time.Duration, configured *SigningKey, previous []*SigningKey) (*KeyRing, error) {
+	ring := &KeyRing{
+		retention: retention,
+		now:       time.Now,
+		store:     store,
+	}
+
+	stored, err := store.ListKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	ring.entries, ring.retired, err = ring.load(stored)
+	if err != nil {
+		return nil, err
+	}
+	ring.current = pickCurrent(ring.entries, ring.now())
+
+	now := ring.now()
+	for _, key := range previous {
+		added, err := ring.adopt(key)
+		if err != nil {
+			return nil, err
+		}
+		if !added {
+			continue
+		}
+		retiresAt := now.Add(retention)
+		ring.entries = append(ring.entries, &ringEntry{key: key, activatesAt: now, retiresAt: &retiresAt})
+	}
+
+	if configured != nil {
+		added, err := ring.adopt(configured)
+		if err != nil {
+			return nil, err
+		}
+		if added {
+			ring.entries = append(ring.entries, &ringEntry{key: configured, activatesAt: now})
+		}
+	}
+
+	// Toutes les clés, y compris la clé configurée, sont enregistrées avant d'être utilisées
+	if err := ring.refresh(); err != nil {
+		return nil, err
+	}
+	if ring.current == nil {
+		return nil, ErrNoActiveKey
+	}
+	return ring, nil
+}
+
+// adopt vérifie qu'une clé configurée peut rejoindre le trousseau. Elle retourne false
+// si la clé y est déjà ou a été retirée. Doit être appelée avec le mutex verrouillé.
+func (r *KeyRing) adopt(key *SigningKey) (bool, error) {
+	if r.retired[key.ID] {
+		return false, nil
+	}
+	for _, entry := range r.entries {
+		if entry.key.ID == key.ID {
+			if !sameKeyMaterial(entry.key, key) {
+				return false, fmt.Errorf("%w: %s", ErrKeyMismatch, key.ID)
+			}
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Schedule ajoute une clé qui sera publiée immédiatement dans le JWKS et qui
+// deviendra la clé de signature à activatesAt. La clé courante passe alors en retrait.
+func (r *KeyRing) Schedule(key *SigningKey, activatesAt time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.retired[key.ID] {
+		return ErrKeyAlreadyExists
+	}
+	for _, entry := range r.entries {
+		if entry.key.ID == key.ID {
+			return ErrKeyAlreadyExists
+		}
+	}
+
+	// Une date passée équivaut à une activation immédiate
+	if now := r.now(); activatesAt.Before(now) {
+		activatesAt = now
+	}
+
+	// La clé est enregistrée avant d'être publiée pour que les autres instances la connaissent
+	entry := &ringEntry{key: key, activatesAt: activatesAt}
+	if err := r.save(entry); err != nil {
+		return err
+	}
+
+	// Une activation immédiate change l'état des clés : son échec d'enregistrement est
+	// signalé, l'état sera réenregistré au prochain appel
+	r.entries = append(r.entries, entry)
+	return r.refresh()
+}
+
+// Rotate génère une nouvelle clé du même algorithme que la clé courante et la
+// programme pour activatesAt (immédiatement si la date est zéro ou passée)
+func (r *KeyRing) Rotate(activatesAt time.Time) (*KeyInfo, error) {
+	r.mutex.Lock()
+	err := r.refresh()
+	alg := r.current.key.Method.Alg()
+	r.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := GenerateSigningKey(uuid.New().String(), alg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.Schedule(key, activatesAt); err != nil {
+		return nil, err
+	}
+
+	for _, info := range r.Keys() {
+		if info.ID == key.ID {
+			return &info, nil
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+// Sync relit le stockage pour récupérer les clés ajoutées ou retirées par les autres instances
+func (r *KeyRing) Sync() error {
+	if r.store == nil {
+		return nil
+	}
+
+	stored, err := r.store.ListKeys()
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	entries, retired, err := r.load(stored)
+	if err != nil {
+		return err
+	}
+	current := pickCurrent(entries, r.now())
+	if current == nil {
+		return ErrNoActiveKey
+	}
+
+	r.entries, r.retired, r.current = entries, retired, current
+	r.syncedAt = r.now()
+	return r.refresh()
+}
+
+// load construit les entrées du trousseau à partir des clés persistées. Les clés déjà
+// chargées sont réutilisées et celles dont l'enregistrement a échoué sont conservées.
+// Doit être appelée avec le mutex verrouillé.
+func (r *KeyRing) load(stored []StoredKey) ([]*ringEntry, map[string]bool, error) {
+	now := r.now()
+	loaded := make(map[string]*SigningKey)
+	for _, entry := range r.entries {
+		loaded[entry.key.ID] = entry.key
+	}
+
+	var entries []*ringEntry
+	retired := make(map[string]bool)
+	for _, key := range stored {
+		if key.State == KeyStateRetired || (key.RetiresAt != nil && !key.RetiresAt.After(now)) {
+			retired[key.ID] = true
+			continue
+		}
+
+		signingKey, ok := loaded[key.ID]
+		if !ok {
+			var err error
+			if signingKey, err = decodeStoredKey(key); err != nil {
+				return nil, nil, fmt.Errorf("stored signing key %s: %w", key.ID, err)
+			}
+		}
+		entries = append(entries, &ringEntry{key: signingKey, activatesAt: key.ActivatesAt, retiresAt: key.RetiresAt, saved: true})
+		delete(loaded, key.ID)
+	}
+
+	for _, entry := range r.entries {
+		if _, missing := loaded[entry.key.ID]; missing && !entry.saved && !retired[entry.key.ID] {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, retired, nil
+}
+
+// pickCurrent retourne la plus ancienne clé activée et non retirée ; refresh promeut ensuite
+// les suivantes dans l'ordre, ce qui place les éventuelles clés concurrentes en retrait.
+func pickCurrent(entries []*ringEntry, now time.Time) *ringEntry {
+	var current *ringEntry
+	for _, entry := range entries {
+		if entry.retiresAt != nil || entry.activatesAt.After(now) {
+			continue
+		}
+		if current == nil || entry.activatesAt.Before(current.activatesAt) {
+			current = entry
+		}
+	}
+	return current
+}
+
+// Keys liste les clés du trousseau et leur état
+func (r *KeyRing) Keys() []KeyInfo {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.refresh()
+
+	infos := make([]KeyInfo, 0, len(r.entries))
+	for _, entry := range r.entries {
+		infos = append(infos, KeyInfo{
+			ID:          entry.key.ID,
+			Algorithm:   entry.key.Method.Alg(),
+			State:       r.state(entry),
+			ActivatesAt: entry.activatesAt,
+			RetiresAt:   entry.retiresAt,
+		})
+	}
+	return infos
+}
+
+func (r *KeyRing) SigningKey() *SigningKey {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.refresh()
+
+	return r.current.key
+}
+
+// VerificationKey cherche la clé du kid. Un kid inconnu peut venir d'une clé créée par une
+// autre instance : le stockage est alors relu, au plus une fois toutes les keySyncInterval.
+func (r *KeyRing) VerificationKey(kid string) (*SigningKey, error) {
+	key, err := r.lookup(kid)
+	if err == ErrKeyNotFound && r.shouldSync() {
+		if r.Sync() == nil {
+			return r.lookup(kid)
+		}
+	}
+	return key, err
+}
+
+func (r *KeyRing) lookup(kid string) (*SigningKey, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.refresh()
+
+	// Les tokens émis avant l'introduction du kid sont vérifiés avec la clé courante
+	if kid == "" {
+		return r.current.key, nil
+	}
+	for _, entry := range r.entries {
+		if entry.key.ID == kid {
+			return entry.key, nil
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+func (r *KeyRing) shouldSync() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.store == nil || r.now().Sub(r.syncedAt) < keySyncInterval {
+		return false
+	}
+	r.syncedAt = r.now()
+	return true
+}
+
+func (r *KeyRing) VerificationKeys() []*SigningKey {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.refresh()
+
+	keys := make([]*SigningKey, 0, len(r.entries))
+	for _, entry := range r.entries {
+		keys = append(keys, entry.key)
+	}
+	return keys
+}
+
+func (r *KeyRing) state(entry *ringEntry) string {
+	switch {
+	case entry == r.current:
+		return KeyStateActive
+	case entry.retiresAt != nil:
+		return KeyStateRetiring
+	default:
+		return KeyStatePending
+	}
+}
+
+// refresh applique le calendrier : promotion des clés arrivées à échéance et
+// suppression des clés retirées. Les changements d'état sont enregistrés dans le
+// stockage ; la première erreur est retournée et l'enregistrement sera retenté au
+// prochain appel. Les lectures (SigningKey, VerificationKeys...) l'ignorent.
+// Doit être appelée avec le mutex verrouillé.
+func (r *KeyRing) refresh() error {
+	now := r.now()
+
+	sort.SliceStable(r.entries, func(i, j int) bool {
+		return r.entries[i].activatesAt.Before(r.entries[j].activatesAt)
+	})
+
+	for _, entry := range r.entries {
+		if entry == r.current || entry.retiresAt != nil || entry.activatesAt.After(now) {
+			continue
+		}
+		if r.current != nil {
+			retiresAt := entry.activatesAt.Add(r.retention)
+			r.current.retiresAt = &retiresAt
+			r.current.saved = false
+		}
+		entry.retiresAt = nil
+		entry.saved = false
+		r.current = entry
+	}
+
+	kept := r.entries[:0]
+	for _, entry := range r.entries {
+		if entry != r.current && entry.retiresAt != nil && !entry.retiresAt.After(now) {
+			r.retire(entry)
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	r.entries = kept
+
+	var saveErr error
+	for _, entry := range r.entries {
+		if !entry.saved {
+			if err := r.save(entry); err != nil && saveErr == nil {
+				saveErr = err
+			}
+		}
+	}
+	return saveErr
+}
+
+// save enregistre l'état de l'entrée. Doit être appelée avec le mutex verrouillé.
+func (r *KeyRing) save(entry *ringEntry) error {
+	if r.store == nil {
+		entry.saved = true
+		return nil
+	}
+
+	material, err := encodeKeyMaterial(entry.key)
+	if err != nil {
+		return err
+	}
+
+	err = r.store.SaveKey(StoredKey{
+		ID:          entry.key.ID,
+		Algorithm:   entry.key.Method.Alg(),
+		Material:    material,
+		State:       r.state(entry),
+		ActivatesAt: entry.activatesAt,
+		RetiresAt:   entry.retiresAt,
+	})
+	entry.saved = err == nil
+	return err
+}
+
+// retire efface le matériel d'une clé retirée du stockage en conservant son kid.
+// En cas d'échec, la date de retrait déjà enregistrée suffit à l'écarter au chargement.
+func (r *KeyRing) retire(entry *ringEntry) {
+	if r.retired == nil {
+		r.retired = make(map[string]bool)
+	}
+	r.retired[entry.key.ID] = true
+
+	if r.store != nil {
+		r.store.SaveKey(StoredKey{
+			ID:          entry.key.ID,
+			Algorithm:   entry.key.Method.Alg(),
+			Material:    []byte{},
+			State:       KeyStateRetired,
+			ActivatesAt: entry.activatesAt,
+			RetiresAt:   entry.retiresAt,
+		})
+	}
+}
+
+// GenerateSigningKey crée une nouvelle clé pour l'algorithme JWT donné
+func GenerateSigningKey(id string, alg string) (*SigningKey, error) {
+	switch alg {
+	case "HS256":
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		return NewHMACKey(id, base64.RawURLEncoding.EncodeToString(secret)), nil
+	case "RS256":
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(id, key)
+	case "ES256", "ES384", "ES512":
+		curves := map[string]elliptic.Curve{"ES256": elliptic.P256(), "ES384": elliptic.P384(), "ES512": elliptic.P521()}
+		key, err := ecdsa.GenerateKey(curves[alg], rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(id, key)
+	case "EdDSA":
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewSigningKey(id, key)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedKeyType, alg)
+	}
+}
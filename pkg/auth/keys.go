@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+var (
+	ErrKeyNotFound        = errors.New("signing key not found")
+	ErrUnsupportedKeyType = errors.New("unsupported key type")
+)
+
+// SigningKey représente une clé de signature des JWT identifiée par son kid.
+// Pour les clés asymétriques, seule la clé publique est exposée dans le JWKS.
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.PrivateKey
+	PublicKey  crypto.PublicKey
+}
+
+// NewHMACKey crée une clé symétrique HS256 à partir d'un secret partagé
+func NewHMACKey(id string, secret string) *SigningKey {
+	return &SigningKey{
+		ID:         id,
+		Method:     jwt.SigningMethodHS256,
+		PrivateKey: []byte(secret),
+		PublicKey:  []byte(secret),
+	}
+}
+
+// LoadSigningKey lit une clé privée PEM (RSA, ECDSA ou Ed25519) depuis un fichier
+func LoadSigningKey(id string, path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSigningKey(id, data)
+}
+
+// ParseSigningKey décode une clé privée PEM et choisit l'algorithme JWT correspondant
+func ParseSigningKey(id string, pemBytes []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+
+	var privateKey interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type: %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSigningKey(id, privateKey)
+}
+
+// NewSigningKey construit une SigningKey à partir d'une clé privée déjà décodée
+func NewSigningKey(id string, privateKey crypto.PrivateKey) (*SigningKey, error) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, PrivateKey: key, PublicKey: &key.PublicKey}, nil
+	case *ecdsa.PrivateKey:
+		var method jwt.SigningMethod
+		switch key.Curve {
+		case elliptic.P256():
+			method = jwt.SigningMethodES256
+		case elliptic.P384():
+			method = jwt.SigningMethodES384
+		case elliptic.P521():
+			method = jwt.SigningMethodES512
+		default:
+			return nil, ErrUnsupportedKeyType
+		}
+		return &SigningKey{ID: id, Method: method, PrivateKey: key, PublicKey: &key.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{ID: id, Method: SigningMethodEdDSA, PrivateKey: key, PublicKey: key.Public()}, nil
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+}
+
+// IsSymmetric indique si la clé est un secret partagé (HMAC) qui ne doit pas être publié
+func (k *SigningKey) IsSymmetric() bool {
+	_, ok := k.Method.(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+// KeySource fournit la clé utilisée pour signer et les clés acceptées pour vérifier
+type KeySource interface {
+	SigningKey() *SigningKey
+	VerificationKey(kid string) (*SigningKey, error)
+	VerificationKeys() []*SigningKey
+}
+
+type staticKeySource struct {
+	key *SigningKey
+}
+
+// NewStaticKeySource retourne une KeySource contenant une seule clé
+func NewStaticKeySource(key *SigningKey) KeySource {
+	return &staticKeySource{key: key}
+}
+
+func (s *staticKeySource) SigningKey() *SigningKey {
+	return s.key
+}
+
+func (s *staticKeySource) VerificationKey(kid string) (*SigningKey, error) {
+	// Les tokens émis avant l'introduction du kid n'ont pas d'en-tête kid
+	if kid == "" || kid == s.key.ID {
+		return s.key, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+func (s *staticKeySource) VerificationKeys() []*SigningKey {
+	return []*SigningKey{s.key}
+}
@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrKeyMismatch          = errors.New("signing key material does not match the stored key")
+	ErrInvalidEncryptionKey = errors.New("signing key encryption key must be 32 bytes")
+	ErrKeyDecryption        = errors.New("signing key material cannot be decrypted")
+)
+
+// sealedKeyPrefix marque le matériel chiffré (AES-256-GCM) ; le matériel enregistré en
+// clair avant l'introduction du chiffrement n'en porte pas
+var sealedKeyPrefix = []byte("aes256gcm:")
+
+// KeyStateRetired marque une clé persistée qui n'est plus acceptée. Son matériel est effacé
+// mais son kid est conservé pour qu'une clé retirée ne soit jamais réactivée au redémarrage.
+const KeyStateRetired = "retired"
+
+// StoredKey est la forme persistée d'une clé du trousseau
+type StoredKey struct {
+	ID        string
+	Algorithm string
+	// Material contient le secret HMAC brut ou la clé privée PEM (PKCS#8)
+	Material    []byte
+	State       string
+	ActivatesAt time.Time
+	// RetiresAt est la date après laquelle la clé n'est plus acceptée (nil tant qu'elle signe)
+	RetiresAt *time.Time
+}
+
+// KeyStore persiste les clés du trousseau pour qu'elles survivent aux redémarrages
+// et soient partagées entre les instances du service
+type KeyStore interface {
+	// SaveKey crée ou met à jour la clé identifiée par son kid
+	SaveKey(key StoredKey) error
+	// ListKeys retourne toutes les clés connues, y compris les clés retirées
+	ListKeys() ([]StoredKey, error)
+}
+
+// encryptedKeyStore chiffre le matériel des clés avant de le confier au stockage : une
+// lecture de la base ou d'une sauvegarde ne suffit pas à signer des tokens. La clé de
+// chiffrement (KEK) vient de la configuration et n'est jamais enregistrée avec les clés.
+type encryptedKeyStore struct {
+	store KeyStore
+	aead  cipher.AEAD
+}
+
+// NewEncryptedKeyStore chiffre le matériel des clés de store avec la KEK (AES-256-GCM).
+// Les clés enregistrées en clair sont acceptées puis chiffrées à la première lecture.
+func NewEncryptedKeyStore(store KeyStore, kek []byte) (KeyStore, error) {
+	if len(kek) != 32 {
+		return nil, ErrInvalidEncryptionKey
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptedKeyStore{store: store, aead: aead}, nil
+}
+
+func (s *encryptedKeyStore) SaveKey(key StoredKey) error {
+	sealed, err := s.seal(key)
+	if err != nil {
+		return err
+	}
+	key.Material = sealed
+	return s.store.SaveKey(key)
+}
+
+func (s *encryptedKeyStore) ListKeys() ([]StoredKey, error) {
+	keys, err := s.store.ListKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, key := range keys {
+		// Le matériel des clés retirées est effacé
+		if len(key.Material) == 0 {
+			continue
+		}
+		if !bytes.HasPrefix(key.Material, sealedKeyPrefix) {
+			if err := s.SaveKey(key); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		material, err := s.open(key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrKeyDecryption, key.ID)
+		}
+		keys[i].Material = material
+	}
+	return keys, nil
+}
+
+// seal chiffre le matériel ; le kid et l'algorithme sont authentifiés pour qu'un matériel
+// ne puisse pas être recopié sur une autre ligne
+func (s *encryptedKeyStore) seal(key StoredKey) ([]byte, error) {
+	if len(key.Material) == 0 {
+		return key.Material, nil
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := append(append([]byte(nil), sealedKeyPrefix...), nonce...)
+	return s.aead.Seal(sealed, nonce, key.Material, sealedKeyData(key)), nil
+}
+
+func (s *encryptedKeyStore) open(key StoredKey) ([]byte, error) {
+	sealed := key.Material[len(sealedKeyPrefix):]
+	if len(sealed) < s.aead.NonceSize() {
+		return nil, ErrKeyDecryption
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	return s.aead.Open(nil, nonce, ciphertext, sealedKeyData(key))
+}
+
+func sealedKeyData(key StoredKey) []byte {
+	return []byte(key.ID + "\x00" + key.Algorithm)
+}
+
+// encodeKeyMaterial sérialise la clé privée pour le stockage
+func encodeKeyMaterial(key *SigningKey) ([]byte, error) {
+	if key.IsSymmetric() {
+		secret, ok := key.PrivateKey.([]byte)
+		if !ok {
+			return nil, ErrUnsupportedKeyType
+		}
+		return secret, nil
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// decodeStoredKey reconstruit la clé de signature à partir de sa forme persistée
+func decodeStoredKey(stored StoredKey) (*SigningKey, error) {
+	if stored.Algorithm == "HS256" {
+		return NewHMACKey(stored.ID, string(stored.Material)), nil
+	}
+
+	key, err := ParseSigningKey(stored.ID, stored.Material)
+	if err != nil {
+		return nil, err
+	}
+	if key.Method.Alg() != stored.Algorithm {
+		return nil, fmt.Errorf("%w: stored key %s is %s, not %s", ErrUnsupportedKeyType, stored.ID, key.Method.Alg(), stored.Algorithm)
+	}
+	return key, nil
+}
+
+// sameKeyMaterial indique si deux clés portent le même matériel privé
+func sameKeyMaterial(a *SigningKey, b *SigningKey) bool {
+	if a.Method.Alg() != b.Method.Alg() {
+		return false
+	}
+	first, err := encodeKeyMaterial(a)
+	if err != nil {
+		return false
+	}
+	second, err := encodeKeyMaterial(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(first, second)
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// MFATokenLifetime laisse le temps d'ouvrir l'application d'authentification
+const MFATokenLifetime = 5 * time.Minute
+
+// MFAClaims contient les informations portées par un token de challenge MFA
+type MFAClaims struct {
+	UserID    string
+	TokenID   string
+	ExpiresAt time.Time
+}
+
+// GenerateMFAToken génère le token remis après un mot de passe correct, à échanger
+// avec un second facteur. Il ne donne accès à aucune ressource.
+func GenerateMFAToken(userID string, tokens *TokenConfig) (string, error) {
+	claims := jwt.MapClaims{
+		"sub": userID,
+		"aud": tokens.Issuer,
+	}
+	return tokens.sign(TypeMFAToken, claims, MFATokenLifetime)
+}
+
+func ValidateMFAToken(tokenString string, tokens *TokenConfig) (*MFAClaims, error) {
+	claims, err := tokens.parse(tokenString, TypeMFAToken, tokens.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, ok := claims["sub"].(string)
+	if !ok || userID == "" {
+		return nil, errors.New("invalid claim: sub")
+	}
+
+	tokenID, ok := claims["jti"].(string)
+	if !ok {
+		return nil, errors.New("invalid claim: jti")
+	}
+
+	exp, _ := claims["exp"].(float64)
+
+	return &MFAClaims{
+		UserID:    userID,
+		TokenID:   tokenID,
+		ExpiresAt: time.Unix(int64(exp), 0),
+	}, nil
+}
@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Règles de la politique de mots de passe, renvoyées au client avec chaque violation
+const (
+	RulePasswordMinLength        = "min_length"
+	RulePasswordMaxLength        = "max_length"
+	RulePasswordCharacterClasses = "character_classes"
+	RulePasswordCommon           = "common_password"
+	RulePasswordPersonalInfo     = "personal_info"
+	RulePasswordStrength         = "strength"
+	RulePasswordBreached         = "breached"
+	RulePasswordReused           = "reused"
+)
+
+// personalTokenMinLength évite de refuser un mot de passe pour un fragment de nom trop court
+const personalTokenMinLength = 4
+
+// PasswordPolicy décrit les exigences appliquées à tout nouveau mot de passe
+type PasswordPolicy struct {
+	MinLength int
+	// MaxLength borne le coût du hachage, 0 pour ne pas limiter
+	MaxLength int
+	// RequiredClasses est le nombre de classes (minuscules, majuscules, chiffres, symboles) exigées
+	RequiredClasses int
+	// MinStrength est le score minimal de EstimatePasswordStrength, de 0 à 4
+	MinStrength int
+	// Denylist contient les mots de passe courants, en minuscules
+	Denylist map[string]struct{}
+	// Breached recense les mots de passe divulgués, nil pour ne pas les vérifier
+	Breached BreachedPasswordChecker
+	// BreachedThreshold est le nombre d'apparitions dans des fuites à partir duquel le mot de passe est refusé
+	BreachedThreshold int
+}
+
+// PasswordViolation est une règle non respectée
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError liste toutes les règles non respectées, pour les afficher en une fois
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	rules := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		rules[i] = violation.Rule
+	}
+	return "password policy violated: " + strings.Join(rules, ", ")
+}
+
+// Check vérifie le mot de passe et retourne une *PasswordPolicyError s'il est refusé.
+// personal contient les informations de l'utilisateur (nom, email) à ne pas retrouver dedans.
+// Une autre erreur signale l'échec de la recherche dans les fuites : le mot de passe n'est
+// alors pas accepté.
+func (p *PasswordPolicy) Check(password string, personal ...string) error {
+	var violations []PasswordViolation
+	violate := func(rule string, format string, args ...interface{}) {
+		violations = append(violations, PasswordViolation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violate(RulePasswordMinLength, "Password must be at least %d characters long", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violate(RulePasswordMaxLength, "Password must be at most %d characters long", p.MaxLength)
+		return &PasswordPolicyError{Violations: violations}
+	}
+
+	if countCharacterClasses(password) < p.RequiredClasses {
+		violate(RulePasswordCharacterClasses, "Password must contain at least %d of: lowercase letters, uppercase letters, digits, symbols", p.RequiredClasses)
+	}
+
+	if _, common := p.Denylist[strings.ToLower(password)]; common {
+		violate(RulePasswordCommon, "Password is too common")
+	}
+
+	tokens := personalTokens(personal)
+	lower := strings.ToLower(password)
+	for _, token := range tokens {
+		if utf8.RuneCountInString(token) >= personalTokenMinLength && strings.Contains(lower, token) {
+			violate(RulePasswordPersonalInfo, "Password must not contain your name or email address")
+			break
+		}
+	}
+
+	if EstimatePasswordStrength(password, p.Denylist, tokens) < p.MinStrength {
+		violate(RulePasswordStrength, "Password is too easy to guess")
+	}
+
+	if p.Breached != nil {
+		occurrences, err := p.Breached.Occurrences(password)
+		if err != nil {
+			return err
+		}
+		if occurrences > 0 && occurrences >= p.BreachedThreshold {
+			violate(RulePasswordBreached, "Password has appeared %d times in known data breaches", occurrences)
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// LoadPasswordDenylist lit une liste de mots de passe courants, un par ligne.
+// Les lignes vides et celles commençant par # sont ignorées.
+func LoadPasswordDenylist(path string) (map[string]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	denylist := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		denylist[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return denylist, nil
+}
+
+func countCharacterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
+
+// personalTokens découpe le nom et l'email en mots, en minuscules (jean-pierre.dupont@x.fr
+// donne jean, pierre, dupont, jean-pierre.dupont, x, fr)
+func personalTokens(values []string) []string {
+	var tokens []string
+	for _, value := range values {
+		value = strings.ToLower(value)
+		if local, _, found := strings.Cut(value, "@"); found {
+			tokens = append(tokens, local)
+		}
+		tokens = append(tokens, strings.FieldsFunc(value, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})...)
+	}
+	return tokens
+}
@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Seuils de score, en log10 du nombre d'essais (mêmes bornes que zxcvbn)
+var strengthThresholds = []float64{3, 6, 8, 10}
+
+// Rangées de clavier qwerty et azerty parcourues par les motifs du type "azerty" ou "asdf"
+var keyboardRows = []string{
+	"1234567890",
+	"qwertyuiop", "asdfghjkl", "zxcvbnm",
+	"azertyuiop", "qsdfghjklm", "wxcvbn",
+}
+
+// Substitutions courantes (p@ssw0rd) annulées avant la recherche dans le dictionnaire
+var leetSubstitutions = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s", "!", "i",
+)
+
+// passwordMatch est un fragment prévisible du mot de passe et son coût en log10 d'essais
+type passwordMatch struct {
+	start, end int
+	guesses    float64
+}
+
+// EstimatePasswordStrength évalue la difficulté à deviner un mot de passe, à la manière de
+// zxcvbn : il est découpé en motifs prévisibles (mots connus, informations personnelles,
+// répétitions, suites, rangées de clavier, années), le découpage le plus favorable à
+// l'attaquant est retenu et son nombre d'essais est ramené à un score de 0 à 4.
+func EstimatePasswordStrength(password string, dictionary map[string]struct{}, personal []string) int {
+	runes := []rune(password)
+	if len(runes) == 0 {
+		return 0
+	}
+
+	matches := dictionaryMatches(runes, dictionary, personal)
+	matches = append(matches, repeatMatches(runes)...)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, keyboardMatches(runes)...)
+	matches = append(matches, yearMatches(runes)...)
+
+	byEnd := make(map[int][]passwordMatch)
+	for _, match := range matches {
+		byEnd[match.end] = append(byEnd[match.end], match)
+	}
+
+	// best[i] est le coût minimal des i premiers caractères ; un caractère hors motif
+	// coûte une recherche exhaustive sur les classes présentes dans le mot de passe
+	bruteforce := math.Log10(float64(characterSpace(runes)))
+	best := make([]float64, len(runes)+1)
+	for end := 1; end <= len(runes); end++ {
+		best[end] = best[end-1] + bruteforce
+		for _, match := range byEnd[end] {
+			if cost := best[match.start] + match.guesses; cost < best[end] {
+				best[end] = cost
+			}
+		}
+	}
+
+	score := 0
+	for _, threshold := range strengthThresholds {
+		if best[len(runes)] >= threshold {
+			score++
+		}
+	}
+	return score
+}
+
+// dictionaryMatches repère les mots de passe courants et les informations personnelles,
+// y compris sous forme de substitutions (p@ssw0rd) ou avec des majuscules
+func dictionaryMatches(runes []rune, dictionary map[string]struct{}, personal []string) []passwordMatch {
+	lower := []rune(strings.ToLower(string(runes)))
+	unleet := []rune(leetSubstitutions.Replace(string(lower)))
+	if len(unleet) != len(lower) {
+		unleet = lower
+	}
+
+	personalWords := make(map[string]struct{}, len(personal))
+	for _, word := range personal {
+		personalWords[word] = struct{}{}
+	}
+
+	// Le rang d'un mot dans la liste n'est pas connu : on compte la taille de la liste
+	dictionaryGuesses := math.Log10(math.Max(float64(len(dictionary)), 100))
+
+	var matches []passwordMatch
+	for start := 0; start < len(runes); start++ {
+		for end := start + 3; end <= len(runes) && end-start <= 32; end++ {
+			for _, candidate := range []string{string(lower[start:end]), string(unleet[start:end])} {
+				guesses := -1.0
+				if _, found := personalWords[candidate]; found {
+					guesses = 1
+				} else if _, found := dictionary[candidate]; found {
+					guesses = dictionaryGuesses
+				}
+				if guesses < 0 {
+					continue
+				}
+
+				if string(runes[start:end]) != string(lower[start:end]) {
+					guesses += math.Log10(2)
+				}
+				if candidate != string(lower[start:end]) {
+					guesses += math.Log10(2)
+				}
+				matches = append(matches, passwordMatch{start: start, end: end, guesses: guesses})
+			}
+		}
+	}
+	return matches
+}
+
+// repeatMatches repère les répétitions d'un bloc de 1 à 4 caractères (aaaa, abab, 123123)
+func repeatMatches(runes []rune) []passwordMatch {
+	var matches []passwordMatch
+	for start := 0; start < len(runes); start++ {
+		for size := 1; size <= 4 && start+2*size <= len(runes); size++ {
+			count := 1
+			for start+(count+1)*size <= len(runes) && string(runes[start+count*size:start+(count+1)*size]) == string(runes[start:start+size]) {
+				count++
+			}
+			if count < 2 || count*size < 3 {
+				continue
+			}
+			block := runes[start : start+size]
+			guesses := float64(size)*math.Log10(float64(characterSpace(block))) + math.Log10(float64(count))
+			matches = append(matches, passwordMatch{start: start, end: start + count*size, guesses: guesses})
+		}
+	}
+	return matches
+}
+
+// sequenceMatches repère les suites croissantes ou décroissantes (abcd, 4321)
+func sequenceMatches(runes []rune) []passwordMatch {
+	var matches []passwordMatch
+	for start := 0; start+2 < len(runes); {
+		delta := runes[start+1] - runes[start]
+		end := start + 1
+		for end+1 < len(runes) && runes[end+1]-runes[end] == delta {
+			end++
+		}
+		if (delta == 1 || delta == -1) && end-start+1 >= 3 {
+			// Une suite qui part d'une extrémité (a, z, 0, 1, 9) est la première essayée
+			first := unicode.ToLower(runes[start])
+			base := 26.0
+			if strings.ContainsRune("az019", first) {
+				base = 4
+			} else if unicode.IsDigit(first) {
+				base = 10
+			}
+			length := float64(end - start + 1)
+			guesses := math.Log10(base * length)
+			if delta < 0 {
+				guesses += math.Log10(2)
+			}
+			matches = append(matches, passwordMatch{start: start, end: end + 1, guesses: guesses})
+		}
+		start = end
+	}
+	return matches
+}
+
+// keyboardMatches repère les touches voisines d'une même rangée (qwerty, azerty, asdf)
+func keyboardMatches(runes []rune) []passwordMatch {
+	lower := []rune(strings.ToLower(string(runes)))
+
+	var matches []passwordMatch
+	for _, row := range keyboardRows {
+		keys := []rune(row)
+		position := make(map[rune]int, len(keys))
+		for i, key := range keys {
+			position[key] = i
+		}
+
+		for start := 0; start < len(lower); {
+			end := start
+			for end+1 < len(lower) {
+				current, inRow := position[lower[end]]
+				next, nextInRow := position[lower[end+1]]
+				if !inRow || !nextInRow || (next-current != 1 && current-next != 1) {
+					break
+				}
+				end++
+			}
+			if length := end - start + 1; length >= 4 {
+				guesses := math.Log10(float64(len(keys)) * float64(length) * 2)
+				matches = append(matches, passwordMatch{start: start, end: end + 1, guesses: guesses})
+			}
+			start = end + 1
+		}
+	}
+	return matches
+}
+
+// yearMatches repère les années récentes, souvent une date de naissance
+func yearMatches(runes []rune) []passwordMatch {
+	var matches []passwordMatch
+	for start := 0; start+4 <= len(runes); start++ {
+		year := string(runes[start : start+4])
+		if strings.Trim(year, "0123456789") == "" && year >= "1900" && year <= "2039" {
+			matches = append(matches, passwordMatch{start: start, end: start + 4, guesses: math.Log10(140)})
+		}
+	}
+	return matches
+}
+
+// characterSpace estime le nombre de caractères qu'un attaquant doit essayer par position
+func characterSpace(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	space := 0
+	for _, class := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.present {
+			space += class.size
+		}
+	}
+	return space
+}
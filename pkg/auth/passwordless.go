@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+)
+
+// MagicLinkLifetime est volontairement court : le lien donne accès au compte
+const MagicLinkLifetime = 15 * time.Minute
+
+// MagicLinkClaims contient les informations portées par un token de lien magique
+type MagicLinkClaims struct {
+	UserID    string
+	Email     string
+	TokenID   string
+	ExpiresAt time.Time
+}
+
+// GenerateMagicLinkToken génère un JWT de connexion sans mot de passe, sur le modèle
+// du token de réinitialisation. Le type magic+jwt empêche d'utiliser l'un pour l'autre.
+func GenerateMagicLinkToken(userID string, email string, tokens *TokenConfig) (string, *MagicLinkClaims, error) {
+	magicLink := &MagicLinkClaims{
+		UserID:    userID,
+		Email:     email,
+		TokenID:   uuid.New().String(),
+		ExpiresAt: time.Now().Add(MagicLinkLifetime),
+	}
+
+	claims := jwt.MapClaims{
+		"sub":   userID,
+		"email": email,
+		"jti":   magicLink.TokenID,
+		"aud":   tokens.Issuer,
+	}
+
+	tokenString, err := tokens.sign(TypeMagicLink, claims, MagicLinkLifetime)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return tokenString, magicLink, nil
+}
+
+// ValidateMagicLinkToken valide un token de lien magique et retourne ses claims.
+// L'usage unique est à la charge de l'appelant (jti).
+func ValidateMagicLinkToken(tokenString string, tokens *TokenConfig) (*MagicLinkClaims, error) {
+	claims, err := tokens.parse(tokenString, TypeMagicLink, tokens.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, ok := claims["sub"].(string)
+	if !ok || userID == "" {
+		return nil, errors.New("invalid token claims: missing sub")
+	}
+
+	email, ok := claims["email"].(string)
+	if !ok || email == "" {
+		return nil, errors.New("invalid token claims: missing email")
+	}
+
+	tokenID, ok := claims["jti"].(string)
+	if !ok || tokenID == "" {
+		return nil, errors.New("invalid token claims: missing jti")
+	}
+
+	exp, _ := claims["exp"].(float64)
+
+	return &MagicLinkClaims{
+		UserID:    userID,
+		Email:     email,
+		TokenID:   tokenID,
+		ExpiresAt: time.Unix(int64(exp), 0),
+	}, nil
+}
+
+// GenerateLoginCode génère un code numérique à 6 chiffres uniformément réparti
+func GenerateLoginCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(totpModulus))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"regexp"
+)
+
+// PKCEMethodS256 est la seule méthode PKCE acceptée (RFC 7636), "plain" est refusée
+const PKCEMethodS256 = "S256"
+
+// Un code_verifier fait 43 à 128 caractères non réservés (RFC 7636 section 4.1)
+var codeVerifierPattern = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+// IsValidCodeChallenge vérifie le format d'un code_challenge S256 (SHA-256 encodé en base64url)
+func IsValidCodeChallenge(challenge string) bool {
+	decoded, err := base64.RawURLEncoding.DecodeString(challenge)
+	return err == nil && len(decoded) == sha256.Size
+}
+
+// VerifyPKCE vérifie que le code_verifier correspond au code_challenge S256
+func VerifyPKCE(verifier string, challenge string) bool {
+	if !codeVerifierPattern.MatchString(verifier) {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
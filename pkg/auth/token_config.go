@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+)
+
+// Types de tokens portés dans l'en-tête typ (RFC 8725 section 3.11).
+// Chaque validateur exige son type, un token ne peut donc pas servir à autre chose.
+const (
+	TypeAccessToken  = "at+jwt"
+	TypeRefreshToken = "refresh+jwt"
+	TypeResetToken   = "reset+jwt"
+	TypeMFAToken     = "mfa+jwt"
+	TypeWebAuthn     = "webauthn+jwt"
+	TypeMagicLink    = "magic+jwt"
+	TypeVerifyEmail  = "verify+jwt"
+	TypeUnlock       = "unlock+jwt"
+)
+
+var (
+	ErrInvalidTokenType = errors.New("invalid token type")
+	ErrInvalidIssuer    = errors.New("invalid token issuer")
+	ErrInvalidAudience  = errors.New("invalid token audience")
+)
+
+// TokenConfig regroupe les clés et les paramètres communs à tous les tokens émis
+type TokenConfig struct {
+	Keys KeySource
+	// Issuer est placé dans le claim iss et exigé à la validation
+	Issuer string
+	// Audience est placée dans le claim aud des tokens d'accès (un service par entrée)
+	Audience []string
+	// AcceptedAudience est l'audience que ce service exige dans les tokens d'accès
+	AcceptedAudience string
+	// Leeway est la tolérance de décalage d'horloge appliquée à exp, nbf et iat
+	Leeway time.Duration
+}
+
+// sign complète les claims communs (iss, iat, nbf, jti) et signe le token avec la clé courante
+func (c *TokenConfig) sign(tokenType string, claims jwt.MapClaims, ttl time.Duration) (string, error) {
+	return c.signWith(c.Keys.SigningKey(), tokenType, claims, ttl)
+}
+
+// signWith signe avec une clé déjà choisie, pour les claims qui dépendent de son algorithme
+func (c *TokenConfig) signWith(key *SigningKey, tokenType string, claims jwt.MapClaims, ttl time.Duration) (string, error) {
+	if key == nil {
+		return "", ErrKeyNotFound
+	}
+
+	now := time.Now()
+	claims["iss"] = c.Issuer
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+	if _, ok := claims["jti"]; !ok {
+		claims["jti"] = uuid.New().String()
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	token.Header["typ"] = tokenType
+	return token.SignedString(key.PrivateKey)
+}
+
+// parse vérifie la signature, le type, l'émetteur, l'audience (si non vide)
+// et les dates du token en appliquant la tolérance d'horloge
+func (c *TokenConfig) parse(tokenString string, tokenType string, audience string) (jwt.MapClaims, error) {
+	claims, typ, err := c.parseSigned(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ != tokenType {
+		return nil, ErrInvalidTokenType
+	}
+
+	if iss, _ := claims["iss"].(string); iss != c.Issuer {
+		return nil, ErrInvalidIssuer
+	}
+
+	if audience != "" && !hasAudience(claims, audience) {
+		return nil, ErrInvalidAudience
+	}
+
+	if err := validateTimes(claims, c.Leeway); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// parseSigned vérifie uniquement la signature et retourne les claims avec le type (en-tête typ)
+func (c *TokenConfig) parseSigned(tokenString string) (jwt.MapClaims, string, error) {
+	parser := &jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(tokenString, c.keyFunc())
+	if err != nil {
+		return nil, "", err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, "", errors.New("invalid token")
+	}
+
+	typ, _ := token.Header["typ"].(string)
+	return claims, typ, nil
+}
+
+// keyFunc résout la clé de vérification à partir du kid et refuse tout changement d'algorithme
+func (c *TokenConfig) keyFunc() jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, err := c.Keys.VerificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key.PublicKey, nil
+	}
+}
+
+// validateTimes vérifie exp, nbf et iat avec une tolérance de décalage d'horloge
+func validateTimes(claims jwt.MapClaims, leeway time.Duration) error {
+	now := time.Now()
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("invalid claim: exp")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(leeway)) {
+		return errors.New("token is expired")
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(nbf), 0).Add(-leeway)) {
+		return errors.New("token is not valid yet")
+	}
+
+	if iat, ok := claims["iat"].(float64); ok && now.Before(time.Unix(int64(iat), 0).Add(-leeway)) {
+		return errors.New("token used before issued")
+	}
+
+	return nil
+}
+
+// hasAudience accepte un claim aud sous forme de chaîne ou de liste (RFC 7519 section 4.1.3)
+func hasAudience(claims jwt.MapClaims, audience string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, value := range aud {
+			if value == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,385 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+)
+
+// Drapeaux des données d'authentificateur (WebAuthn Level 3 section 6.1)
+const (
+	webauthnFlagUserPresent    = 0x01
+	webauthnFlagUserVerified   = 0x04
+	webauthnFlagBackupEligible = 0x08
+	webauthnFlagBackupState    = 0x10
+	webauthnFlagAttestedData   = 0x40
+	webauthnFlagExtensionData  = 0x80
+)
+
+// Types de cérémonie portés par clientDataJSON
+const (
+	webauthnTypeCreate = "webauthn.create"
+	webauthnTypeGet    = "webauthn.get"
+)
+
+// maxCredentialIDLength est la taille maximale d'un identifiant de credential (section 5.8.3)
+const maxCredentialIDLength = 1023
+
+// oidFIDOAAGUID est l'extension de certificat portant l'AAGUID de l'authentificateur
+var oidFIDOAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+var (
+	ErrInvalidClientData        = errors.New("invalid client data")
+	ErrChallengeMismatch        = errors.New("challenge mismatch")
+	ErrOriginNotAllowed         = errors.New("origin not allowed")
+	ErrInvalidAuthenticatorData = errors.New("invalid authenticator data")
+	ErrRPIDMismatch             = errors.New("relying party id mismatch")
+	ErrUserNotPresent           = errors.New("user presence required")
+	ErrUserNotVerified          = errors.New("user verification required")
+	ErrUnsupportedAttestation   = errors.New("unsupported attestation format")
+	ErrInvalidAttestation       = errors.New("invalid attestation statement")
+)
+
+// WebAuthnConfig décrit la Relying Party : son identifiant (domaine) et les origines
+// autorisées à lancer les cérémonies. La vérification de l'utilisateur (PIN, biométrie)
+// est toujours exigée puisque le credential remplace le mot de passe.
+type WebAuthnConfig struct {
+	RPID    string
+	RPName  string
+	Origins []string
+}
+
+// AuthenticatorData est la structure signée par l'authentificateur (section 6.1)
+type AuthenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+	// Présents uniquement lors de l'enregistrement (drapeau AT)
+	AAGUID        []byte
+	CredentialID  []byte
+	CredentialKey *COSEPublicKey
+	// Forme COSE de la clé, conservée telle quelle pour les vérifications suivantes
+	RawCredentialKey []byte
+}
+
+func (d *AuthenticatorData) UserPresent() bool    { return d.Flags&webauthnFlagUserPresent != 0 }
+func (d *AuthenticatorData) UserVerified() bool   { return d.Flags&webauthnFlagUserVerified != 0 }
+func (d *AuthenticatorData) BackupEligible() bool { return d.Flags&webauthnFlagBackupEligible != 0 }
+func (d *AuthenticatorData) BackupState() bool    { return d.Flags&webauthnFlagBackupState != 0 }
+
+// RegisteredCredential est le résultat d'une cérémonie d'enregistrement vérifiée
+type RegisteredCredential struct {
+	ID                []byte
+	PublicKey         []byte
+	Algorithm         int64
+	SignCount         uint32
+	AAGUID            []byte
+	BackupEligible    bool
+	BackupState       bool
+	AttestationFormat string
+}
+
+// AssertionResult est le résultat d'une cérémonie d'authentification vérifiée
+type AssertionResult struct {
+	SignCount   uint32
+	BackupState bool
+}
+
+type collectedClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// NewWebAuthnChallenge génère un challenge aléatoire de 256 bits encodé en base64url
+func NewWebAuthnChallenge() (string, error) {
+	buffer := make([]byte, 32)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
+
+// VerifyRegistration vérifie la réponse d'un navigateur à navigator.credentials.create()
+// (section 7.1). Les formats d'attestation "none" et "packed" sont acceptés.
+func (c *WebAuthnConfig) VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte) (*RegisteredCredential, error) {
+	if err := c.verifyClientData(clientDataJSON, webauthnTypeCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	value, rest, err := decodeCBOR(attestationObject)
+	if err != nil || len(rest) != 0 {
+		return nil, ErrInvalidAttestation
+	}
+	object, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrInvalidAttestation
+	}
+	format, _ := object["fmt"].(string)
+	statement, _ := object["attStmt"].(map[interface{}]interface{})
+	rawAuthData, _ := object["authData"].([]byte)
+	if format == "" || statement == nil || rawAuthData == nil {
+		return nil, ErrInvalidAttestation
+	}
+
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.verifyAuthenticatorData(authData); err != nil {
+		return nil, err
+	}
+	if authData.CredentialKey == nil {
+		return nil, ErrInvalidAuthenticatorData
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), rawAuthData...), clientDataHash[:]...)
+
+	switch format {
+	case "none":
+		if len(statement) != 0 {
+			return nil, ErrInvalidAttestation
+		}
+	case "packed":
+		if err := verifyPackedAttestation(statement, signed, authData); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupportedAttestation
+	}
+
+	return &RegisteredCredential{
+		ID:                authData.CredentialID,
+		PublicKey:         authData.RawCredentialKey,
+		Algorithm:         authData.CredentialKey.Algorithm,
+		SignCount:         authData.SignCount,
+		AAGUID:            authData.AAGUID,
+		BackupEligible:    authData.BackupEligible(),
+		BackupState:       authData.BackupState(),
+		AttestationFormat: format,
+	}, nil
+}
+
+// VerifyAssertion vérifie la réponse d'un navigateur à navigator.credentials.get()
+// (section 7.2) avec la clé COSE enregistrée. Le contrôle du compteur de signatures
+// est laissé à l'appelant, qui connaît la dernière valeur stockée.
+func (c *WebAuthnConfig) VerifyAssertion(challenge string, publicKey, clientDataJSON, authenticatorData, signature []byte) (*AssertionResult, error) {
+	key, rest, err := ParseCOSEKey(publicKey)
+	if err != nil || len(rest) != 0 {
+		return nil, ErrUnsupportedCOSEKey
+	}
+
+	if err := c.verifyClientData(clientDataJSON, webauthnTypeGet, challenge); err != nil {
+		return nil, err
+	}
+
+	authData, err := ParseAuthenticatorData(authenticatorData)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.verifyAuthenticatorData(authData); err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authenticatorData...), clientDataHash[:]...)
+	if err := key.Verify(signed, signature); err != nil {
+		return nil, err
+	}
+
+	return &AssertionResult{
+		SignCount:   authData.SignCount,
+		BackupState: authData.BackupState(),
+	}, nil
+}
+
+// SignCountRegressed indique si le compteur reçu n'a pas augmenté depuis la dernière
+// assertion, ce qui signale un authentificateur cloné (section 6.1.1). Les clés
+// synchronisées renvoient toujours 0 et ne sont pas concernées.
+func SignCountRegressed(stored, received uint32) bool {
+	return (received != 0 || stored != 0) && received <= stored
+}
+
+// ParseAuthenticatorData décode rpIdHash, les drapeaux, le compteur et, si présentes,
+// les données du credential attesté et les extensions
+func ParseAuthenticatorData(data []byte) (*AuthenticatorData, error) {
+	if len(data) < 37 {
+		return nil, ErrInvalidAuthenticatorData
+	}
+
+	authData := &AuthenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if authData.Flags&webauthnFlagAttestedData != 0 {
+		if len(rest) < 18 {
+			return nil, ErrInvalidAuthenticatorData
+		}
+		authData.AAGUID = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || idLength > maxCredentialIDLength || len(rest) < idLength {
+			return nil, ErrInvalidAuthenticatorData
+		}
+		authData.CredentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		key, remaining, err := ParseCOSEKey(rest)
+		if err != nil {
+			return nil, err
+		}
+		authData.CredentialKey = key
+		authData.RawCredentialKey = rest[:len(rest)-len(remaining)]
+		rest = remaining
+	}
+
+	if authData.Flags&webauthnFlagExtensionData != 0 {
+		extensions, remaining, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, ErrInvalidAuthenticatorData
+		}
+		if _, ok := extensions.(map[interface{}]interface{}); !ok {
+			return nil, ErrInvalidAuthenticatorData
+		}
+		rest = remaining
+	}
+
+	if len(rest) != 0 {
+		return nil, ErrInvalidAuthenticatorData
+	}
+	return authData, nil
+}
+
+// verifyClientData contrôle le type de cérémonie, le challenge et l'origine
+func (c *WebAuthnConfig) verifyClientData(clientDataJSON []byte, ceremonyType string, challenge string) error {
+	var clientData collectedClientData
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return ErrInvalidClientData
+	}
+	if clientData.Type != ceremonyType {
+		return ErrInvalidClientData
+	}
+	if subtle.ConstantTimeCompare([]byte(clientData.Challenge), []byte(challenge)) != 1 {
+		return ErrChallengeMismatch
+	}
+	// Les cérémonies lancées depuis une iframe d'un autre site sont refusées
+	if clientData.CrossOrigin {
+		return ErrOriginNotAllowed
+	}
+	for _, origin := range c.Origins {
+		if clientData.Origin == origin {
+			return nil
+		}
+	}
+	return ErrOriginNotAllowed
+}
+
+// verifyAuthenticatorData contrôle le RP ID et exige la présence et la vérification de l'utilisateur
+func (c *WebAuthnConfig) verifyAuthenticatorData(authData *AuthenticatorData) error {
+	expected := sha256.Sum256([]byte(c.RPID))
+	if subtle.ConstantTimeCompare(authData.RPIDHash, expected[:]) != 1 {
+		return ErrRPIDMismatch
+	}
+	if !authData.UserPresent() {
+		return ErrUserNotPresent
+	}
+	if !authData.UserVerified() {
+		return ErrUserNotVerified
+	}
+	// BS sans BE est une combinaison invalide (section 6.1.3)
+	if authData.BackupState() && !authData.BackupEligible() {
+		return ErrInvalidAuthenticatorData
+	}
+	return nil
+}
+
+// verifyPackedAttestation vérifie une attestation "packed" (section 8.2). Avec x5c la
+// signature est faite par le certificat d'attestation, sinon il s'agit d'une auto-attestation
+// signée par la clé du credential. La chaîne de certificats n'est pas rattachée à une
+// autorité de confiance : aucun référentiel de métadonnées FIDO n'est configuré.
+func verifyPackedAttestation(statement map[interface{}]interface{}, signed []byte, authData *AuthenticatorData) error {
+	alg, ok := statement["alg"].(int64)
+	if !ok {
+		return ErrInvalidAttestation
+	}
+	signature, ok := statement["sig"].([]byte)
+	if !ok {
+		return ErrInvalidAttestation
+	}
+
+	chain, hasCertificates := statement["x5c"].([]interface{})
+	if !hasCertificates {
+		if _, present := statement["x5c"]; present {
+			return ErrInvalidAttestation
+		}
+		if alg != authData.CredentialKey.Algorithm {
+			return ErrInvalidAttestation
+		}
+		if err := authData.CredentialKey.Verify(signed, signature); err != nil {
+			return ErrInvalidAttestation
+		}
+		return nil
+	}
+
+	if len(chain) == 0 {
+		return ErrInvalidAttestation
+	}
+	der, ok := chain[0].([]byte)
+	if !ok {
+		return ErrInvalidAttestation
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return ErrInvalidAttestation
+	}
+	if err := verifyWithAlgorithm(alg, certificate.PublicKey, signed, signature); err != nil {
+		return ErrInvalidAttestation
+	}
+	return verifyPackedCertificate(certificate, authData.AAGUID)
+}
+
+// verifyPackedCertificate applique les exigences du certificat d'attestation (section 8.2.1)
+func verifyPackedCertificate(certificate *x509.Certificate, aaguid []byte) error {
+	if certificate.Version != 3 {
+		return ErrInvalidAttestation
+	}
+	if len(certificate.Subject.OrganizationalUnit) != 1 ||
+		certificate.Subject.OrganizationalUnit[0] != "Authenticator Attestation" ||
+		len(certificate.Subject.Country) == 0 ||
+		len(certificate.Subject.Organization) == 0 ||
+		certificate.Subject.CommonName == "" {
+		return ErrInvalidAttestation
+	}
+	if !certificate.BasicConstraintsValid || certificate.IsCA {
+		return ErrInvalidAttestation
+	}
+
+	for _, extension := range certificate.Extensions {
+		if !extension.Id.Equal(oidFIDOAAGUID) {
+			continue
+		}
+		if extension.Critical {
+			return ErrInvalidAttestation
+		}
+		var value []byte
+		if _, err := asn1.Unmarshal(extension.Value, &value); err != nil {
+			return ErrInvalidAttestation
+		}
+		if !bytes.Equal(value, aaguid) {
+			return ErrInvalidAttestation
+		}
+	}
+	return nil
+}
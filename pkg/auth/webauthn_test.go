@@ -0,0 +1,587 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+)
+
+const (
+	testRPID   = "auth.example.com"
+	testOrigin = "https://auth.example.com"
+)
+
+var testRelyingParty = &WebAuthnConfig{
+	RPID:    testRPID,
+	RPName:  "Example",
+	Origins: []string{testOrigin},
+}
+
+// softAuthenticator est un authentificateur logiciel ES256 qui produit les mêmes
+// structures qu'une clé de sécurité : authData, attestationObject et signatures DER
+type softAuthenticator struct {
+	rpID         string
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	aaguid       []byte
+	signCount    uint32
+	flags        byte
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("génération de la clé: %v", err)
+	}
+	credentialID := make([]byte, 16)
+	aaguid := make([]byte, 16)
+	rand.Read(credentialID)
+	rand.Read(aaguid)
+	return &softAuthenticator{
+		rpID:         testRPID,
+		key:          key,
+		credentialID: credentialID,
+		aaguid:       aaguid,
+		flags:        webauthnFlagUserPresent | webauthnFlagUserVerified,
+	}
+}
+
+func (a *softAuthenticator) coseKey() []byte {
+	return encodeCOSEEC2(&a.key.PublicKey)
+}
+
+// authenticatorData incrémente le compteur puis construit authData, avec les données
+// du credential attesté lors de l'enregistrement
+func (a *softAuthenticator) authenticatorData(attested bool) []byte {
+	a.signCount++
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append([]byte(nil), rpIDHash[:]...)
+	flags := a.flags
+	if attested {
+		flags |= webauthnFlagAttestedData
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if attested {
+		data = append(data, a.aaguid...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.coseKey()...)
+	}
+	return data
+}
+
+func (a *softAuthenticator) sign(t *testing.T, authData, clientDataJSON []byte) []byte {
+	t.Helper()
+	return signES256(t, a.key, authData, clientDataJSON)
+}
+
+// register répond à navigator.credentials.create() avec l'attestation "none"
+func (a *softAuthenticator) register(t *testing.T, challenge, origin string) ([]byte, []byte) {
+	t.Helper()
+	clientDataJSON := clientData(t, webauthnTypeCreate, challenge, origin)
+	attestationObject := encodeCBOR(map[interface{}]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": a.authenticatorData(true),
+	})
+	return clientDataJSON, attestationObject
+}
+
+// assert répond à navigator.credentials.get()
+func (a *softAuthenticator) assert(t *testing.T, challenge, origin string) ([]byte, []byte, []byte) {
+	t.Helper()
+	clientDataJSON := clientData(t, webauthnTypeGet, challenge, origin)
+	authData := a.authenticatorData(false)
+	return clientDataJSON, authData, a.sign(t, authData, clientDataJSON)
+}
+
+func clientData(t *testing.T, ceremonyType, challenge, origin string) []byte {
+	t.Helper()
+	data, err := json.Marshal(collectedClientData{Type: ceremonyType, Challenge: challenge, Origin: origin})
+	if err != nil {
+		t.Fatalf("encodage de clientDataJSON: %v", err)
+	}
+	return data
+}
+
+// signES256 signe authData || sha256(clientDataJSON) comme le fait un authentificateur
+func signES256(t *testing.T, key *ecdsa.PrivateKey, authData, clientDataJSON []byte) []byte {
+	t.Helper()
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("signature: %v", err)
+	}
+	return signature
+}
+
+func newChallenge(t *testing.T) string {
+	t.Helper()
+	challenge, err := NewWebAuthnChallenge()
+	if err != nil {
+		t.Fatalf("génération du challenge: %v", err)
+	}
+	return challenge
+}
+
+// attestationCertificate crée un certificat d'attestation auto-signé conforme à la section 8.2.1
+func attestationCertificate(t *testing.T, key *ecdsa.PrivateKey, subject pkix.Name, aaguid []byte) []byte {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               subject,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+	}
+	if aaguid != nil {
+		value, err := asn1.Marshal(aaguid)
+		if err != nil {
+			t.Fatalf("encodage de l'AAGUID: %v", err)
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: oidFIDOAAGUID, Value: value}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("création du certificat: %v", err)
+	}
+	return der
+}
+
+func attestationSubject() pkix.Name {
+	return pkix.Name{
+		Country:            []string{"FR"},
+		Organization:       []string{"Example Authenticators"},
+		OrganizationalUnit: []string{"Authenticator Attestation"},
+		CommonName:         "Example Attestation",
+	}
+}
+
+func TestVerifyRegistrationNone(t *testing.T) {
+	authenticator := newSoftAuthenticator(t)
+	challenge := newChallenge(t)
+	clientDataJSON, attestationObject := authenticator.register(t, challenge, testOrigin)
+
+	credential, err := testRelyingParty.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+	if string(credential.ID) != string(authenticator.credentialID) {
+		t.Errorf("ID = %x, attendu %x", credential.ID, authenticator.credentialID)
+	}
+	if string(credential.PublicKey) != string(authenticator.coseKey()) {
+		t.Errorf("la clé COSE enregistrée diffère de celle de l'authentificateur")
+	}
+	if credential.Algorithm != COSEAlgES256 || credential.SignCount != 1 || credential.AttestationFormat != "none" {
+		t.Errorf("credential inattendu: %+v", credential)
+	}
+	if string(credential.AAGUID) != string(authenticator.aaguid) {
+		t.Errorf("AAGUID = %x, attendu %x", credential.AAGUID, authenticator.aaguid)
+	}
+}
+
+func TestVerifyRegistrationNoneRejectsStatement(t *testing.T) {
+	authenticator := newSoftAuthenticator(t)
+	challenge := newChallenge(t)
+	clientDataJSON := clientData(t, webauthnTypeCreate, challenge, testOrigin)
+	attestationObject := encodeCBOR(map[interface{}]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[interface{}]interface{}{"alg": COSEAlgES256},
+		"authData": authenticator.authenticatorData(true),
+	})
+
+	if _, err := testRelyingParty.VerifyRegistration(challenge, clientDataJSON, attestationObject); err != ErrInvalidAttestation {
+		t.Fatalf("err = %v, attendu %v", err, ErrInvalidAttestation)
+	}
+}
+
+func TestVerifyRegistrationPackedSelfAttestation(t *testing.T) {
+	tests := []struct {
+		name    string
+		alg     int64
+		tamper  bool
+		wantErr error
+	}{
+		{name: "valide", alg: COSEAlgES256},
+		{name: "algorithme différent de la clé", alg: COSEAlgRS256, wantErr: ErrInvalidAttestation},
+		{name: "signature altérée", alg: COSEAlgES256, tamper: true, wantErr: ErrInvalidAttestation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newSoftAuthenticator(t)
+			challenge := newChallenge(t)
+			clientDataJSON := clientData(t, webauthnTypeCreate, challenge, testOrigin)
+			authData := authenticator.authenticatorData(true)
+			signature := authenticator.sign(t, authData, clientDataJSON)
+			if tt.tamper {
+				signature[len(signature)-1] ^= 0xff
+			}
+			attestationObject := encodeCBOR(map[interface{}]interface{}{
+				"fmt":      "packed",
+				"attStmt":  map[interface{}]interface{}{"alg": tt.alg, "sig": signature},
+				"authData": authData,
+			})
+
+			credential, err := testRelyingParty.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, attendu %v", err, tt.wantErr)
+			}
+			if err == nil && credential.AttestationFormat != "packed" {
+				t.Errorf("AttestationFormat = %q, attendu packed", credential.AttestationFormat)
+			}
+		})
+	}
+}
+
+func TestVerifyRegistrationPackedCertificate(t *testing.T) {
+	withoutOU := attestationSubject()
+	withoutOU.OrganizationalUnit = nil
+
+	tests := []struct {
+		name        string
+		subject     pkix.Name
+		otherAAGUID bool
+		omitAAGUID  bool
+		wantErr     error
+	}{
+		{name: "valide", subject: attestationSubject()},
+		{name: "sans extension AAGUID", subject: attestationSubject(), omitAAGUID: true},
+		{name: "AAGUID différent", subject: attestationSubject(), otherAAGUID: true, wantErr: ErrInvalidAttestation},
+		{name: "OU manquante", subject: withoutOU, wantErr: ErrInvalidAttestation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newSoftAuthenticator(t)
+			attestationKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			if err != nil {
+				t.Fatalf("génération de la clé d'attestation: %v", err)
+			}
+			aaguid := authenticator.aaguid
+			switch {
+			case tt.omitAAGUID:
+				aaguid = nil
+			case tt.otherAAGUID:
+				aaguid = make([]byte, 16)
+			}
+			certificate := attestationCertificate(t, attestationKey, tt.subject, aaguid)
+
+			challenge := newChallenge(t)
+			clientDataJSON := clientData(t, webauthnTypeCreate, challenge, testOrigin)
+			authData := authenticator.authenticatorData(true)
+			attestationObject := encodeCBOR(map[interface{}]interface{}{
+				"fmt": "packed",
+				"attStmt": map[interface{}]interface{}{
+					"alg": COSEAlgES256,
+					"sig": signES256(t, attestationKey, authData, clientDataJSON),
+					"x5c": []interface{}{certificate},
+				},
+				"authData": authData,
+			})
+
+			if _, err := testRelyingParty.VerifyRegistration(challenge, clientDataJSON, attestationObject); err != tt.wantErr {
+				t.Fatalf("err = %v, attendu %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyRegistrationPackedCertificateRejectsCredentialSignature(t *testing.T) {
+	authenticator := newSoftAuthenticator(t)
+	attestationKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("génération de la clé d'attestation: %v", err)
+	}
+	certificate := attestationCertificate(t, attestationKey, attestationSubject(), authenticator.aaguid)
+
+	challenge := newChallenge(t)
+	clientDataJSON := clientData(t, webauthnTypeCreate, challenge, testOrigin)
+	authData := authenticator.authenticatorData(true)
+	// Avec x5c, la signature doit venir du certificat et non de la clé du credential
+	attestationObject := encodeCBOR(map[interface{}]interface{}{
+		"fmt": "packed",
+		"attStmt": map[interface{}]interface{}{
+			"alg": COSEAlgES256,
+			"sig": authenticator.sign(t, authData, clientDataJSON),
+			"x5c": []interface{}{certificate},
+		},
+		"authData": authData,
+	})
+
+	if _, err := testRelyingParty.VerifyRegistration(challenge, clientDataJSON, attestationObject); err != ErrInvalidAttestation {
+		t.Fatalf("err = %v, attendu %v", err, ErrInvalidAttestation)
+	}
+}
+
+func TestVerifyRegistrationUnsupportedFormat(t *testing.T) {
+	authenticator := newSoftAuthenticator(t)
+	challenge := newChallenge(t)
+	clientDataJSON := clientData(t, webauthnTypeCreate, challenge, testOrigin)
+	attestationObject := encodeCBOR(map[interface{}]interface{}{
+		"fmt":      "tpm",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": authenticator.authenticatorData(true),
+	})
+
+	if _, err := testRelyingParty.VerifyRegistration(challenge, clientDataJSON, attestationObject); err != ErrUnsupportedAttestation {
+		t.Fatalf("err = %v, attendu %v", err, ErrUnsupportedAttestation)
+	}
+}
+
+func TestVerifyRegistrationRejects(t *testing.T) {
+	tests := []struct {
+		name      string
+		rpID      string
+		origin    string
+		challenge string
+		wantErr   error
+	}{
+		{name: "origine non autorisée", rpID: testRPID, origin: "https://evil.example.com", wantErr: ErrOriginNotAllowed},
+		{name: "RP ID différent", rpID: "evil.example.com", origin: testOrigin, wantErr: ErrRPIDMismatch},
+		{name: "challenge différent", rpID: testRPID, origin: testOrigin, challenge: "autre", wantErr: ErrChallengeMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newSoftAuthenticator(t)
+			authenticator.rpID = tt.rpID
+			challenge := newChallenge(t)
+			answered := challenge
+			if tt.challenge != "" {
+				answered = tt.challenge
+			}
+			clientDataJSON, attestationObject := authenticator.register(t, answered, tt.origin)
+
+			if _, err := testRelyingParty.VerifyRegistration(challenge, clientDataJSON, attestationObject); err != tt.wantErr {
+				t.Fatalf("err = %v, attendu %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	authenticator := newSoftAuthenticator(t)
+	challenge := newChallenge(t)
+	clientDataJSON, attestationObject := authenticator.register(t, challenge, testOrigin)
+	credential, err := testRelyingParty.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+
+	challenge = newChallenge(t)
+	clientDataJSON, authData, signature := authenticator.assert(t, challenge, testOrigin)
+	result, err := testRelyingParty.VerifyAssertion(challenge, credential.PublicKey, clientDataJSON, authData, signature)
+	if err != nil {
+		t.Fatalf("VerifyAssertion: %v", err)
+	}
+	if result.SignCount != 2 {
+		t.Errorf("SignCount = %d, attendu 2", result.SignCount)
+	}
+	if SignCountRegressed(credential.SignCount, result.SignCount) {
+		t.Errorf("un compteur qui augmente ne doit pas être signalé")
+	}
+}
+
+func TestVerifyAssertionSignCountRegression(t *testing.T) {
+	authenticator := newSoftAuthenticator(t)
+	challenge := newChallenge(t)
+	clientDataJSON, attestationObject := authenticator.register(t, challenge, testOrigin)
+	credential, err := testRelyingParty.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		t.Fatalf("VerifyRegistration: %v", err)
+	}
+
+	// Un clone de l'authentificateur repart du compteur copié
+	clone := *authenticator
+	stored := credential.SignCount
+	for i := 0; i < 3; i++ {
+		challenge = newChallenge(t)
+		clientDataJSON, authData, signature := authenticator.assert(t, challenge, testOrigin)
+		result, err := testRelyingParty.VerifyAssertion(challenge, credential.PublicKey, clientDataJSON, authData, signature)
+		if err != nil {
+			t.Fatalf("VerifyAssertion: %v", err)
+		}
+		if SignCountRegressed(stored, result.SignCount) {
+			t.Fatalf("compteur %d signalé après %d", result.SignCount, stored)
+		}
+		stored = result.SignCount
+	}
+
+	challenge = newChallenge(t)
+	clientDataJSON, authData, signature := clone.assert(t, challenge, testOrigin)
+	result, err := testRelyingParty.VerifyAssertion(challenge, credential.PublicKey, clientDataJSON, authData, signature)
+	if err != nil {
+		t.Fatalf("la signature du clone est valide, VerifyAssertion: %v", err)
+	}
+	if !SignCountRegressed(stored, result.SignCount) {
+		t.Fatalf("compteur %d après %d non signalé", result.SignCount, stored)
+	}
+}
+
+func TestSignCountRegressed(t *testing.T) {
+	tests := []struct {
+		stored   uint32
+		received uint32
+		want     bool
+	}{
+		{stored: 0, received: 0, want: false},
+		{stored: 0, received: 1, want: false},
+		{stored: 5, received: 6, want: false},
+		{stored: 5, received: 5, want: true},
+		{stored: 5, received: 4, want: true},
+		{stored: 5, received: 0, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := SignCountRegressed(tt.stored, tt.received); got != tt.want {
+			t.Errorf("SignCountRegressed(%d, %d) = %v, attendu %v", tt.stored, tt.received, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyAssertionRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(authenticator *softAuthenticator)
+		answer  func(t *testing.T, authenticator *softAuthenticator, challenge string) ([]byte, []byte, []byte)
+		wantErr error
+	}{
+		{
+			name: "origine non autorisée",
+			answer: func(t *testing.T, authenticator *softAuthenticator, challenge string) ([]byte, []byte, []byte) {
+				return authenticator.assert(t, challenge, "https://evil.example.com")
+			},
+			wantErr: ErrOriginNotAllowed,
+		},
+		{
+			name:    "RP ID différent",
+			modify:  func(authenticator *softAuthenticator) { authenticator.rpID = "evil.example.com" },
+			wantErr: ErrRPIDMismatch,
+		},
+		{
+			name: "challenge différent",
+			answer: func(t *testing.T, authenticator *softAuthenticator, challenge string) ([]byte, []byte, []byte) {
+				return authenticator.assert(t, newChallenge(t), testOrigin)
+			},
+			wantErr: ErrChallengeMismatch,
+		},
+		{
+			name: "type de cérémonie d'enregistrement",
+			answer: func(t *testing.T, authenticator *softAuthenticator, challenge string) ([]byte, []byte, []byte) {
+				clientDataJSON := clientData(t, webauthnTypeCreate, challenge, testOrigin)
+				authData := authenticator.authenticatorData(false)
+				return clientDataJSON, authData, authenticator.sign(t, authData, clientDataJSON)
+			},
+			wantErr: ErrInvalidClientData,
+		},
+		{
+			name: "iframe d'une autre origine",
+			answer: func(t *testing.T, authenticator *softAuthenticator, challenge string) ([]byte, []byte, []byte) {
+				clientDataJSON := []byte(`{"type":"webauthn.get","challenge":"` + challenge + `","origin":"` + testOrigin + `","crossOrigin":true}`)
+				authData := authenticator.authenticatorData(false)
+				return clientDataJSON, authData, authenticator.sign(t, authData, clientDataJSON)
+			},
+			wantErr: ErrOriginNotAllowed,
+		},
+		{
+			name: "signature altérée",
+			answer: func(t *testing.T, authenticator *softAuthenticator, challenge string) ([]byte, []byte, []byte) {
+				clientDataJSON, authData, signature := authenticator.assert(t, challenge, testOrigin)
+				authData[len(authData)-1] ^= 0xff
+				return clientDataJSON, authData, signature
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "utilisateur non vérifié",
+			modify:  func(authenticator *softAuthenticator) { authenticator.flags = webauthnFlagUserPresent },
+			wantErr: ErrUserNotVerified,
+		},
+		{
+			name:    "utilisateur absent",
+			modify:  func(authenticator *softAuthenticator) { authenticator.flags = webauthnFlagUserVerified },
+			wantErr: ErrUserNotPresent,
+		},
+		{
+			name: "sauvegarde sans éligibilité",
+			modify: func(authenticator *softAuthenticator) {
+				authenticator.flags |= webauthnFlagBackupState
+			},
+			wantErr: ErrInvalidAuthenticatorData,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newSoftAuthenticator(t)
+			challenge := newChallenge(t)
+			clientDataJSON, attestationObject := authenticator.register(t, challenge, testOrigin)
+			credential, err := testRelyingParty.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+			if err != nil {
+				t.Fatalf("VerifyRegistration: %v", err)
+			}
+
+			if tt.modify != nil {
+				tt.modify(authenticator)
+			}
+			challenge = newChallenge(t)
+			var authData, signature []byte
+			if tt.answer != nil {
+				clientDataJSON, authData, signature = tt.answer(t, authenticator, challenge)
+			} else {
+				clientDataJSON, authData, signature = authenticator.assert(t, challenge, testOrigin)
+			}
+
+			if _, err := testRelyingParty.VerifyAssertion(challenge, credential.PublicKey, clientDataJSON, authData, signature); err != tt.wantErr {
+				t.Fatalf("err = %v, attendu %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertionRejectsOtherCredentialKey(t *testing.T) {
+	authenticator := newSoftAuthenticator(t)
+	other := newSoftAuthenticator(t)
+
+	challenge := newChallenge(t)
+	clientDataJSON, authData, signature := authenticator.assert(t, challenge, testOrigin)
+	if _, err := testRelyingParty.VerifyAssertion(challenge, other.coseKey(), clientDataJSON, authData, signature); err != ErrInvalidSignature {
+		t.Fatalf("err = %v, attendu %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestParseAuthenticatorDataRejectsMalformed(t *testing.T) {
+	authenticator := newSoftAuthenticator(t)
+	attested := authenticator.authenticatorData(true)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "tronqué", data: attested[:36]},
+		{name: "credential tronqué", data: attested[:60]},
+		{name: "octets en trop", data: append(append([]byte(nil), attested...), 0x00)},
+		{name: "extensions annoncées absentes", data: append(append([]byte(nil), attested[:32]...), webauthnFlagUserPresent|webauthnFlagUserVerified|webauthnFlagExtensionData, 0, 0, 0, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseAuthenticatorData(tt.data); err == nil {
+				t.Fatalf("ParseAuthenticatorData a accepté des données invalides")
+			}
+		})
+	}
+}
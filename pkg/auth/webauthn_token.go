@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// WebAuthnCeremonyLifetime est aussi le délai annoncé au navigateur (timeout des options)
+const WebAuthnCeremonyLifetime = 5 * time.Minute
+
+// Cérémonies WebAuthn : un token émis pour l'une ne peut pas servir à l'autre
+const (
+	WebAuthnCeremonyRegistration   = "registration"
+	WebAuthnCeremonyAuthentication = "authentication"
+)
+
+// WebAuthnClaims contient l'état d'une cérémonie en cours, porté par le client entre
+// la demande d'options et l'envoi de la réponse de l'authentificateur
+type WebAuthnClaims struct {
+	Ceremony  string
+	UserID    string
+	Challenge string
+	TokenID   string
+	ExpiresAt time.Time
+}
+
+// GenerateWebAuthnToken génère le token de cérémonie. UserID est vide pour une connexion
+// sans identifiant : l'utilisateur est déterminé par le credential présenté.
+func GenerateWebAuthnToken(ceremony string, userID string, challenge string, tokens *TokenConfig) (string, error) {
+	claims := jwt.MapClaims{
+		"aud":       tokens.Issuer,
+		"ceremony":  ceremony,
+		"challenge": challenge,
+	}
+	if userID != "" {
+		claims["sub"] = userID
+	}
+	return tokens.sign(TypeWebAuthn, claims, WebAuthnCeremonyLifetime)
+}
+
+func ValidateWebAuthnToken(tokenString string, ceremony string, tokens *TokenConfig) (*WebAuthnClaims, error) {
+	claims, err := tokens.parse(tokenString, TypeWebAuthn, tokens.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, _ := claims["ceremony"].(string); value != ceremony {
+		return nil, errors.New("invalid claim: ceremony")
+	}
+
+	challenge, ok := claims["challenge"].(string)
+	if !ok || challenge == "" {
+		return nil, errors.New("invalid claim: challenge")
+	}
+
+	tokenID, ok := claims["jti"].(string)
+	if !ok {
+		return nil, errors.New("invalid claim: jti")
+	}
+
+	userID, _ := claims["sub"].(string)
+	if ceremony == WebAuthnCeremonyRegistration && userID == "" {
+		return nil, errors.New("invalid claim: sub")
+	}
+
+	exp, _ := claims["exp"].(float64)
+
+	return &WebAuthnClaims{
+		Ceremony:  ceremony,
+		UserID:    userID,
+		Challenge: challenge,
+		TokenID:   tokenID,
+		ExpiresAt: time.Unix(int64(exp), 0),
+	}, nil
+}
@@ -19,12 +19,16 @@ func main() {
 
 	// Initialiser la configuration
 	cfg := config.Load()
+	tokens := &auth.TokenConfig{
+		Keys:   auth.NewStaticKeySource(auth.NewHMACKey(cfg.JWTKeyID, cfg.JWTSecret)),
+		Issuer: cfg.JWTIssuer,
+	}
 
 	// Test de génération d'un reset token
 	email := "test@example.com"
 	fmt.Println("Génération d'un reset token pour:", email)
 	
-	resetToken, tokenUID, err := auth.GenerateResetToken(email, cfg.ResetTokenSecret, cfg.TokenExpiryHours)
+	resetToken, tokenUID, err := auth.GenerateResetToken(email, tokens, cfg.TokenExpiryHours)
 	if err != nil {
 		fmt.Printf("Erreur lors de la génération du reset token: %v\n", err)
 		os.Exit(1)
@@ -36,7 +40,7 @@ func main() {
 	// Test de validation du reset token
 	fmt.Println("\nValidation du reset token...")
 	
-	validatedEmail, validatedUID, err := auth.ValidateResetToken(resetToken, cfg.ResetTokenSecret)
+	validatedEmail, validatedUID, err := auth.ValidateResetToken(resetToken, tokens)
 	if err != nil {
 		fmt.Printf("Erreur lors de la validation du reset token: %v\n", err)
 		os.Exit(1)
@@ -60,7 +64,11 @@ func main() {
 	
 	// Test avec un mauvais secret
 	fmt.Println("\nTest avec un mauvais secret...")
-	_, _, err = auth.ValidateResetToken(resetToken, "mauvais-secret")
+	wrongTokens := &auth.TokenConfig{
+		Keys:   auth.NewStaticKeySource(auth.NewHMACKey(cfg.JWTKeyID, "mauvais-secret")),
+		Issuer: cfg.JWTIssuer,
+	}
+	_, _, err = auth.ValidateResetToken(resetToken, wrongTokens)
 	if err != nil {
 		fmt.Printf("Erreur attendue avec un mauvais secret: %v\n", err)
 	} else {
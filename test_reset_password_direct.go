@@ -3,11 +3,15 @@ package main
 import (
 	"fmt"
 	"log"
+	"net/url"
+	"regexp"
 
 	"github.com/amirtalbi/examen_go/internal/config"
 	"github.com/amirtalbi/examen_go/internal/domain/models"
 	"github.com/amirtalbi/examen_go/internal/domain/repositories"
+	"github.com/amirtalbi/examen_go/internal/mail"
 	"github.com/amirtalbi/examen_go/internal/service"
+	"github.com/amirtalbi/examen_go/pkg/auth"
 )
 
 func main() {
@@ -17,13 +21,42 @@ func main() {
 	// Créer un repository utilisateur en mémoire pour les tests
 	userRepo := repositories.NewUserRepository()
 
-	// Créer un service d'authentification
-	authService := service.NewAuthService(userRepo, cfg)
+	// Les emails sont conservés en mémoire pour récupérer le lien de réinitialisation
+	mailer := mail.NewMemoryMailer()
+
+	// Créer un service d'authentification avec des dépendances en mémoire
+	tokens := &auth.TokenConfig{
+		Keys:             auth.NewStaticKeySource(auth.NewHMACKey(cfg.JWTKeyID, cfg.JWTSecret)),
+		Issuer:           cfg.JWTIssuer,
+		Audience:         cfg.JWTAudience,
+		AcceptedAudience: cfg.JWTAudience[0],
+	}
+	sessionRepo := repositories.NewSessionRepository()
+	refreshTokenRepo := repositories.NewRefreshTokenRepository()
+	sessionService := service.NewSessionService(sessionRepo, refreshTokenRepo)
+	rbacService := service.NewRBACService(repositories.NewRoleRepository(), userRepo, sessionService)
+	authService := service.NewAuthService(
+		userRepo,
+		sessionRepo,
+		refreshTokenRepo,
+		repositories.NewPasswordHistoryRepository(),
+		repositories.NewRevocationStore(),
+		service.NewMFAService(repositories.NewMFARepository(), userRepo, cfg),
+		service.NewLockoutService(repositories.NewLoginAttemptRepository(), userRepo, repositories.NewRevocationStore(), tokens, service.NewLogSecurityEventSink(), mailer, cfg),
+		rbacService,
+		service.NewOrganizationService(repositories.NewOrganizationRepository(), userRepo, rbacService, sessionService, cfg),
+		tokens,
+		auth.NewArgon2idHasher(auth.DefaultArgon2idParams),
+		&auth.PasswordPolicy{MinLength: 8, MaxLength: 128, MinStrength: 2},
+		service.NewLogSecurityEventSink(),
+		mailer,
+		cfg,
+	)
 
 	// Enregistrer un utilisateur de test
 	registerRequest := models.RegisterRequest{
 		Email:    "test@example.com",
-		Password: "password123",
+		Password: "velo-rouge-du-dimanche",
 		Name:     "Test User",
 	}
 
@@ -36,12 +69,24 @@ func main() {
 
 	// 1. Demander un token de réinitialisation
 	fmt.Println("1. Demande d'un token de réinitialisation pour:", registerRequest.Email)
-	resetToken, err := authService.ForgotPassword(registerRequest.Email)
+	err = authService.ForgotPassword(registerRequest.Email, "")
 	if err != nil {
 		log.Fatalf("Erreur lors de la demande de réinitialisation: %v", err)
 	}
 
-	fmt.Println("✅ Token de réinitialisation reçu directement dans la réponse:", resetToken)
+	// Le token n'est plus retourné : il est extrait du lien contenu dans l'email
+	message, ok := mailer.Last(registerRequest.Email)
+	if !ok {
+		log.Fatalf("❌ Aucun email de réinitialisation envoyé")
+	}
+	link := regexp.MustCompile(`https?://\S+`).FindString(message.Text)
+	parsedLink, err := url.Parse(link)
+	if err != nil {
+		log.Fatalf("❌ Lien de réinitialisation invalide: %v", err)
+	}
+	resetToken := parsedLink.Query().Get("token")
+
+	fmt.Println("✅ Token de réinitialisation reçu par email:", resetToken)
 
 	// 2. Utiliser le token pour réinitialiser le mot de passe
 	newPassword := "newpassword456"